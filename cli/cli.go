@@ -0,0 +1,430 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+// Exit codes returned by Run
+const (
+	EXIT_OK        = 0 // Success
+	EXIT_ERROR     = 1 // A file could not be read or written or an operation failed
+	EXIT_USAGE     = 2 // The command or its arguments are not valid
+	EXIT_PARSE     = 3 // The json (or a schema) could not be parsed
+	EXIT_NOT_FOUND = 4 // A path was not found
+	EXIT_INVALID   = 5 // The json is not valid for the schema
+)
+
+type command struct {
+	args string
+	desc string
+	run  func(c *cli, args []string) int
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"fmt":      {"[-compact] [-indent n] [-w] [file...]", "Format json. Written to stdout unless -w replaces the files", runFmt},
+		"validate": {"[-schema file] [file...]", "Check that the json is valid. With -schema check it against a json schema", runValidate},
+		"get":      {"[-raw] <path> [file]", "Write the value at the path. -raw writes strings without quotes", runGet},
+		"set":      {"[-string] [-w] <path> <value> [file]", "Set the value at the path. The value is json unless -string is given", runSet},
+		"delete":   {"[-w] <path> [file]", "Remove the value at the path", runDelete},
+		"diag":     {"[file]", "Write the diagnostic list of nodes", runDiag},
+		"gostruct": {"[-name Root] [-package name] [file...]", "Write Go type definitions for the json", runGoStruct},
+		"repl":     {"[file]", "Explore and change the json with commands such as cd, ls, cat and set. Use help for a list", runRepl},
+	}
+}
+
+type cli struct {
+	name   string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Run a command and return the exit code. name is the program name used in messages and args are the
+// command and its arguments.
+// A path is a json pointer if it starts with '/' else a dot path such as "address.phoneNumbers.0".
+// The path "" is the whole document. When no file is given (or the file is "-") stdin is read.
+func Run(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{name: name, stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.usage()
+		if len(args) == 0 {
+			return EXIT_USAGE
+		}
+		return EXIT_OK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		c.errorf("unknown command '%s'", args[0])
+		c.usage()
+		return EXIT_USAGE
+	}
+	return cmd.run(c, args[1:])
+}
+
+func (c *cli) usage() {
+	fmt.Fprintf(c.stderr, "Usage: %s <command> [arguments]\n", c.name)
+	names := make([]string, 0, len(commands))
+	for k := range commands {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(c.stderr, "  %s %s\n      %s\n", k, commands[k].args, commands[k].desc)
+	}
+	fmt.Fprintf(c.stderr, "A <path> is a json pointer (/a/0/b) or a dot path (a.0.b). stdin is read if there is no file.\n")
+	fmt.Fprintf(c.stderr, "Exit codes: 0 ok, 1 error, 2 usage, 3 parse error, 4 path not found, 5 schema violations\n")
+}
+
+func (c *cli) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(c.stderr, "%s: %s\n", c.name, fmt.Sprintf(format, args...))
+}
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s %s %s\n", c.name, name, commands[name].args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Read and parse a file. "" or "-" reads stdin. The exit code is EXIT_OK if the json was read.
+func (c *cli) read(file string) (parser.NodeI, int) {
+	r := c.stdin
+	if file == "" || file == "-" {
+		file = "stdin"
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			c.errorf("failed to read %s. %s", file, err.Error())
+			return nil, EXIT_ERROR
+		}
+		defer f.Close()
+		r = f
+	}
+	node, err := parser.ParseReader(r)
+	if err != nil {
+		// A ParseError with an underlying error is a failure to read the input
+		var pe *parser.ParseError
+		if errors.As(err, &pe) && pe.Err != nil {
+			c.errorf("failed to read %s. %s", file, pe.Err.Error())
+			return nil, EXIT_ERROR
+		}
+		c.errorf("failed to parse %s. %s", file, err.Error())
+		return nil, EXIT_PARSE
+	}
+	return node, EXIT_OK
+}
+
+// Write the json to stdout or replace the file if inPlace is true
+func (c *cli) write(text, file string, inPlace bool) int {
+	if !inPlace {
+		fmt.Fprintln(c.stdout, text)
+		return EXIT_OK
+	}
+	if file == "" || file == "-" {
+		c.errorf("-w requires a file")
+		return EXIT_USAGE
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(file, []byte(text+"\n"), mode); err != nil {
+		c.errorf("failed to write %s. %s", file, err.Error())
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// Return the node as indented json. JsonValueIndented indents the root by one level so this is removed.
+func Pretty(node parser.NodeI, indent int) string {
+	if node.GetName() != "" {
+		node = parser.Clone(node, "", true)
+	}
+	if indent <= 0 {
+		return node.JsonValue()
+	}
+	lines := strings.Split(strings.TrimPrefix(node.JsonValueIndented(indent), "\n"), "\n")
+	pad := strings.Repeat(" ", indent)
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(l, pad)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Return a json pointer for a json pointer or a dot path
+func toPointer(path string) (string, error) {
+	if path == "" || strings.HasPrefix(path, "/") {
+		if _, err := parser.NewPointerPath(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	return parser.NewDotPath(path).Pointer(), nil
+}
+
+// Return the file argument at i or "" if there is not one
+func fileArg(fs *flag.FlagSet, i int) string {
+	if fs.NArg() > i {
+		return fs.Arg(i)
+	}
+	return ""
+}
+
+func runFmt(c *cli, args []string) int {
+	fs := c.flags("fmt")
+	compact := fs.Bool("compact", false, "write the json on one line")
+	indent := fs.Int("indent", 4, "the number of spaces to indent by")
+	inPlace := fs.Bool("w", false, "replace the files with the formatted json")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	if *compact {
+		*indent = 0
+	}
+	result := EXIT_OK
+	for _, f := range files {
+		node, rc := c.read(f)
+		if rc == EXIT_OK {
+			rc = c.write(Pretty(node, *indent), f, *inPlace)
+		}
+		if rc > result {
+			result = rc
+		}
+	}
+	return result
+}
+
+func runValidate(c *cli, args []string) int {
+	fs := c.flags("validate")
+	schemaFile := fs.String("schema", "", "a json schema file")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	var schema *parser.Schema
+	if *schemaFile != "" {
+		dat, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			c.errorf("failed to read %s. %s", *schemaFile, err.Error())
+			return EXIT_ERROR
+		}
+		schema, err = parser.ParseSchema(dat)
+		if err != nil {
+			c.errorf("failed to parse schema %s. %s", *schemaFile, err.Error())
+			return EXIT_PARSE
+		}
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	result := EXIT_OK
+	for _, f := range files {
+		name := f
+		if name == "" || name == "-" {
+			name = "stdin"
+		}
+		node, rc := c.read(f)
+		if rc == EXIT_OK && schema != nil {
+			for _, v := range schema.Validate(node) {
+				fmt.Fprintf(c.stdout, "%s: %s\n", name, v)
+				rc = EXIT_INVALID
+			}
+		}
+		if rc == EXIT_OK {
+			fmt.Fprintf(c.stdout, "%s: ok\n", name)
+		}
+		if rc > result {
+			result = rc
+		}
+	}
+	return result
+}
+
+func runGet(c *cli, args []string) int {
+	fs := c.flags("get")
+	raw := fs.Bool("raw", false, "write a string value without quotes")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	pointer, err := toPointer(fs.Arg(0))
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return EXIT_USAGE
+	}
+	root, rc := c.read(fileArg(fs, 1))
+	if rc != EXIT_OK {
+		return rc
+	}
+	node, err := parser.FindPointer(root, pointer)
+	if err != nil {
+		c.errorf("path '%s' was not found", fs.Arg(0))
+		return EXIT_NOT_FOUND
+	}
+	if s, ok := node.(*parser.JsonString); ok && *raw {
+		fmt.Fprintln(c.stdout, s.GetValue())
+	} else {
+		fmt.Fprintln(c.stdout, Pretty(node, 4))
+	}
+	return EXIT_OK
+}
+
+func runSet(c *cli, args []string) int {
+	fs := c.flags("set")
+	asString := fs.Bool("string", false, "the value is a string and not json")
+	inPlace := fs.Bool("w", false, "replace the file with the updated json")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	pointer, err := toPointer(fs.Arg(0))
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return EXIT_USAGE
+	}
+	var value parser.NodeI = parser.NewJsonString("", fs.Arg(1))
+	if !*asString {
+		value, err = parser.Parse([]byte(fs.Arg(1)))
+		if err != nil {
+			c.errorf("value '%s' is not valid json. Use -string for a string value. %s", fs.Arg(1), err.Error())
+			return EXIT_PARSE
+		}
+	}
+	file := fileArg(fs, 2)
+	root, rc := c.read(file)
+	if rc != EXIT_OK {
+		return rc
+	}
+	op := parser.PATCH_ADD
+	if _, err := parser.FindPointer(root, pointer); err == nil {
+		op = parser.PATCH_REPLACE
+	} else if p, _ := parser.NewPointerPath(pointer); p.Len() > 1 {
+		if _, err := parser.FindPointer(root, p.PathParent().Pointer()); err != nil {
+			c.errorf("path '%s' was not found", fs.Arg(0))
+			return EXIT_NOT_FOUND
+		}
+	}
+	root, err = parser.Patch{{Op: op, Path: pointer, Value: value}}.Apply(root)
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return EXIT_ERROR
+	}
+	return c.write(Pretty(root, 4), file, *inPlace)
+}
+
+func runDelete(c *cli, args []string) int {
+	fs := c.flags("delete")
+	inPlace := fs.Bool("w", false, "replace the file with the updated json")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	pointer, err := toPointer(fs.Arg(0))
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return EXIT_USAGE
+	}
+	if pointer == "" {
+		c.errorf("the whole document cannot be deleted")
+		return EXIT_USAGE
+	}
+	file := fileArg(fs, 1)
+	root, rc := c.read(file)
+	if rc != EXIT_OK {
+		return rc
+	}
+	if _, err := parser.FindPointer(root, pointer); err != nil {
+		c.errorf("path '%s' was not found", fs.Arg(0))
+		return EXIT_NOT_FOUND
+	}
+	root, err = parser.Patch{{Op: parser.PATCH_REMOVE, Path: pointer}}.Apply(root)
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return EXIT_ERROR
+	}
+	return c.write(Pretty(root, 4), file, *inPlace)
+}
+
+func runDiag(c *cli, args []string) int {
+	fs := c.flags("diag")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	root, rc := c.read(fileArg(fs, 0))
+	if rc != EXIT_OK {
+		return rc
+	}
+	fmt.Fprintln(c.stdout, parser.DiagnosticList(root))
+	return EXIT_OK
+}
+
+func runGoStruct(c *cli, args []string) int {
+	fs := c.flags("gostruct")
+	name := fs.String("name", "Root", "the name of the root type")
+	pkg := fs.String("package", "", "write a package clause with this name")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	samples := make([]parser.NodeI, 0, len(files))
+	for _, f := range files {
+		node, rc := c.read(f)
+		if rc != EXIT_OK {
+			return rc
+		}
+		samples = append(samples, node)
+	}
+	src, err := parser.GoStructs(parser.GoStructOptions{RootName: *name, PackageName: *pkg}, samples...)
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return EXIT_ERROR
+	}
+	fmt.Fprint(c.stdout, src)
+	return EXIT_OK
+}
@@ -0,0 +1,751 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+// The maximum depth of the current location and the number of undo steps kept
+const (
+	REPL_MAX_DEPTH = 256
+	REPL_MAX_UNDO  = 100
+)
+
+var replHelp = []string{
+	"cd [path]          Change the current node. No path is the root",
+	"ls [path]          List the child nodes",
+	"cat [path]         Write the json value",
+	"set <path> <value> Add or replace a value. The value is a string if it is not json",
+	"rm <path>          Remove a node",
+	"mv <from> <to>     Move a node",
+	"find <text>        Find nodes with a name containing text. Text starting with '$' is a json path",
+	"undo               Undo the last set, rm or mv",
+	"save [file]        Write the json to the file",
+	"pwd                Write the current path",
+	"exit               Leave. Also quit or Ctrl-D",
+	"A path is like a file path: /a/0/b is from the root, a/b is from the current node and .. is the parent.",
+	"'~1' is a '/' in a name and '~0' is a '~'. Press TAB to complete a command or a name.",
+}
+
+// An interactive explorer for a json document. Commands are run with Exec.
+type Repl struct {
+	root  parser.NodeI
+	file  string
+	trail *parser.Trail // The nodes from the root to the current node. The root is not included
+	undo  []*replUndo
+	dirty bool
+	out   io.Writer
+}
+
+// Create a Repl for the root node. file is the default file for save.
+func NewRepl(root parser.NodeI, file string, out io.Writer) *Repl {
+	return &Repl{root: root, file: file, trail: parser.NewTrail(REPL_MAX_DEPTH, "/"), undo: make([]*replUndo, 0), out: out}
+}
+
+// Return the root node. This changes if the whole document is replaced or a change is undone.
+func (r *Repl) Root() parser.NodeI {
+	return r.root
+}
+
+// Return the current node
+func (r *Repl) Current() parser.NodeI {
+	if r.trail.Len() == 0 {
+		return r.root
+	}
+	return r.trail.GetLast()
+}
+
+var replEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Return the names (or indexes for lists) of the nodes in the trail
+func (r *Repl) trailElements() []string {
+	elements := make([]string, 0, r.trail.Len())
+	for i := 0; i < r.trail.Len(); i++ {
+		if ind := r.trail.GetIndexAt(uint(i)); ind >= 0 {
+			elements = append(elements, strconv.Itoa(ind))
+		} else {
+			elements = append(elements, r.trail.GetNodeAt(uint(i)).GetName())
+		}
+	}
+	return elements
+}
+
+// Return the json pointer of the current node. The root is "/".
+func (r *Repl) Pwd() string {
+	p := replJoin(r.trailElements())
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// Return a json pointer for the elements
+func replJoin(elements []string) string {
+	var sb strings.Builder
+	for _, e := range elements {
+		sb.WriteByte('/')
+		sb.WriteString(replEscaper.Replace(e))
+	}
+	return sb.String()
+}
+
+// Return the prompt. A '*' shows there are changes that have not been saved.
+func (r *Repl) Prompt() string {
+	if r.dirty {
+		return r.Pwd() + " *> "
+	}
+	return r.Pwd() + "> "
+}
+
+// Run a command line. Return true if the Repl should exit.
+func (r *Repl) Exec(line string) bool {
+	args := replSplit(line)
+	if len(args) == 0 {
+		return false
+	}
+	var err error
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	case "help", "?":
+		for _, h := range replHelp {
+			fmt.Fprintln(r.out, h)
+		}
+	case "pwd":
+		fmt.Fprintln(r.out, r.Pwd())
+	case "cd":
+		err = r.cd(replArg(args, 1, "/"))
+	case "ls":
+		err = r.ls(replArg(args, 1, "."))
+	case "cat":
+		var n parser.NodeI
+		if n, err = r.find(replArg(args, 1, ".")); err == nil {
+			fmt.Fprintln(r.out, Pretty(n, 4))
+		}
+	case "set":
+		if len(args) < 3 {
+			err = fmt.Errorf("usage: set <path> <value>")
+		} else {
+			err = r.set(args[1], strings.Join(args[2:], " "))
+		}
+	case "rm":
+		if len(args) != 2 {
+			err = fmt.Errorf("usage: rm <path>")
+		} else {
+			err = r.rm(args[1])
+		}
+	case "mv":
+		if len(args) != 3 {
+			err = fmt.Errorf("usage: mv <from> <to>")
+		} else {
+			err = r.mv(args[1], args[2])
+		}
+	case "find":
+		if len(args) < 2 {
+			err = fmt.Errorf("usage: find <text>")
+		} else {
+			err = r.findText(strings.Join(args[1:], " "))
+		}
+	case "undo":
+		err = r.undoLast()
+	case "save":
+		err = r.save(replArg(args, 1, r.file))
+	default:
+		err = fmt.Errorf("unknown command '%s'. Use help for a list of commands", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %s\n", err.Error())
+	}
+	return false
+}
+
+// Split a line in to words. A word can be quoted with ' or ".
+func replSplit(line string) []string {
+	words := make([]string, 0)
+	var sb strings.Builder
+	var quote rune
+	inWord := false
+	for _, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			sb.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+				inWord = false
+			}
+		default:
+			sb.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, sb.String())
+	}
+	return words
+}
+
+func replArg(args []string, i int, def string) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return def
+}
+
+// Return the elements of the path from the root. A path starting with '/' is from the root.
+// Other paths are from the current node.
+func (r *Repl) elements(path string) ([]string, error) {
+	elements := make([]string, 0)
+	if !strings.HasPrefix(path, "/") {
+		elements = append(elements, r.trailElements()...)
+	}
+	for _, e := range strings.Split(path, "/") {
+		switch e {
+		case "", ".":
+		case "..":
+			if len(elements) > 0 {
+				elements = elements[:len(elements)-1]
+			}
+		default:
+			p, err := parser.NewPointerPath("/" + e)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, p.StringFirst())
+		}
+	}
+	return elements, nil
+}
+
+// Return the json pointer for the path
+func (r *Repl) pointer(path string) (string, error) {
+	elements, err := r.elements(path)
+	if err != nil {
+		return "", err
+	}
+	return replJoin(elements), nil
+}
+
+// Return the child of a container with the name or index
+func replChild(n parser.NodeI, name string) parser.NodeI {
+	switch c := n.(type) {
+	case *parser.JsonObject:
+		return c.GetNodeWithName(name)
+	case *parser.JsonList:
+		i, err := strconv.Atoi(name)
+		if err == nil && i >= 0 && i < c.Len() {
+			return c.GetNodeAt(i)
+		}
+		return c.GetNodeWithName(name)
+	}
+	return nil
+}
+
+// Return the node at the path. The trail is filled with the nodes from the root.
+func (r *Repl) walk(path string, trail *parser.Trail) (parser.NodeI, error) {
+	elements, err := r.elements(path)
+	if err != nil {
+		return nil, err
+	}
+	node := r.root
+	for i, e := range elements {
+		child := replChild(node, e)
+		if child == nil {
+			return nil, fmt.Errorf("'%s' was not found", replJoin(elements[:i+1]))
+		}
+		index := -1
+		if l, ok := node.(*parser.JsonList); ok {
+			for j, v := range l.GetValues() {
+				if v == child {
+					index = j
+				}
+			}
+		}
+		if !trail.Push(child, index) {
+			return nil, fmt.Errorf("the path is deeper than %d", REPL_MAX_DEPTH)
+		}
+		node = child
+	}
+	return node, nil
+}
+
+func (r *Repl) find(path string) (parser.NodeI, error) {
+	return r.walk(path, parser.NewTrail(REPL_MAX_DEPTH, "/"))
+}
+
+func (r *Repl) cd(path string) error {
+	trail := parser.NewTrail(REPL_MAX_DEPTH, "/")
+	n, err := r.walk(path, trail)
+	if err != nil {
+		return err
+	}
+	if !n.IsContainer() {
+		return fmt.Errorf("'%s' is not an object or a list", replPointer(n))
+	}
+	r.trail = trail
+	return nil
+}
+
+// Return the names of the child nodes. Object keys are sorted. List members are the index.
+func replChildNames(n parser.NodeI) []string {
+	switch c := n.(type) {
+	case *parser.JsonObject:
+		return c.GetSortedKeys()
+	case *parser.JsonList:
+		names := make([]string, c.Len())
+		for i := range names {
+			names[i] = strconv.Itoa(i)
+		}
+		return names
+	}
+	return []string{}
+}
+
+func (r *Repl) ls(path string) error {
+	n, err := r.find(path)
+	if err != nil {
+		return err
+	}
+	if !n.IsContainer() {
+		fmt.Fprintf(r.out, "%s %s\n", parser.GetNodeTypeName(n.GetNodeType()), Pretty(n, 0))
+		return nil
+	}
+	for _, name := range replChildNames(n) {
+		c := replChild(n, name)
+		switch v := c.(type) {
+		case *parser.JsonObject:
+			fmt.Fprintf(r.out, "%s/ {%d}\n", name, v.Len())
+		case *parser.JsonList:
+			fmt.Fprintf(r.out, "%s/ [%d]\n", name, v.Len())
+		default:
+			fmt.Fprintf(r.out, "%s: %s\n", name, Pretty(c, 0))
+		}
+	}
+	return nil
+}
+
+// An undo step. op is the inverse of a change. A json patch adds an object member as the
+// last member so a removed member is moved back before the member named before.
+type replUndo struct {
+	op     *parser.PatchOperation
+	before string
+}
+
+// Apply a patch operation. The inverse operation is kept so it can be undone.
+func (r *Repl) apply(op *parser.PatchOperation) error {
+	undo, moved := r.inverse(op)
+	root, err := parser.Patch{op}.Apply(r.root)
+	if err != nil {
+		return err
+	}
+	if moved != nil {
+		undo.op.From = parser.PointerTo(moved)
+	}
+	r.undo = append(r.undo, undo)
+	if len(r.undo) > REPL_MAX_UNDO {
+		r.undo = r.undo[1:]
+	}
+	r.root = root
+	r.dirty = true
+	r.restore()
+	return nil
+}
+
+// Return the operation that undoes op. The nodes that op removes or replaces are kept, not copied.
+// For a move the node is returned so the From of the inverse can be set once it has moved.
+// If the node is not found the undo step is not used as op will fail.
+func (r *Repl) inverse(op *parser.PatchOperation) (*replUndo, parser.NodeI) {
+	old := r.findPointer(op.Path)
+	switch op.Op {
+	case parser.PATCH_REMOVE:
+		return &replUndo{op: &parser.PatchOperation{Op: parser.PATCH_ADD, Path: op.Path, Value: old}, before: replNextKey(old)}, nil
+	case parser.PATCH_MOVE:
+		node := r.findPointer(op.From)
+		return &replUndo{op: &parser.PatchOperation{Op: parser.PATCH_MOVE, Path: op.From}, before: replNextKey(node)}, node
+	}
+	if old != nil {
+		return &replUndo{op: &parser.PatchOperation{Op: parser.PATCH_REPLACE, Path: op.Path, Value: old}}, nil
+	}
+	parentPointer := op.Path[:strings.LastIndex(op.Path, "/")]
+	if l, ok := r.findPointer(parentPointer).(*parser.JsonList); ok && strings.HasSuffix(op.Path, "/-") {
+		return &replUndo{op: &parser.PatchOperation{Op: parser.PATCH_REMOVE, Path: parentPointer + "/" + strconv.Itoa(l.Len())}}, nil
+	}
+	return &replUndo{op: &parser.PatchOperation{Op: parser.PATCH_REMOVE, Path: op.Path}}, nil
+}
+
+// Return the node at pointer or nil if it is not found
+func (r *Repl) findPointer(pointer string) parser.NodeI {
+	n, err := parser.FindPointer(r.root, pointer)
+	if err != nil {
+		return nil
+	}
+	return n
+}
+
+// Return the name of the member after n if n is in an object
+func replNextKey(n parser.NodeI) string {
+	if n == nil {
+		return ""
+	}
+	if o, ok := n.GetParent().(*parser.JsonObject); ok {
+		keys := o.GetKeys()
+		for i, k := range keys {
+			if k == n.GetName() && i+1 < len(keys) {
+				return keys[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// Move to the current path in a changed tree. If it is no longer found use the nearest parent.
+func (r *Repl) restore() {
+	pwd := r.Pwd()
+	for {
+		if r.cd(pwd) == nil {
+			return
+		}
+		pwd = pwd[:strings.LastIndex(pwd, "/")]
+		if pwd == "" {
+			r.trail.Clear()
+			return
+		}
+	}
+}
+
+func (r *Repl) set(path, value string) error {
+	pointer, err := r.pointer(path)
+	if err != nil {
+		return err
+	}
+	node, err := parser.Parse([]byte(value))
+	if err != nil {
+		node = parser.NewJsonString("", value)
+	}
+	op := parser.PATCH_ADD
+	if _, err := parser.FindPointer(r.root, pointer); err == nil {
+		op = parser.PATCH_REPLACE
+	}
+	return r.apply(&parser.PatchOperation{Op: op, Path: pointer, Value: node})
+}
+
+func (r *Repl) rm(path string) error {
+	pointer, err := r.pointer(path)
+	if err != nil {
+		return err
+	}
+	if pointer == "" {
+		return fmt.Errorf("the root cannot be removed")
+	}
+	return r.apply(&parser.PatchOperation{Op: parser.PATCH_REMOVE, Path: pointer})
+}
+
+func (r *Repl) mv(from, to string) error {
+	fp, err := r.pointer(from)
+	if err != nil {
+		return err
+	}
+	tp, err := r.pointer(to)
+	if err != nil {
+		return err
+	}
+	// Moving in to an existing object keeps the name like mv for files. Moving in to a list appends
+	if n, err := r.find(to); err == nil && tp != fp {
+		switch n.GetNodeType() {
+		case parser.NT_OBJECT:
+			tp = tp + fp[strings.LastIndex(fp, "/"):]
+		case parser.NT_LIST:
+			tp = tp + "/-"
+		}
+	}
+	return r.apply(&parser.PatchOperation{Op: parser.PATCH_MOVE, From: fp, Path: tp})
+}
+
+func (r *Repl) findText(text string) error {
+	if strings.HasPrefix(text, "$") {
+		nodes, _, err := parser.JsonPathQuery(r.Current(), text)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			fmt.Fprintf(r.out, "%s: %s\n", replPointer(n), Pretty(n, 0))
+		}
+		return nil
+	}
+	var walk func(n parser.NodeI)
+	walk = func(n parser.NodeI) {
+		if n.GetName() != "" && strings.Contains(n.GetName(), text) {
+			fmt.Fprintln(r.out, replPointer(n))
+		}
+		if c, ok := n.(parser.NodeC); ok {
+			for _, v := range c.GetValues() {
+				walk(v)
+			}
+		}
+	}
+	walk(r.Current())
+	return nil
+}
+
+func replPointer(n parser.NodeI) string {
+	if p := parser.PointerTo(n); p != "" {
+		return p
+	}
+	return "/"
+}
+
+func (r *Repl) undoLast() error {
+	if len(r.undo) == 0 {
+		return fmt.Errorf("there is nothing to undo")
+	}
+	undo := r.undo[len(r.undo)-1]
+	root, err := parser.Patch{undo.op}.Apply(r.root)
+	if err != nil {
+		return err
+	}
+	r.root = root
+	if undo.before != "" {
+		if o, ok := r.findPointer(undo.op.Path[:strings.LastIndex(undo.op.Path, "/")]).(*parser.JsonObject); ok {
+			path, _ := parser.NewPointerPath(undo.op.Path)
+			o.MoveBefore(path.StringLast(), undo.before)
+		}
+	}
+	r.undo = r.undo[:len(r.undo)-1]
+	r.dirty = len(r.undo) > 0
+	r.restore()
+	return nil
+}
+
+func (r *Repl) save(file string) error {
+	if file == "" {
+		return fmt.Errorf("usage: save <file>")
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(file, []byte(Pretty(r.root, 4)+"\n"), mode); err != nil {
+		return err
+	}
+	r.file = file
+	r.dirty = false
+	fmt.Fprintf(r.out, "saved %s\n", file)
+	return nil
+}
+
+// Return the possible completions of the last word in line. A command is completed
+// if it is the first word. Otherwise the names of the child nodes are completed.
+// Names of objects and lists end with a '/'.
+func (r *Repl) Complete(line string) []string {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	out := make([]string, 0)
+	if strings.TrimSpace(line[:start]) == "" {
+		for _, c := range []string{"cat", "cd", "exit", "find", "help", "ls", "mv", "pwd", "quit", "rm", "save", "set", "undo"} {
+			if strings.HasPrefix(c, word) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	dir, prefix := "", word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dir, prefix = word[:i+1], word[i+1:]
+	}
+	parent, err := r.find(dir)
+	if err != nil {
+		return out
+	}
+	for _, name := range replChildNames(parent) {
+		escaped := replEscaper.Replace(name)
+		if strings.HasPrefix(escaped, prefix) {
+			if replChild(parent, name).IsContainer() {
+				escaped = escaped + "/"
+			}
+			out = append(out, dir+escaped)
+		}
+	}
+	return out
+}
+
+// Return the longest prefix of all the values
+func commonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	p := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
+
+func runRepl(c *cli, args []string) int {
+	fs := c.flags("repl")
+	if fs.Parse(args) != nil {
+		return EXIT_USAGE
+	}
+	var root parser.NodeI = parser.NewJsonObject("")
+	file := fileArg(fs, 0)
+	if file != "" {
+		node, rc := c.read(file)
+		if rc != EXIT_OK {
+			return rc
+		}
+		root = node
+	}
+	r := NewRepl(root, file, c.stdout)
+	var lines lineReader
+	if f, ok := c.stdin.(*os.File); ok {
+		lines = newTermReader(f, c.stdout, r.Complete)
+	}
+	if lines == nil {
+		lines = &scanReader{scanner: bufio.NewScanner(c.stdin), out: c.stdout}
+	}
+	defer lines.Close()
+	for {
+		line, err := lines.ReadLine(r.Prompt())
+		if err != nil {
+			return EXIT_OK
+		}
+		if r.Exec(line) {
+			return EXIT_OK
+		}
+	}
+}
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	Close()
+}
+
+// Read lines when stdin is not a terminal. The prompt is written so the output is the same.
+type scanReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (s *scanReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+	if !s.scanner.Scan() {
+		fmt.Fprintln(s.out)
+		return "", io.EOF
+	}
+	return s.scanner.Text(), nil
+}
+
+func (s *scanReader) Close() {
+}
+
+// A line editor for a terminal in raw mode. Supports backspace, TAB completion, Ctrl-C and Ctrl-D.
+type termReader struct {
+	in       *bufio.Reader
+	out      io.Writer
+	complete func(string) []string
+	restore  func()
+}
+
+func newTermReader(in *os.File, out io.Writer, complete func(string) []string) lineReader {
+	restore, err := makeRaw(in)
+	if err != nil {
+		return nil
+	}
+	return &termReader{in: bufio.NewReader(in), out: out, complete: complete, restore: restore}
+}
+
+func (t *termReader) Close() {
+	t.restore()
+}
+
+func (t *termReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(t.out, prompt)
+	line := make([]rune, 0)
+	reader := t.in
+	for {
+		c, _, err := reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch c {
+		case '\r', '\n':
+			fmt.Fprint(t.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(t.out, "^C\r\n"+prompt)
+			line = line[:0]
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(t.out, "\r\n")
+				return "", io.EOF
+			}
+		case 127, 8:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Fprint(t.out, "\b \b")
+			}
+		case '\t':
+			options := t.complete(string(line))
+			if len(options) == 0 {
+				continue
+			}
+			word := string(line)
+			word = word[strings.LastIndexAny(word, " \t")+1:]
+			add := strings.TrimPrefix(commonPrefix(options), word)
+			if len(options) == 1 && !strings.HasSuffix(options[0], "/") {
+				add = add + " "
+			}
+			if add == "" && len(options) > 1 {
+				sort.Strings(options)
+				fmt.Fprint(t.out, "\r\n"+strings.Join(options, "  ")+"\r\n"+prompt+string(line))
+				continue
+			}
+			line = append(line, []rune(add)...)
+			fmt.Fprint(t.out, add)
+		case 27: // Escape sequences such as the arrow keys are ignored
+			if b, _ := reader.ReadByte(); b == '[' {
+				for {
+					b, err = reader.ReadByte()
+					if err != nil || (b >= 'A' && b <= 'Z') || b == '~' {
+						break
+					}
+				}
+			}
+		default:
+			if c >= ' ' {
+				line = append(line, c)
+				fmt.Fprint(t.out, string(c))
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+// Raw mode is only supported on linux. Lines are read without TAB completion.
+func makeRaw(f *os.File) (func(), error) {
+	return nil, fmt.Errorf("raw mode is not supported")
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The maximum nesting of arrays and maps read by ParseCBOR and ParseMsgPack
+const BINARY_MAX_DEPTH = 1000
+
+// Options for the CBOR and MessagePack encodings. The zero value uses the defaults.
+type BinaryOptions struct {
+	Deterministic bool   // Write map keys in order of their encoded bytes so equal trees are always the same bytes
+	BytesPrefix   string // Byte strings are read as a JsonString of the prefix and the base64 of the bytes. Strings of the prefix and base64 are written as byte strings
+}
+
+// Return the JsonString for a byte string
+func binaryBytes(name string, b []byte, options BinaryOptions) NodeI {
+	return NewJsonString(name, options.BytesPrefix+base64.StdEncoding.EncodeToString(b))
+}
+
+// Return the bytes to write for a JsonString. nil if it is a text string.
+func binaryBytesValue(s string, options BinaryOptions) []byte {
+	if options.BytesPrefix == "" || !strings.HasPrefix(s, options.BytesPrefix) {
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s[len(options.BytesPrefix):])
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Return the JsonNumber for a float that was read. Values with no fraction are written with ".0"
+// so they are encoded as a float again.
+func binaryFloat(name string, f float64) (NodeI, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("the number '%s' cannot be held in a JsonNumber", strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	s := formatFloat(f)
+	if !strings.ContainsAny(s, ".e") {
+		s = s + ".0"
+	}
+	return NewJsonNumberFromLiteral(name, s)
+}
+
+// Return the value of a number written without a fraction or an exponent. nil if the number is a float.
+func binaryInteger(n *JsonNumber) *big.Int {
+	l := n.GetLiteral()
+	if strings.ContainsAny(l, ".eE") {
+		return nil
+	}
+	i, ok := new(big.Int).SetString(l, 10)
+	if !ok {
+		return nil
+	}
+	return i
+}
+
+// Return the keys of an object in the order they are written. encodeKey returns the bytes for a key.
+func binaryKeys(obj *JsonObject, deterministic bool, encodeKey func(string) []byte) []string {
+	keys := append([]string{}, obj.keys...)
+	if deterministic {
+		encoded := make(map[string][]byte, len(keys))
+		for _, k := range keys {
+			encoded[k] = encodeKey(k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(encoded[keys[i]], encoded[keys[j]]) < 0
+		})
+	}
+	return keys
+}
+
+// Return the name of a map key that was read. Keys can be strings or integers.
+func binaryKey(key NodeI) (string, error) {
+	switch k := key.(type) {
+	case *JsonString:
+		if k.GetValue() == "" {
+			return "", fmt.Errorf("a map key cannot be empty")
+		}
+		return k.GetValue(), nil
+	case *JsonNumber:
+		if binaryInteger(k) != nil {
+			return k.GetLiteral(), nil
+		}
+	}
+	return "", fmt.Errorf("a map key must be a string or an integer not a %s", GetNodeTypeName(key.GetNodeType()))
+}
+
+func binaryPathError(path []string, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at '%s'", fmt.Sprintf(format, args...), (&Path{path: path}).Pointer())
+}
+
+// Return the IEEE 754 half precision bits for f if it can be held without losing precision
+func float16Bits(f float64) (uint16, bool) {
+	f32 := float32(f)
+	if float64(f32) != f {
+		return 0, false
+	}
+	b := math.Float32bits(f32)
+	sign := uint16(b>>16) & 0x8000
+	if f == 0 {
+		return sign, true
+	}
+	exp := int(b>>23&0xff) - 127
+	mant := b & 0x7fffff
+	switch {
+	case exp >= -14 && exp <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(exp+15)<<10 | uint16(mant>>13), true
+	case exp >= -24 && exp < -14:
+		// A subnormal half is m * 2^-24
+		full := mant | 0x800000
+		shift := uint(-exp - 1)
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
+
+func float16Value(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h >> 10 & 0x1f)
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
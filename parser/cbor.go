@@ -0,0 +1,427 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	cborUint    = 0
+	cborNegInt  = 1
+	cborBytes   = 2
+	cborText    = 3
+	cborArray   = 4
+	cborMap     = 5
+	cborTag     = 6
+	cborSimple  = 7
+	cborBreak   = 0xff
+	cborIndef   = 31
+	cborPosBig  = 2 // Tag for an unsigned bignum
+	cborNegBig  = 3 // Tag for a negative bignum
+	cborFalse   = 0xf4
+	cborTrue    = 0xf5
+	cborNull    = 0xf6
+	cborFloat16 = 0xf9
+	cborFloat32 = 0xfa
+	cborFloat64 = 0xfb
+)
+
+// Return the node encoded as CBOR (RFC 8949).
+//
+// Numbers written without a fraction or an exponent are integers. Integers outside the 64 bit range
+// are bignums (tags 2 and 3). Other numbers are floats in the shortest form that holds the value
+// (half, single or double precision). Strings are text strings unless options.BytesPrefix is set.
+func CBORValue(n NodeI, options BinaryOptions) ([]byte, error) {
+	w := &cborWriter{options: options}
+	if err := w.write(n, parserEmptyPath); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+type cborWriter struct {
+	buf     bytes.Buffer
+	options BinaryOptions
+}
+
+// Write the initial byte for a major type and its argument in the shortest form
+func (w *cborWriter) head(major byte, arg uint64) {
+	var b [8]byte
+	switch {
+	case arg < 24:
+		w.buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		w.buf.WriteByte(major<<5 | 24)
+		w.buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		w.buf.WriteByte(major<<5 | 25)
+		binary.BigEndian.PutUint16(b[:], uint16(arg))
+		w.buf.Write(b[:2])
+	case arg <= math.MaxUint32:
+		w.buf.WriteByte(major<<5 | 26)
+		binary.BigEndian.PutUint32(b[:], uint32(arg))
+		w.buf.Write(b[:4])
+	default:
+		w.buf.WriteByte(major<<5 | 27)
+		binary.BigEndian.PutUint64(b[:], arg)
+		w.buf.Write(b[:])
+	}
+}
+
+func (w *cborWriter) text(s string) {
+	w.head(cborText, uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *cborWriter) write(n NodeI, path []string) error {
+	switch v := n.(type) {
+	case *JsonObject:
+		w.head(cborMap, uint64(v.Len()))
+		keys := binaryKeys(v, w.options.Deterministic, func(k string) []byte {
+			kw := &cborWriter{}
+			kw.text(k)
+			return kw.buf.Bytes()
+		})
+		for _, k := range keys {
+			w.text(k)
+			if err := w.write(*v.value[k], jpAppend(path, k)); err != nil {
+				return err
+			}
+		}
+	case *JsonList:
+		w.head(cborArray, uint64(v.Len()))
+		for i, item := range v.GetValues() {
+			if err := w.write(item, jpAppend(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case *JsonString:
+		if b := binaryBytesValue(v.GetValue(), w.options); b != nil {
+			w.head(cborBytes, uint64(len(b)))
+			w.buf.Write(b)
+		} else {
+			w.text(v.GetValue())
+		}
+	case *JsonNumber:
+		return w.number(v, path)
+	case *JsonBool:
+		if v.GetValue() {
+			w.buf.WriteByte(cborTrue)
+		} else {
+			w.buf.WriteByte(cborFalse)
+		}
+	case *JsonNull:
+		w.buf.WriteByte(cborNull)
+	}
+	return nil
+}
+
+func (w *cborWriter) number(n *JsonNumber, path []string) error {
+	if i := binaryInteger(n); i != nil {
+		if i.Sign() >= 0 {
+			if i.IsUint64() {
+				w.head(cborUint, i.Uint64())
+				return nil
+			}
+			w.head(cborTag, cborPosBig)
+		} else {
+			i.Sub(big.NewInt(-1), i)
+			if i.IsUint64() {
+				w.head(cborNegInt, i.Uint64())
+				return nil
+			}
+			w.head(cborTag, cborNegBig)
+		}
+		w.head(cborBytes, uint64(len(i.Bytes())))
+		w.buf.Write(i.Bytes())
+		return nil
+	}
+	f := n.GetValue()
+	if math.IsInf(f, 0) {
+		return binaryPathError(path, "the number %s is too large for a float", n.GetLiteral())
+	}
+	var b [8]byte
+	if h, ok := float16Bits(f); ok {
+		w.buf.WriteByte(cborFloat16)
+		binary.BigEndian.PutUint16(b[:], h)
+		w.buf.Write(b[:2])
+	} else if float64(float32(f)) == f {
+		w.buf.WriteByte(cborFloat32)
+		binary.BigEndian.PutUint32(b[:], math.Float32bits(float32(f)))
+		w.buf.Write(b[:4])
+	} else {
+		w.buf.WriteByte(cborFloat64)
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+		w.buf.Write(b[:])
+	}
+	return nil
+}
+
+// Parse a CBOR (RFC 8949) data item.
+//
+// Integers are JsonNumber with the exact value, including bignums (tags 2 and 3). Floats are JsonNumber
+// with a fraction or exponent so they are written back as floats. Byte strings are a JsonString of the
+// base64 of the bytes (see BinaryOptions). Map keys must be strings or integers. Other tags are ignored
+// and undefined is JsonNull. Errors are a *ParseError with the Offset of the data item.
+func ParseCBOR(data []byte, options BinaryOptions) (NodeI, error) {
+	r := &cborReader{data: data, options: options}
+	n, err := r.value("")
+	if err != nil {
+		return nil, err
+	}
+	if r.pos < len(r.data) {
+		return nil, r.error(r.pos, "unexpected data after the value")
+	}
+	return n, nil
+}
+
+type cborReader struct {
+	data    []byte
+	pos     int
+	depth   int
+	options BinaryOptions
+}
+
+func (r *cborReader) error(offset int, format string, args ...interface{}) *ParseError {
+	return &ParseError{Msg: fmt.Sprintf(format, args...), Offset: offset, Binary: true}
+}
+
+func (r *cborReader) take(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, r.error(len(r.data), "unexpected end of the data")
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// Read the initial byte and the argument of a data item
+func (r *cborReader) head() (byte, byte, uint64, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		arg, err := r.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var v uint64
+		for _, c := range arg {
+			v = v<<8 | uint64(c)
+		}
+		return major, info, v, nil
+	case info == cborIndef && major >= cborBytes && major <= cborMap:
+		return major, info, 0, nil
+	case info == cborIndef && major == cborSimple:
+		return 0, 0, 0, r.error(r.pos-1, "unexpected break")
+	}
+	return 0, 0, 0, r.error(r.pos-1, "invalid additional information %d for major type %d", info, major)
+}
+
+// Return true and move past the break if the next byte is a break
+func (r *cborReader) isBreak() (bool, error) {
+	if r.pos >= len(r.data) {
+		return false, r.error(r.pos, "unexpected end of the data")
+	}
+	if r.data[r.pos] == cborBreak {
+		r.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+// Read a byte or text string. Indefinite length strings are a list of definite length chunks.
+func (r *cborReader) chunks(major, info byte, arg uint64) ([]byte, error) {
+	if info != cborIndef {
+		return r.take(arg)
+	}
+	var b []byte
+	for {
+		brk, err := r.isBreak()
+		if err != nil || brk {
+			return b, err
+		}
+		start := r.pos
+		m, i, a, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || i == cborIndef {
+			return nil, r.error(start, "a chunk of an indefinite length string must be a definite length string of the same type")
+		}
+		c, err := r.take(a)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, c...)
+	}
+}
+
+func (r *cborReader) value(name string) (NodeI, error) {
+	start := r.pos
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > BINARY_MAX_DEPTH {
+		return nil, r.error(start, "the data is nested more than %d levels", BINARY_MAX_DEPTH)
+	}
+	major, info, arg, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return NewJsonNumberFromLiteral(name, strconv.FormatUint(arg, 10))
+	case cborNegInt:
+		i := new(big.Int).SetUint64(arg)
+		return NewJsonNumberFromLiteral(name, i.Sub(big.NewInt(-1), i).String())
+	case cborBytes:
+		b, err := r.chunks(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		return binaryBytes(name, b, r.options), nil
+	case cborText:
+		b, err := r.chunks(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(b) {
+			return nil, r.error(start, "a text string is not valid utf-8")
+		}
+		return NewJsonString(name, string(b)), nil
+	case cborArray:
+		return r.array(name, info, arg)
+	case cborMap:
+		return r.object(name, info, arg)
+	case cborTag:
+		if arg != cborPosBig && arg != cborNegBig {
+			return r.value(name)
+		}
+		m, i, a, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != cborBytes {
+			return nil, r.error(start, "a bignum must be a byte string")
+		}
+		b, err := r.chunks(m, i, a)
+		if err != nil {
+			return nil, err
+		}
+		n := new(big.Int).SetBytes(b)
+		if arg == cborNegBig {
+			n.Sub(big.NewInt(-1), n)
+		}
+		return NewJsonNumberFromLiteral(name, n.String())
+	}
+	var f float64
+	switch info {
+	case 20, 21:
+		return NewJsonBool(name, info == 21), nil
+	case 22, 23:
+		return NewJsonNull(name), nil
+	case 25:
+		f = float16Value(uint16(arg))
+	case 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case 27:
+		f = math.Float64frombits(arg)
+	default:
+		return nil, r.error(start, "the simple value %d is not supported", arg)
+	}
+	n, err := binaryFloat(name, f)
+	if err != nil {
+		return nil, r.error(start, "%s", err.Error())
+	}
+	return n, nil
+}
+
+// Return true if there is another item in an array or map of count items (or indefinite length)
+func (r *cborReader) more(info byte, count uint64, i uint64) (bool, error) {
+	if info == cborIndef {
+		brk, err := r.isBreak()
+		return !brk, err
+	}
+	return i < count, nil
+}
+
+func (r *cborReader) array(name string, info byte, count uint64) (NodeI, error) {
+	// Every item is at least one byte
+	if info != cborIndef && count > uint64(len(r.data)-r.pos) {
+		return nil, r.error(len(r.data), "unexpected end of the data")
+	}
+	list := NewJsonList(name)
+	for i := uint64(0); ; i++ {
+		more, err := r.more(info, count, i)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return list, nil
+		}
+		n, err := r.value("")
+		if err != nil {
+			return nil, err
+		}
+		list.Add(n)
+	}
+}
+
+func (r *cborReader) object(name string, info byte, count uint64) (NodeI, error) {
+	if info != cborIndef && count > uint64(len(r.data)-r.pos)/2 {
+		return nil, r.error(len(r.data), "unexpected end of the data")
+	}
+	obj := NewJsonObject(name)
+	for i := uint64(0); ; i++ {
+		more, err := r.more(info, count, i)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return obj, nil
+		}
+		start := r.pos
+		k, err := r.value("")
+		if err != nil {
+			return nil, err
+		}
+		key, err := binaryKey(k)
+		if err != nil {
+			return nil, r.error(start, "%s", err.Error())
+		}
+		if obj.GetNodeWithName(key) != nil {
+			return nil, r.error(start, "duplicate map key '%s'", key)
+		}
+		n, err := r.value(key)
+		if err != nil {
+			return nil, err
+		}
+		obj.Add(n)
+	}
+}
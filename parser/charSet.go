@@ -18,6 +18,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 )
 
 type TokenType uint16
@@ -34,6 +35,7 @@ const (
 	TT_NUMBER        TokenType = iota
 	TT_COLON         TokenType = iota
 	TT_NULL          TokenType = iota
+	TT_UNKNOWN       TokenType = iota
 )
 
 var (
@@ -218,6 +220,9 @@ var (
 
 func Padding(tab, indent int, useIndent int) string {
 	if useIndent == 0 && tab > 0 {
+		if tab*indent > len(padding) {
+			return "\n" + strings.Repeat(" ", tab*indent)
+		}
 		return "\n" + padding[:tab*indent]
 	}
 	return ""
@@ -253,6 +258,20 @@ func HexCharToInt(c byte) uint16 {
 	return hexDigits[c-'0'] // return value
 }
 
+// Same as HexCharToInt but returns false instead of a panic if c is not a hex digit
+func hexValue(c byte) (uint16, bool) {
+	if c >= 'a' && c <= 'f' {
+		return uint16(c-'a') + 10, true
+	}
+	if c >= 'A' && c <= 'F' {
+		return uint16(c-'A') + 10, true
+	}
+	if c >= '0' && c <= '9' {
+		return uint16(c - '0'), true
+	}
+	return 0, false
+}
+
 func GetTokenTypeName(tt TokenType) string {
 	switch tt {
 	case TT_ARRAY_OPEN:
@@ -0,0 +1,306 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type CSVQuote int
+
+const (
+	CSV_QUOTE_NEEDED  CSVQuote = iota // Quote fields that contain the delimiter, a quote, a line break or leading space
+	CSV_QUOTE_ALL                     // Quote every field
+	CSV_QUOTE_STRINGS                 // Quote the column names and fields that are a JsonString
+)
+
+// Options for converting between csv and a list of objects. The zero value uses the defaults.
+type CSVOptions struct {
+	Delimiter   rune     // The field delimiter. Default ','. Use '\t' for TSV
+	Quote       CSVQuote // Which fields CSVValue quotes
+	PathDelim   string   // Separates the names in a column name for a nested value. Default "."
+	Columns     []string // The columns written by CSVValue in order. Default is every column found in the rows
+	Flat        bool     // ParseCSV uses the column names as keys and does not create nested objects and lists
+	NoInference bool     // ParseCSV returns every value as a JsonString
+}
+
+func (o CSVOptions) withDefaults() CSVOptions {
+	if o.Delimiter == 0 {
+		o.Delimiter = ','
+	}
+	if o.PathDelim == "" {
+		o.PathDelim = "."
+	}
+	return o
+}
+
+// Return a list of objects as csv with a row of column names.
+//
+// The columns are the names found in all of the rows in the order they are first found. A nested
+// value is in a column named by its path from the row, for example "address.city" or "tags.0".
+// An empty object or list is written as {} or [] and a JsonNull is an empty field.
+func CSVValue(n NodeI, options CSVOptions) (string, error) {
+	o := options.withDefaults()
+	list, ok := n.(*JsonList)
+	if !ok {
+		return "", fmt.Errorf("csv needs a list of objects not a %s", GetNodeTypeName(n.GetNodeType()))
+	}
+	columns := o.Columns
+	rows := make([]map[string]NodeI, 0, list.Len())
+	found := make(map[string]bool)
+	for i, v := range list.GetValues() {
+		obj, ok := v.(*JsonObject)
+		if !ok {
+			return "", fmt.Errorf("the value at '/%d' is a %s not an object", i, GetNodeTypeName(v.GetNodeType()))
+		}
+		row := make(map[string]NodeI)
+		for _, f := range csvFlatten(obj, parserEmptyPath, o.PathDelim, nil) {
+			row[f.name] = f.node
+			if options.Columns == nil && !found[f.name] {
+				found[f.name] = true
+				columns = append(columns, f.name)
+			}
+		}
+		rows = append(rows, row)
+	}
+	var sb strings.Builder
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = csvField(c, o.Quote != CSV_QUOTE_NEEDED, o.Delimiter)
+	}
+	sb.WriteString(strings.Join(header, string(o.Delimiter)))
+	sb.WriteByte('\n')
+	fields := make([]string, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			fields[i] = csvField("", o.Quote == CSV_QUOTE_ALL, o.Delimiter)
+			if v, ok := row[c]; ok {
+				fields[i] = csvField(csvText(v), o.Quote == CSV_QUOTE_ALL || (o.Quote == CSV_QUOTE_STRINGS && v.GetNodeType() == NT_STRING), o.Delimiter)
+			}
+		}
+		sb.WriteString(strings.Join(fields, string(o.Delimiter)))
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
+
+type csvColumn struct {
+	name string
+	node NodeI
+}
+
+// Return the leaf values of a container with their column names
+func csvFlatten(n NodeC, path []string, delim string, cols []csvColumn) []csvColumn {
+	add := func(name string, v NodeI) {
+		p := jpAppend(path, name)
+		if c, ok := v.(NodeC); ok && c.Len() > 0 {
+			cols = csvFlatten(c, p, delim, cols)
+		} else {
+			cols = append(cols, csvColumn{name: (&Path{path: p, delim: delim}).String(), node: v})
+		}
+	}
+	switch c := n.(type) {
+	case *JsonObject:
+		for _, k := range c.keys {
+			add(k, *c.value[k])
+		}
+	case *JsonList:
+		for i, v := range c.GetValues() {
+			add(strconv.Itoa(i), v)
+		}
+	}
+	return cols
+}
+
+func csvText(n NodeI) string {
+	switch n.GetNodeType() {
+	case NT_NULL:
+		return ""
+	case NT_OBJECT:
+		return "{}"
+	case NT_LIST:
+		return "[]"
+	}
+	return n.String()
+}
+
+func csvField(s string, quote bool, delim rune) string {
+	if !quote && s != "" && (strings.ContainsAny(s, "\"\r\n"+string(delim)) || s[0] == ' ') {
+		quote = true
+	}
+	if !quote {
+		return s
+	}
+	return "\"" + strings.ReplaceAll(s, "\"", "\"\"") + "\""
+}
+
+// Parse csv with a row of column names. Return a JsonList with a JsonObject for each row.
+//
+// A column name that is a path, for example "address.city", is a value in a nested object.
+// If the names in an object are 0, 1, 2... it is a JsonList. Empty fields are JsonNull.
+// A nested object or list is left out if all of its fields are empty. An empty string, a null and a
+// missing value are all written as an empty field so they are all JsonNull when read back.
+// Fields that are json numbers (without leading zeros), true or false are a JsonNumber or a JsonBool.
+// Other fields are a JsonString.
+func ParseCSV(data []byte, options CSVOptions) (NodeC, error) {
+	return ParseCSVReader(bytes.NewReader(data), options)
+}
+
+// Parse csv as it is read from r.
+func ParseCSVReader(r io.Reader, options CSVOptions) (NodeC, error) {
+	o := options.withDefaults()
+	cr := csv.NewReader(r)
+	cr.Comma = o.Delimiter
+	header, err := cr.Read()
+	if err == io.EOF {
+		return NewJsonList(""), nil
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	paths := make([][]string, len(header))
+	for i, h := range header {
+		if o.Flat {
+			paths[i] = []string{h}
+		} else {
+			paths[i] = strings.Split(h, o.PathDelim)
+		}
+	}
+	list := NewJsonList("")
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		row := NewJsonObject("")
+		empty := make(map[NodeI]bool)
+		for i, f := range record {
+			v := csvValue(f, o.NoInference)
+			if f == "" {
+				empty[v] = true
+			}
+			if err := csvAdd(row, paths[i], v); err != nil {
+				line, column := cr.FieldPos(i)
+				return nil, &ParseError{Msg: fmt.Sprintf("the column '%s' %s", header[i], err.Error()), Line: line, Column: column}
+			}
+		}
+		list.Add(csvLists(row, empty, false))
+	}
+}
+
+func csvError(err error) error {
+	var ce *csv.ParseError
+	if errors.As(err, &ce) {
+		return &ParseError{Msg: ce.Err.Error(), Line: ce.Line, Column: ce.Column, Err: err}
+	}
+	return &ParseError{Msg: fmt.Sprintf("failed to read input. %s", err.Error()), Err: err}
+}
+
+func csvValue(f string, noInference bool) NodeI {
+	switch {
+	case noInference:
+		return NewJsonString("", f)
+	case f == "":
+		return NewJsonNull("")
+	case f == "true" || f == "false":
+		return NewJsonBool("", f == "true")
+	}
+	// Codes such as 007 keep their leading zeros
+	digits := strings.TrimPrefix(f, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		return NewJsonString("", f)
+	}
+	if n, err := NewJsonNumberFromLiteral("", f); err == nil {
+		return n
+	}
+	return NewJsonString("", f)
+}
+
+// Add a value to the row creating the objects in the path
+func csvAdd(row *JsonObject, path []string, v NodeI) error {
+	obj := row
+	for i, name := range path {
+		existing := obj.GetNodeWithName(name)
+		if i == len(path)-1 {
+			if existing != nil {
+				return fmt.Errorf("is repeated or is also a nested value")
+			}
+			v.setName(name)
+			_, err := obj.Add(v)
+			return err
+		}
+		if existing == nil {
+			existing = NewJsonObject(name)
+			if _, err := obj.Add(existing); err != nil {
+				return err
+			}
+		}
+		next, ok := existing.(*JsonObject)
+		if !ok {
+			return fmt.Errorf("is inside a value that is not an object")
+		}
+		obj = next
+	}
+	return nil
+}
+
+// Return the node with objects that have the names 0, 1, 2... replaced by lists.
+// A nested object that only holds empty fields is nil so missing values do not create objects.
+func csvLists(n NodeI, empty map[NodeI]bool, nested bool) NodeI {
+	obj, ok := n.(*JsonObject)
+	if !ok {
+		return n
+	}
+	isList := obj.Len() > 0
+	for i, k := range obj.keys {
+		if k != strconv.Itoa(i) {
+			isList = false
+		}
+	}
+	var c NodeC = NewJsonObject(obj.GetName())
+	if isList {
+		c = NewJsonList(obj.GetName())
+	}
+	allEmpty := true
+	for _, k := range obj.keys {
+		v := csvLists(*obj.value[k], empty, true)
+		if v == nil {
+			continue
+		}
+		if !empty[v] {
+			allEmpty = false
+		}
+		v.setParent(nil)
+		if isList {
+			v.setName("")
+		}
+		c.Add(v)
+	}
+	if nested && allEmpty {
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,307 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ChangeType int
+
+// The largest table used to match the longest common sequence of two lists
+const DIFF_MAX_LCS = 1000000
+
+const (
+	CHANGE_ADDED   ChangeType = iota // The node is in b but not in a
+	CHANGE_REMOVED                   // The node is in a but not in b
+	CHANGE_CHANGED                   // The node is in a and b but the value is different
+	CHANGE_ORDER                     // The object keys or list values are in a different order
+	CHANGE_TYPE                      // The node is in a and b but the type is different
+)
+
+var changeTypeNames = []string{"added", "removed", "changed", "order", "changed type"}
+
+func (ct ChangeType) String() string {
+	return changeTypeNames[ct]
+}
+
+// A single difference between two trees. From is the node in a and To is the node in b.
+// From is nil for CHANGE_ADDED and To is nil for CHANGE_REMOVED.
+// Path is the path in a. For CHANGE_ADDED the last element of Path is the name or index in b.
+type Change struct {
+	Type ChangeType
+	Path *Path
+	From NodeI
+	To   NodeI
+}
+
+type Changes []*Change
+
+type DiffOptions struct {
+	IgnoreKeyOrder  bool   // Do not report objects with the same keys in a different order
+	IgnoreListOrder bool   // Match list values anywhere in the list
+	IdentityKey     string // Match objects in lists that have the same value for this key
+}
+
+func (c *Change) String() string {
+	switch c.Type {
+	case CHANGE_ADDED:
+		return fmt.Sprintf("added %s: %s", c.Path.Pointer(), valueJson(c.To))
+	case CHANGE_REMOVED:
+		return fmt.Sprintf("removed %s: %s", c.Path.Pointer(), valueJson(c.From))
+	}
+	return fmt.Sprintf("%s %s: %s -> %s", c.Type, c.Path.Pointer(), valueJson(c.From), valueJson(c.To))
+}
+
+// Return the change for a path or nil if the path did not change
+func (c Changes) Get(path *Path) *Change {
+	for _, v := range c {
+		if v.Path.Equal(path) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (c Changes) String() string {
+	var sb strings.Builder
+	for _, v := range c {
+		sb.WriteString(v.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Render the changes in the style of a unified diff. Each change has a header with the
+// json pointer to the node, followed by the old value prefixed with '-' and the new value prefixed with '+'.
+func (c Changes) Unified(nameA, nameB string) string {
+	var sb strings.Builder
+	if len(c) == 0 {
+		return sb.String()
+	}
+	sb.WriteString("--- " + nameA + "\n")
+	sb.WriteString("+++ " + nameB + "\n")
+	for _, v := range c {
+		sb.WriteString(fmt.Sprintf("@@ %s @@", v.Path.Pointer()))
+		if v.Type != CHANGE_CHANGED {
+			sb.WriteString(" " + v.Type.String())
+		}
+		sb.WriteByte('\n')
+		if v.From != nil {
+			sb.WriteString("-" + valueJson(v.From) + "\n")
+		}
+		if v.To != nil {
+			sb.WriteString("+" + valueJson(v.To) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// Return the differences between a and b. List values are compared by position
+// after the longest common sequence of equal values is matched.
+func Diff(a, b NodeI) Changes {
+	return DiffWithOptions(a, b, DiffOptions{})
+}
+
+// Return the differences between a and b using the options.
+func DiffWithOptions(a, b NodeI, options DiffOptions) Changes {
+	d := &differ{options: options, changes: make(Changes, 0)}
+	d.diff(a, b, make([]string, 0))
+	return d.changes
+}
+
+type differ struct {
+	options DiffOptions
+	changes Changes
+}
+
+func (d *differ) add(ct ChangeType, path []string, from, to NodeI) {
+	if len(path) == 0 {
+		path = parserEmptyPath
+	}
+	d.changes = append(d.changes, &Change{Type: ct, Path: &Path{path: path, delim: "."}, From: from, To: to})
+}
+
+func (d *differ) diff(a, b NodeI, path []string) {
+	if a.GetNodeType() != b.GetNodeType() {
+		d.add(CHANGE_TYPE, path, a, b)
+		return
+	}
+	switch av := a.(type) {
+	case *JsonObject:
+		d.diffObjects(av, b.(*JsonObject), path)
+	case *JsonList:
+		d.diffLists(av, b.(*JsonList), path)
+	default:
+		if !EqualValues(a, b) {
+			d.add(CHANGE_CHANGED, path, a, b)
+		}
+	}
+}
+
+func (d *differ) diffObjects(a, b *JsonObject, path []string) {
+	common := make([]string, 0)
+	for _, k := range a.keys {
+		if bv, ok := b.value[k]; ok {
+			common = append(common, k)
+			d.diff(*a.value[k], *bv, jpAppend(path, k))
+		} else {
+			d.add(CHANGE_REMOVED, jpAppend(path, k), *a.value[k], nil)
+		}
+	}
+	for _, k := range b.keys {
+		if _, ok := a.value[k]; !ok {
+			d.add(CHANGE_ADDED, jpAppend(path, k), nil, *b.value[k])
+		}
+	}
+	if !d.options.IgnoreKeyOrder {
+		i := 0
+		for _, k := range b.keys {
+			if _, ok := a.value[k]; ok {
+				if common[i] != k {
+					d.add(CHANGE_ORDER, path, a, b)
+					return
+				}
+				i++
+			}
+		}
+	}
+}
+
+// Nodes in lists are equal if the values and the names are equal
+func diffEqual(a, b NodeI) bool {
+	return a.GetName() == b.GetName() && EqualValues(a, b)
+}
+
+func (d *differ) diffLists(a, b *JsonList, path []string) {
+	av := a.GetValues()
+	bv := b.GetValues()
+	if d.options.IdentityKey == "" && !d.options.IgnoreListOrder {
+		d.diffSequence(av, bv, path)
+		return
+	}
+	match := make([]int, len(av))
+	used := make([]bool, len(bv))
+	for i := range av {
+		match[i] = -1
+		if d.options.IdentityKey != "" {
+			if j := indexOfKeyValue(b, av[i], d.options.IdentityKey); j >= 0 && !used[j] {
+				match[i] = j
+				used[j] = true
+			}
+		}
+	}
+	for i := range av {
+		for j := 0; match[i] < 0 && j < len(bv); j++ {
+			if !used[j] && diffEqual(av[i], bv[j]) {
+				match[i] = j
+				used[j] = true
+			}
+		}
+	}
+	last := -1
+	ordered := true
+	for i, j := range match {
+		if j < 0 {
+			d.add(CHANGE_REMOVED, jpAppend(path, strconv.Itoa(i)), av[i], nil)
+			continue
+		}
+		if j < last {
+			ordered = false
+		}
+		last = j
+		d.diff(av[i], bv[j], jpAppend(path, strconv.Itoa(i)))
+	}
+	for j, u := range used {
+		if !u {
+			d.add(CHANGE_ADDED, jpAppend(path, strconv.Itoa(j)), nil, bv[j])
+		}
+	}
+	if !ordered && !d.options.IgnoreListOrder {
+		d.add(CHANGE_ORDER, path, a, b)
+	}
+}
+
+// Match the longest common sequence of equal values. The values between the matches
+// are compared by position. Any left over are removed from a or added from b.
+// Equal values at the start and end are matched first. If the table for the rest would
+// have more than DIFF_MAX_LCS cells the rest is compared by position.
+func (d *differ) diffSequence(av, bv []NodeI, path []string) {
+	lo, hiA, hiB := 0, len(av), len(bv)
+	for lo < hiA && lo < hiB && diffEqual(av[lo], bv[lo]) {
+		lo++
+	}
+	for hiA > lo && hiB > lo && diffEqual(av[hiA-1], bv[hiB-1]) {
+		hiA--
+		hiB--
+	}
+	n, m := hiA-lo, hiB-lo
+	if n == 0 || m == 0 || n*m > DIFF_MAX_LCS {
+		d.diffGap(av, bv, lo, hiA, lo, hiB, path)
+		return
+	}
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if diffEqual(av[lo+i], bv[lo+j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	gapI, gapJ := 0, 0
+	for i < n || j < m {
+		if i < n && j < m && diffEqual(av[lo+i], bv[lo+j]) {
+			d.diffGap(av, bv, lo+gapI, lo+i, lo+gapJ, lo+j, path)
+			i++
+			j++
+			gapI, gapJ = i, j
+		} else if j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]) {
+			i++
+		} else {
+			j++
+		}
+	}
+	d.diffGap(av, bv, lo+gapI, hiA, lo+gapJ, hiB, path)
+}
+
+func (d *differ) diffGap(av, bv []NodeI, i0, i1, j0, j1 int, path []string) {
+	for k := 0; i0+k < i1 || j0+k < j1; k++ {
+		i, j := i0+k, j0+k
+		switch {
+		case i < i1 && j < j1:
+			if av[i].GetName() != bv[j].GetName() {
+				d.add(CHANGE_CHANGED, jpAppend(path, strconv.Itoa(i)), av[i], bv[j])
+			} else {
+				d.diff(av[i], bv[j], jpAppend(path, strconv.Itoa(i)))
+			}
+		case i < i1:
+			d.add(CHANGE_REMOVED, jpAppend(path, strconv.Itoa(i)), av[i], nil)
+		default:
+			d.add(CHANGE_ADDED, jpAppend(path, strconv.Itoa(j)), nil, bv[j])
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type GoStructOptions struct {
+	PackageName string // If not "" a package clause is written
+	RootName    string // The name of the root type. "Root" is used if ""
+}
+
+// Go names that are written in upper case
+var goInitialisms = map[string]bool{"api": true, "html": true, "http": true, "https": true, "id": true, "ip": true,
+	"json": true, "uid": true, "uri": true, "url": true, "uuid": true, "xml": true}
+
+// Return Go type definitions for the samples. Objects are structs with json tags.
+// The values of all lists and all samples are merged so a struct has every key found.
+// A key that is not in every object has the omitempty option. A value that is null
+// in any sample is a pointer. Numbers are int64 unless a value has a fraction.
+// Values with different types are interface{}. The result is formatted with go/format.
+func GoStructs(options GoStructOptions, samples ...NodeI) (string, error) {
+	shape := newInferShape()
+	for _, s := range samples {
+		shape.add(s, &InferOptions{})
+	}
+	g := &goStructGen{names: make(map[string]bool), defs: make([]string, 0)}
+	root := options.RootName
+	if root == "" {
+		root = "Root"
+	}
+	root = goName(root)
+	g.names[root] = true
+	var sb strings.Builder
+	if options.PackageName != "" {
+		sb.WriteString("package " + options.PackageName + "\n\n")
+	}
+	if shape.objects > 0 && g.singleType(shape) == "object" {
+		g.writeStruct(shape, root)
+	} else {
+		g.defs = append(g.defs, fmt.Sprintf("type %s %s\n", root, g.typeOf(shape, root, root, false)))
+		// The root type must be first
+		g.defs = append(g.defs[len(g.defs)-1:], g.defs[:len(g.defs)-1]...)
+	}
+	sb.WriteString(strings.Join(g.defs, "\n"))
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("generated code is not valid. %s", err.Error())
+	}
+	return string(src), nil
+}
+
+type goStructGen struct {
+	names map[string]bool // Type names used
+	defs  []string
+}
+
+// Return the only type of the shape ignoring null. Integers are numbers if both are found.
+// Return "" if there is more than one type or only null.
+func (g *goStructGen) singleType(s *inferShape) string {
+	t := ""
+	for _, v := range s.types {
+		if v == "integer" && indexOf(s.types, "number") >= 0 {
+			continue
+		}
+		if v != "null" {
+			if t != "" {
+				return ""
+			}
+			t = v
+		}
+	}
+	return t
+}
+
+// Return the Go type for a shape. name is the name to use for a struct.
+// Structs are pointers if they are optional or null.
+func (g *goStructGen) typeOf(s *inferShape, name, parent string, optional bool) string {
+	t := ""
+	switch g.singleType(s) {
+	case "object":
+		t = g.writeStruct(s, g.typeName(name, parent))
+		if optional {
+			return "*" + t
+		}
+	case "array":
+		if s.items == nil || len(s.items.types) == 0 {
+			return "[]interface{}"
+		}
+		return "[]" + g.typeOf(s.items, goSingular(name), parent, false)
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	default:
+		return "interface{}"
+	}
+	if indexOf(s.types, "null") >= 0 {
+		return "*" + t
+	}
+	return t
+}
+
+// Return a type name not already used. The parent name is added to the front if the name is used.
+func (g *goStructGen) typeName(name, parent string) string {
+	n := goName(name)
+	if g.names[n] {
+		n = parent + n
+	}
+	base := n
+	for i := 2; g.names[n]; i++ {
+		n = base + strconv.Itoa(i)
+	}
+	g.names[n] = true
+	return n
+}
+
+// Add the struct definition and return the name
+func (g *goStructGen) writeStruct(s *inferShape, name string) string {
+	i := len(g.defs)
+	g.defs = append(g.defs, "")
+	var sb strings.Builder
+	sb.WriteString("type " + name + " struct {\n")
+	fields := make(map[string]bool)
+	for _, k := range s.keys {
+		p := s.properties[k]
+		f := goName(k)
+		base := f
+		for j := 2; fields[f]; j++ {
+			f = base + strconv.Itoa(j)
+		}
+		fields[f] = true
+		tag := k
+		optional := p.total < s.objects
+		if optional {
+			tag = tag + ",omitempty"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s `json:%s`\n", f, g.typeOf(p, k, name, optional), strconv.Quote(tag)))
+	}
+	sb.WriteString("}\n")
+	g.defs[i] = sb.String()
+	return name
+}
+
+// Return an exported Go name for a json key. For example "first_name" is "FirstName" and "user-id" is "UserID"
+func goName(key string) string {
+	var sb strings.Builder
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if goInitialisms[strings.ToLower(w)] {
+			sb.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		sb.WriteRune(unicode.ToUpper(r[0]))
+		sb.WriteString(string(r[1:]))
+	}
+	n := sb.String()
+	if n == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(n)[0]) {
+		return "X" + n
+	}
+	return n
+}
+
+// Return the name of one item in a list. For example "phoneNumbers" is "phoneNumber"
+func goSingular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "ss"):
+		return name
+	case strings.HasSuffix(name, "s") && len(name) > 3:
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
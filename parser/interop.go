@@ -0,0 +1,230 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var (
+	_ json.Marshaler   = (*JsonObject)(nil)
+	_ json.Unmarshaler = (*JsonObject)(nil)
+	_ json.Marshaler   = (*JsonList)(nil)
+	_ json.Unmarshaler = (*JsonList)(nil)
+	_ json.Marshaler   = (*JsonString)(nil)
+	_ json.Unmarshaler = (*JsonString)(nil)
+	_ json.Marshaler   = (*JsonNumber)(nil)
+	_ json.Unmarshaler = (*JsonNumber)(nil)
+	_ json.Marshaler   = (*JsonBool)(nil)
+	_ json.Unmarshaler = (*JsonBool)(nil)
+	_ json.Marshaler   = (*JsonNull)(nil)
+	_ json.Unmarshaler = (*JsonNull)(nil)
+)
+
+// Create a node tree from the values used by encoding/json. These are map[string]interface{}, []interface{},
+// float64, string, bool, nil, json.Number and json.RawMessage. Map keys are sorted.
+// Any other value is converted using Marshal.
+func FromInterface(v interface{}) (NodeI, error) {
+	return fromInterface(v, "")
+}
+
+func fromInterface(v interface{}, name string) (NodeI, error) {
+	switch t := v.(type) {
+	case nil:
+		return NewJsonNull(name), nil
+	case bool:
+		return NewJsonBool(name, t), nil
+	case string:
+		return NewJsonString(name, t), nil
+	case float64:
+		return NewJsonNumber(name, t), nil
+	case json.Number:
+		return NewJsonNumberFromLiteral(name, string(t))
+	case json.RawMessage:
+		return marshalJson(t, name)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		obj := NewJsonObject(name)
+		for _, k := range keys {
+			n, err := fromInterface(t[k], k)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := obj.Add(n); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case []interface{}:
+		list := NewJsonList(name)
+		for _, e := range t {
+			n, err := fromInterface(e, "")
+			if err != nil {
+				return nil, err
+			}
+			list.Add(n)
+		}
+		return list, nil
+	}
+	return marshalValue(reflect.ValueOf(v), name, false)
+}
+
+// Return the node as the values used by encoding/json. Objects are map[string]interface{}, lists are []interface{},
+// numbers are float64, strings are string, booleans are bool and null is nil.
+func ToInterface(node NodeI) interface{} {
+	switch n := node.(type) {
+	case *JsonObject:
+		m := make(map[string]interface{}, n.Len())
+		for _, k := range n.keys {
+			m[k] = ToInterface(*n.value[k])
+		}
+		return m
+	case *JsonList:
+		l := make([]interface{}, 0, n.Len())
+		for _, v := range n.GetValues() {
+			l = append(l, ToInterface(v))
+		}
+		return l
+	case *JsonNumber:
+		return n.GetValue()
+	case *JsonString:
+		return n.GetValue()
+	case *JsonBool:
+		return n.GetValue()
+	}
+	return nil
+}
+
+// Return the json value of a node without its name
+func valueJson(n NodeI) string {
+	return stringNamedTabIndent(n, "", 0, 0, INDENT_OFF)
+}
+
+// Parse data and check that the root node is the required type
+func unmarshalJsonAs(data []byte, nt NodeType) (NodeI, error) {
+	n, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if n.GetNodeType() != nt {
+		return nil, fmt.Errorf("cannot unmarshal %s into a %s node", GetNodeTypeName(n.GetNodeType()), GetNodeTypeName(nt))
+	}
+	return n, nil
+}
+
+// The json value of the object. The name is not included.
+func (n *JsonObject) MarshalJSON() ([]byte, error) {
+	return []byte(valueJson(n)), nil
+}
+
+// Replace the content of the object with the parsed json object. The name is not changed.
+func (n *JsonObject) UnmarshalJSON(data []byte) error {
+	p, err := unmarshalJsonAs(data, NT_OBJECT)
+	if err != nil {
+		return err
+	}
+	o := p.(*JsonObject)
+	n.nt = NT_OBJECT
+	n.value = o.value
+	n.keys = o.keys
+	for _, v := range n.value {
+		(*v).setParent(n)
+	}
+	return nil
+}
+
+// The json value of the list. The name is not included.
+func (n *JsonList) MarshalJSON() ([]byte, error) {
+	return []byte(valueJson(n)), nil
+}
+
+// Replace the content of the list with the parsed json list. The name is not changed.
+func (n *JsonList) UnmarshalJSON(data []byte) error {
+	p, err := unmarshalJsonAs(data, NT_LIST)
+	if err != nil {
+		return err
+	}
+	n.nt = NT_LIST
+	n.value = p.(*JsonList).value
+	for _, v := range n.value {
+		(*v).setParent(n)
+	}
+	return nil
+}
+
+func (n *JsonString) MarshalJSON() ([]byte, error) {
+	return []byte(valueJson(n)), nil
+}
+
+func (n *JsonString) UnmarshalJSON(data []byte) error {
+	p, err := unmarshalJsonAs(data, NT_STRING)
+	if err != nil {
+		return err
+	}
+	n.nt = NT_STRING
+	n.value = p.(*JsonString).value
+	return nil
+}
+
+func (n *JsonNumber) MarshalJSON() ([]byte, error) {
+	return []byte(n.GetLiteral()), nil
+}
+
+func (n *JsonNumber) UnmarshalJSON(data []byte) error {
+	p, err := unmarshalJsonAs(data, NT_NUMBER)
+	if err != nil {
+		return err
+	}
+	n.nt = NT_NUMBER
+	n.value = p.(*JsonNumber).value
+	n.literal = p.(*JsonNumber).literal
+	return nil
+}
+
+func (n *JsonBool) MarshalJSON() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+func (n *JsonBool) UnmarshalJSON(data []byte) error {
+	p, err := unmarshalJsonAs(data, NT_BOOL)
+	if err != nil {
+		return err
+	}
+	n.nt = NT_BOOL
+	n.value = p.(*JsonBool).value
+	return nil
+}
+
+func (n *JsonNull) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+func (n *JsonNull) UnmarshalJSON(data []byte) error {
+	_, err := unmarshalJsonAs(data, NT_NULL)
+	if err != nil {
+		return err
+	}
+	n.nt = NT_NULL
+	return nil
+}
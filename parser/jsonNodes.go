@@ -17,8 +17,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -110,10 +114,13 @@ func (n *jsonParentNode) IsContainer() bool {
 
 //
 // Objects node is a ParentNode and a value of type map[string]*NodeI
+// The keys are held in the order they were added. This is the order used
+// by GetKeys, GetValues and when the object is rendered as json.
 //
 type JsonObject struct {
 	jsonParentNode
 	value map[string]*NodeI
+	keys  []string
 }
 
 func NewJsonType(name string, nodeType NodeType) NodeI {
@@ -135,7 +142,7 @@ func NewJsonType(name string, nodeType NodeType) NodeI {
 }
 
 func NewJsonObject(name string) *JsonObject {
-	return &JsonObject{jsonParentNode: NewJsonParentNode(name, NT_OBJECT), value: make(map[string]*NodeI)}
+	return &JsonObject{jsonParentNode: NewJsonParentNode(name, NT_OBJECT), value: make(map[string]*NodeI), keys: make([]string, 0)}
 }
 
 func (n *JsonObject) GetNodeWithName(name string) NodeI {
@@ -146,23 +153,83 @@ func (n *JsonObject) GetNodeWithName(name string) NodeI {
 	return *v
 }
 
+// Return the keys in the order they were added (or moved or sorted)
+func (n *JsonObject) GetKeys() []string {
+	keys := make([]string, len(n.keys))
+	copy(keys, n.keys)
+	return keys
+}
+
 func (n *JsonObject) GetSortedKeys() []string {
-	keys := make([]string, 0, len(n.value))
-	for k := range n.value {
-		keys = append(keys, k)
-	}
+	keys := n.GetKeys()
 	sort.Strings(keys)
 	return keys
 }
 
 func (n *JsonObject) GetValues() []NodeI {
-	values := make([]NodeI, 0, len(n.value))
-	for _, v := range n.value {
-		values = append(values, *v)
+	values := make([]NodeI, 0, len(n.keys))
+	for _, k := range n.keys {
+		values = append(values, *n.value[k])
 	}
 	return values
 }
 
+// Move the node with name key so it is immediately before the node with name beforeKey
+func (n *JsonObject) MoveBefore(key, beforeKey string) error {
+	return n.move(key, beforeKey, 0)
+}
+
+// Move the node with name key so it is immediately after the node with name afterKey
+func (n *JsonObject) MoveAfter(key, afterKey string) error {
+	return n.move(key, afterKey, 1)
+}
+
+func (n *JsonObject) move(key, toKey string, offset int) error {
+	from := n.indexOfKey(key)
+	if from < 0 {
+		return fmt.Errorf("no matching node [%s] found in object node [%s]", key, n.name)
+	}
+	if n.indexOfKey(toKey) < 0 {
+		return fmt.Errorf("no matching node [%s] found in object node [%s]", toKey, n.name)
+	}
+	if key == toKey {
+		return nil
+	}
+	keys := append(n.keys[:from:from], n.keys[from+1:]...)
+	to := indexOf(keys, toKey) + offset
+	keys = append(keys[:to], append([]string{key}, keys[to:]...)...)
+	n.keys = keys
+	return nil
+}
+
+// Sort the keys a to z in place. This changes the order the nodes are rendered
+func (n *JsonObject) SortKeys() {
+	sort.Strings(n.keys)
+}
+
+// Sort the nodes in place using the less function. This changes the order the nodes are rendered
+func (n *JsonObject) SortFunc(less func(a, b NodeI) bool) {
+	sort.SliceStable(n.keys, func(i, j int) bool {
+		return less(*n.value[n.keys[i]], *n.value[n.keys[j]])
+	})
+}
+
+func (n *JsonObject) indexOfKey(key string) int {
+	if _, ok := n.value[key]; !ok {
+		return -1
+	}
+	return indexOf(n.keys, key)
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
 func (n *JsonObject) Equal(b NodeI) bool {
 	if baseEquals(n, b) {
 		l := b.(*JsonObject)
@@ -193,6 +260,7 @@ func (n *JsonObject) GetValuesSorted() []NodeI {
 
 func (n *JsonObject) Clear() {
 	n.value = make(map[string]*NodeI)
+	n.keys = make([]string, 0)
 }
 
 func (n *JsonObject) Len() int {
@@ -207,6 +275,7 @@ func (n *JsonObject) Add(node NodeI) (NodeI, error) {
 		return nil, fmt.Errorf("duplicate name [%s] in JsonObject container with name [%s]", node.GetName(), n.name)
 	}
 	n.value[node.GetName()] = &node
+	n.keys = append(n.keys, node.GetName())
 	node.setParent(n)
 	return node, nil
 }
@@ -229,9 +298,10 @@ func (n *JsonObject) Remove(nodeRemove NodeI) error {
 }
 
 func (n *JsonObject) removeFromParent(nodeRemove NodeI) error {
-	_, ok := n.value[nodeRemove.GetName()]
-	if ok {
+	i := n.indexOfKey(nodeRemove.GetName())
+	if i >= 0 {
 		delete(n.value, nodeRemove.GetName())
+		n.keys = append(n.keys[:i], n.keys[i+1:]...)
 	} else {
 		return fmt.Errorf("no matching node [%s] found in parent object node [%s]", nodeRemove.GetName(), n.name)
 	}
@@ -281,6 +351,32 @@ func (n *JsonList) Add(node NodeI) (NodeI, error) {
 	return node, nil
 }
 
+// Insert a node at index i. If i is Len() the node is added to the end of the list.
+func (n *JsonList) InsertAt(i int, node NodeI) error {
+	if i < 0 || i > len(n.value) {
+		return fmt.Errorf("index out of bounds. Range: 0..%d Actual:%d", len(n.value), i)
+	}
+	if node.GetParent() != nil {
+		return fmt.Errorf("node %s already has a parent", node.GetName())
+	}
+	n.value = append(n.value, nil)
+	copy(n.value[i+1:], n.value[i:])
+	n.value[i] = &node
+	node.setParent(n)
+	return nil
+}
+
+// Remove and return the node at index i.
+func (n *JsonList) RemoveAt(i int) (NodeI, error) {
+	if i < 0 || i >= len(n.value) {
+		return nil, fmt.Errorf("index out of bounds. Range: 0..%d Actual:%d", len(n.value)-1, i)
+	}
+	node := *n.value[i]
+	n.value = append(n.value[:i], n.value[i+1:]...)
+	node.setParent(nil)
+	return node, nil
+}
+
 func (n *JsonList) Clear() {
 	n.value = make([]*NodeI, 0)
 }
@@ -339,7 +435,7 @@ func (n *JsonList) Remove(nodeRemove NodeI) error {
 	rpp := rp.GetParent()
 	if rpp == n {
 		rp.removeFromParent(nodeRemove)
-		if rp.Len() == 0 {
+		if rp.Len() == 0 && rp.GetNodeType() == NT_OBJECT {
 			rpp.removeFromParent(rp)
 		}
 		return nil
@@ -405,37 +501,253 @@ func (n *JsonString) String() string {
 
 //
 // Number node is a ParentNode and a value of type float64
+// When parsed (or set with SetLiteral) the json text of the number is also held
+// so large integers and decimals are not changed by float64 conversion.
 //
 type JsonNumber struct {
 	jsonParentNode
-	value float64
+	value   float64
+	literal string // The number as json text. "" if the value was set from a float64
 }
 
 func NewJsonNumber(name string, value float64) *JsonNumber {
 	return &JsonNumber{jsonParentNode: NewJsonParentNode(name, NT_NUMBER), value: value}
 }
 
+// Create a JsonNumber from json number text. For example "12345678901234567890" or "1.5e-9".
+// The text is kept and returned by String() and JsonValue() until the value is changed.
+func NewJsonNumberFromLiteral(name string, literal string) (*JsonNumber, error) {
+	n := NewJsonNumber(name, 0)
+	err := n.SetLiteral(literal)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
 func (n *JsonNumber) GetValue() float64 {
 	return n.value
 }
 
+// Return the json text of the number. If the value was set from a float64
+// this is the shortest text that represents the float64.
+func (n *JsonNumber) GetLiteral() string {
+	if n.literal != "" {
+		return n.literal
+	}
+	return formatFloat(n.value)
+}
+
+// Set the value from json number text. The text is kept exactly as given.
+func (n *JsonNumber) SetLiteral(literal string) error {
+	if !IsJsonNumber(literal) {
+		return fmt.Errorf("invalid number '%s'", literal)
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("invalid number '%s'. %s", literal, err.Error())
+	}
+	n.value = f
+	n.literal = literal
+	return nil
+}
+
+// Return true if the number has no fraction. For example 10, 1.0 and 1e3 are integers.
+func (n *JsonNumber) IsInteger() bool {
+	d, ok := parseNumberDecimal(n.GetLiteral())
+	if !ok {
+		return false
+	}
+	return d.digits == "" || d.exp.Cmp(big.NewInt(int64(len(d.digits)))) >= 0
+}
+
+// Return -1, 0 or +1 if the number is less than, equal to or more than b. The json text is
+// compared exactly so numbers with exponents too large for a big.Rat are still ordered correctly.
+func (n *JsonNumber) Cmp(b *JsonNumber) int {
+	da, okA := parseNumberDecimal(n.GetLiteral())
+	db, okB := parseNumberDecimal(b.GetLiteral())
+	if (n.literal == "" && b.literal == "") || !okA || !okB {
+		switch {
+		case n.value < b.value:
+			return -1
+		case n.value > b.value:
+			return 1
+		}
+		return 0
+	}
+	return da.cmp(db)
+}
+
+// A number as sign * 0.digits * 10^exp. digits has no leading or trailing zeros so zero has no digits.
+type numberDecimal struct {
+	neg    bool
+	digits string
+	exp    *big.Int
+}
+
+// Parse json number text. Returns false if the text is not a number. For example "+Inf".
+func parseNumberDecimal(lit string) (numberDecimal, bool) {
+	d := numberDecimal{}
+	if strings.HasPrefix(lit, "-") {
+		d.neg = true
+		lit = lit[1:]
+	}
+	mant, exp := lit, "0"
+	if i := strings.IndexAny(lit, "eE"); i >= 0 {
+		mant, exp = lit[:i], strings.TrimPrefix(lit[i+1:], "+")
+	}
+	intPart, frac := mant, ""
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		intPart, frac = mant[:i], mant[i+1:]
+	}
+	digits := intPart + frac
+	e, ok := new(big.Int).SetString(exp, 10)
+	if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return d, false
+	}
+	trimmed := strings.TrimLeft(digits, "0")
+	d.exp = e.Add(e, big.NewInt(int64(len(intPart)-(len(digits)-len(trimmed)))))
+	d.digits = strings.TrimRight(trimmed, "0")
+	if d.digits == "" {
+		d.neg = false
+	}
+	return d, true
+}
+
+func (a numberDecimal) sign() int {
+	switch {
+	case a.digits == "":
+		return 0
+	case a.neg:
+		return -1
+	}
+	return 1
+}
+
+func (a numberDecimal) cmp(b numberDecimal) int {
+	sa, sb := a.sign(), b.sign()
+	if sa != sb {
+		if sa < sb {
+			return -1
+		}
+		return 1
+	}
+	c := a.exp.Cmp(b.exp)
+	if c == 0 {
+		c = strings.Compare(a.digits, b.digits)
+	}
+	return c * sa
+}
+
 func (n *JsonNumber) Equal(b NodeI) bool {
 	if baseEquals(n, b) {
-		return (n.value == b.(*JsonNumber).value)
+		return n.Cmp(b.(*JsonNumber)) == 0
 	}
 	return false
 }
 
+// Return the value as an int64. Floating point values will be truncated.
+// Use GetInt64 for an error if the value is not an int64.
 func (n *JsonNumber) GetIntValue() int64 {
-	return int64(n.value)
+	i, err := n.GetInt64()
+	if err != nil {
+		return int64(n.value)
+	}
+	return i
+}
+
+// Return the value as an int64. Returns an error if the number is not an integer or is out of range.
+func (n *JsonNumber) GetInt64() (int64, error) {
+	if n.literal != "" {
+		i, err := strconv.ParseInt(n.literal, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+	}
+	bi, err := n.GetBigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !bi.IsInt64() {
+		return 0, fmt.Errorf("number %s overflows int64", n.GetLiteral())
+	}
+	return bi.Int64(), nil
+}
+
+// Return the value as a uint64. Returns an error if the number is not an integer, is negative or is out of range.
+func (n *JsonNumber) GetUint64() (uint64, error) {
+	if n.literal != "" {
+		i, err := strconv.ParseUint(n.literal, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+	}
+	bi, err := n.GetBigInt()
+	if err != nil {
+		return 0, err
+	}
+	if bi.Sign() < 0 {
+		return 0, fmt.Errorf("number %s is negative. Cannot convert to uint64", n.GetLiteral())
+	}
+	if !bi.IsUint64() {
+		return 0, fmt.Errorf("number %s overflows uint64", n.GetLiteral())
+	}
+	return bi.Uint64(), nil
+}
+
+// Return the value as a big.Int. Returns an error if the number is not an integer
+// or the exponent is too large.
+func (n *JsonNumber) GetBigInt() (*big.Int, error) {
+	if !n.IsInteger() {
+		return nil, fmt.Errorf("number %s is not an integer", n.GetLiteral())
+	}
+	r, ok := new(big.Rat).SetString(n.GetLiteral())
+	if !ok {
+		return nil, fmt.Errorf("number %s is too large for a big.Int", n.GetLiteral())
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// Return the value as a big.Float. The precision is large enough to hold all of the digits.
+func (n *JsonNumber) GetBigFloat() *big.Float {
+	lit := n.GetLiteral()
+	f, _, err := big.ParseFloat(lit, 10, uint(len(lit))*4+64, big.ToNearestEven)
+	if err != nil {
+		return big.NewFloat(n.value)
+	}
+	return f
+}
+
+// Return the exact decimal value as a big.Rat. A big.Rat cannot hold an exponent that is too
+// large (for example 1e-99999999) so the float64 value is returned. Use Cmp to compare numbers.
+func (n *JsonNumber) GetBigRat() *big.Rat {
+	r, ok := new(big.Rat).SetString(n.GetLiteral())
+	if !ok {
+		r = new(big.Rat)
+		if !math.IsInf(n.value, 0) && !math.IsNaN(n.value) {
+			r.SetFloat64(n.value)
+		}
+	}
+	return r
 }
 
 func (n *JsonNumber) SetValue(newValue float64) {
 	n.value = newValue
+	n.literal = ""
 }
 
 func (n *JsonNumber) SetIntValue(newValue int64) {
 	n.value = float64(newValue)
+	n.literal = strconv.FormatInt(newValue, 10)
+}
+
+func (n *JsonNumber) SetUint64Value(newValue uint64) {
+	n.value = float64(newValue)
+	n.literal = strconv.FormatUint(newValue, 10)
+}
+
+func (n *JsonNumber) SetBigIntValue(newValue *big.Int) error {
+	return n.SetLiteral(newValue.String())
 }
 
 func (n *JsonNumber) JsonValueIndented(tab int) string {
@@ -447,9 +759,30 @@ func (n *JsonNumber) JsonValue() string {
 }
 
 func (n *JsonNumber) String() string {
-	s := fmt.Sprintf("%f", n.value)
-	s = strings.TrimRight(s, "0")
-	s = strings.TrimRight(s, ".")
+	return n.GetLiteral()
+}
+
+// Format a float64 as json number text. The same rules as encoding/json are used
+// so very large and very small values use an exponent.
+func formatFloat(f float64) string {
+	return formatFloatBits(f, 64)
+}
+
+// Format f as the shortest literal that reads back to the same float32 or float64
+func formatFloatBits(f float64, bitSize int) string {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, bitSize)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		l := len(s)
+		if l >= 4 && s[l-4] == 'e' && s[l-3] == '-' && s[l-2] == '0' {
+			s = s[:l-2] + s[l-1:]
+		}
+	}
 	return s
 }
 
@@ -594,7 +927,8 @@ func Clone(n NodeI, newName string, cloneLeafNodeData bool) NodeI {
 			case NT_BOOL:
 				nn.(*JsonBool).SetValue(n.(*JsonBool).GetValue())
 			case NT_NUMBER:
-				nn.(*JsonNumber).SetValue(n.(*JsonNumber).GetValue())
+				nn.(*JsonNumber).value = n.(*JsonNumber).value
+				nn.(*JsonNumber).literal = n.(*JsonNumber).literal
 			case NT_STRING:
 				nn.(*JsonString).SetValue(n.(*JsonString).GetValue())
 			}
@@ -619,12 +953,14 @@ func Rename(node NodeI, newName string) error {
 			if po.GetNodeWithName(newName) != nil {
 				return fmt.Errorf("cannot rename node. Parent already has a node with the new name")
 			}
-			delete(parentNode.(*JsonObject).value, node.GetName())
-			node.setName(newName)
-			_, err := po.Add(node)
-			if err != nil {
-				return err
+			i := po.indexOfKey(node.GetName())
+			if i < 0 {
+				return fmt.Errorf("cannot rename node. It was not found in its parent")
 			}
+			delete(po.value, node.GetName())
+			node.setName(newName)
+			po.value[newName] = &node
+			po.keys[i] = newName
 
 		default:
 			return fmt.Errorf("cannot rename node as its parent is not a container node")
@@ -652,6 +988,11 @@ func GetNodeTypeName(tt NodeType) string {
 }
 
 func stringValueTabIndent(n NodeI, tab, indent int, useIndent int) string {
+	return stringNamedTabIndent(n, n.GetName(), tab, indent, useIndent)
+}
+
+// Render n with the given name. An empty name renders only the value.
+func stringNamedTabIndent(n NodeI, name string, tab, indent int, useIndent int) string {
 	var sb strings.Builder
 	p := Padding(tab, indent, useIndent)
 	indent++
@@ -659,9 +1000,9 @@ func stringValueTabIndent(n NodeI, tab, indent int, useIndent int) string {
 		useIndent--
 	}
 	sb.WriteString(p)
-	if n.GetName() != "" {
+	if name != "" {
 		sb.WriteByte('"')
-		sb.WriteString(n.GetName())
+		sb.WriteString(EncodeQuotedString(name))
 		sb.WriteByte('"')
 		sb.WriteByte(':')
 		sb.WriteByte(' ')
@@ -689,14 +1030,12 @@ func stringValueTabIndent(n NodeI, tab, indent int, useIndent int) string {
 	case NT_OBJECT:
 		sb.WriteByte('{')
 		nO := n.(*JsonObject)
-		c := len(nO.value) - 1
-		i := 0
-		for _, v := range nO.value {
-			sb.WriteString(stringValueTabIndent(*v, tab, indent, useIndent))
+		c := len(nO.keys) - 1
+		for i, k := range nO.keys {
+			sb.WriteString(stringValueTabIndent(*nO.value[k], tab, indent, useIndent))
 			if i < c {
 				sb.WriteByte(',')
 			}
-			i++
 		}
 		sb.WriteString(p)
 		sb.WriteByte('}')
@@ -722,3 +1061,44 @@ func baseEquals(a, b NodeI) bool {
 	}
 	return true
 }
+
+// Return true if the values of a and b are equal. The names of a and b are not compared
+// but the names of the nodes they contain are. Numbers are compared by value so 1, 1.0 and
+// 1e0 are equal. The order of the keys in an object is ignored.
+func EqualValues(a, b NodeI) bool {
+	if a.GetNodeType() != b.GetNodeType() {
+		return false
+	}
+	switch av := a.(type) {
+	case *JsonObject:
+		bv := b.(*JsonObject)
+		if len(av.value) != len(bv.value) {
+			return false
+		}
+		for key, val := range av.value {
+			v, ok := bv.value[key]
+			if !ok || !EqualValues(*val, *v) {
+				return false
+			}
+		}
+		return true
+	case *JsonList:
+		bv := b.(*JsonList)
+		if len(av.value) != len(bv.value) {
+			return false
+		}
+		for i, v := range av.value {
+			if (*v).GetName() != (*bv.value[i]).GetName() || !EqualValues(*v, *bv.value[i]) {
+				return false
+			}
+		}
+		return true
+	case *JsonNumber:
+		return av.Cmp(b.(*JsonNumber)) == 0
+	case *JsonString:
+		return av.value == b.(*JsonString).value
+	case *JsonBool:
+		return av.value == b.(*JsonBool).value
+	}
+	return true
+}
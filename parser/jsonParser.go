@@ -18,125 +18,175 @@ package parser
 
 import (
 	"fmt"
+	"io"
 )
 
 const (
 	pad string = "                  "
 )
 
-func Parse(json []byte) (node NodeC, err error) {
-	defer func() {
-		r := recover()
-		if r != nil {
-			node = nil
-			err = fmt.Errorf("parser Error: %v", r)
-		}
-	}()
-	sc := NewScanner(json).SkipSpace()
-	var root NodeC
-	tok := sc.Next()
-	switch tok {
-	case '[':
-		root = parseList(sc, "")
-	case '{':
-		root = parseObject(sc, "")
-	default:
-		err = fmt.Errorf("parser Error: %s", sc.Diag("?"))
-		return
+// Options that change the way json is parsed
+type ParseOptions struct {
+	ValidateUTF8 bool // Return an error if a quoted string is not valid UTF-8. Also makes the Strict checks
+	Strict       bool // Return an error for a \x escape or an unescaped control character in a quoted string
+}
+
+// Parse a json text. The root node is normally a JsonObject or a JsonList
+// but any json value is accepted (RFC 8259) so the root can also be a
+// JsonString, JsonNumber, JsonBool or JsonNull.
+func Parse(json []byte) (node NodeI, err error) {
+	return parseScanner(NewScanner(json))
+}
+
+// Parse json read from r. The input is read in chunks as it is parsed
+// so the whole document does not need to be held in memory.
+func ParseReader(r io.Reader) (node NodeI, err error) {
+	return parseScanner(NewReaderScanner(r))
+}
+
+// Same as Parse with options
+func ParseWithOptions(json []byte, options ParseOptions) (node NodeI, err error) {
+	return parseScanner(options.apply(NewScanner(json)))
+}
+
+// Same as ParseReader with options
+func ParseReaderWithOptions(r io.Reader, options ParseOptions) (node NodeI, err error) {
+	return parseScanner(options.apply(NewReaderScanner(r)))
+}
+
+func (o ParseOptions) apply(sc *Scanner) *Scanner {
+	return sc.SetValidateUTF8(o.ValidateUTF8).SetStrict(o.Strict)
+}
+
+var (
+	valueTokens = []TokenType{TT_OBJECT_OPEN, TT_ARRAY_OPEN, TT_QUOTED_STRING, TT_NUMBER, TT_BOOL_TRUE, TT_BOOL_FALSE, TT_NULL}
+)
+
+func parseScanner(sc *Scanner) (NodeI, error) {
+	toc, err := sc.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseValue(sc, toc, "")
+	if err != nil {
+		return nil, err
 	}
-	node = root
-	err = nil
-	return
+	if sc.SkipSpace().HasNext() {
+		return nil, sc.error("unexpected data after the root value")
+	}
+	if sc.err != nil {
+		return nil, sc.error("")
+	}
+	return root, nil
 }
 
-func parseObject(sc *Scanner, name string) NodeC {
+// Return the node for the value starting with toc.
+func parseValue(sc *Scanner, toc *Token, name string) (NodeI, error) {
+	switch toc.GetType() {
+	case TT_QUOTED_STRING:
+		return NewJsonString(name, toc.GetStringValue()), nil
+	case TT_NUMBER:
+		return &JsonNumber{jsonParentNode: NewJsonParentNode(name, NT_NUMBER), value: toc.GetNumberValue(), literal: toc.GetStringValue()}, nil
+	case TT_BOOL_TRUE:
+		return NewJsonBool(name, true), nil
+	case TT_BOOL_FALSE:
+		return NewJsonBool(name, false), nil
+	case TT_NULL:
+		return NewJsonNull(name), nil
+	case TT_ARRAY_OPEN:
+		return parseList(sc, name)
+	case TT_OBJECT_OPEN:
+		return parseObject(sc, name)
+	}
+	return nil, sc.tokenError(fmt.Sprintf("unrecognised token '%s'", toc.GetStringValue()), toc, valueTokens...)
+}
+
+func parseObject(sc *Scanner, name string) (NodeC, error) {
 	root := NewJsonObject(name)
-	var err error
+	toc, err := sc.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	if toc.IsObjectClose() {
+		return root, nil
+	}
 	for {
-		toc := sc.NextToken()
-		if toc.IsObjectClose() {
-			return NewJsonObject(name)
-		}
 		if !toc.IsQuotedString() {
-			panic(fmt.Sprintf("object name is invalid. Found '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
+			return nil, sc.tokenError(fmt.Sprintf("object name is invalid. Found '%s'", toc.GetStringValue()), toc, TT_QUOTED_STRING)
+		}
+		nameToc := toc
+		toc, err = sc.NextToken()
+		if err != nil {
+			return nil, err
 		}
-		name := toc.GetStringValue()
-		toc = sc.NextToken()
 		if !toc.IsColon() {
-			panic(fmt.Sprintf("object name not followed by a ':'. Found '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
-		}
-		toc = sc.NextToken()
-		switch toc.GetType() {
-		case TT_QUOTED_STRING:
-			_, err = root.Add(NewJsonString(name, toc.GetStringValue()))
-		case TT_NUMBER:
-			_, err = root.Add(NewJsonNumber(name, toc.GetNumberValue()))
-		case TT_BOOL_TRUE:
-			_, err = root.Add(NewJsonBool(name, true))
-		case TT_BOOL_FALSE:
-			_, err = root.Add(NewJsonBool(name, false))
-		case TT_NULL:
-			_, err = root.Add(NewJsonNull(name))
-		case TT_ARRAY_OPEN:
-			_, err = root.Add(parseList(sc, name))
-		case TT_OBJECT_OPEN:
-			_, err = root.Add(parseObject(sc, name))
-		default:
-			panic(fmt.Sprintf("unrecognised token '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
+			return nil, sc.tokenError(fmt.Sprintf("object name not followed by a ':'. Found '%s'", toc.GetStringValue()), toc, TT_COLON)
+		}
+		toc, err = sc.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		node, err := parseValue(sc, toc, nameToc.GetStringValue())
+		if err != nil {
+			return nil, err
+		}
+		_, err = root.Add(node)
+		if err != nil {
+			return nil, sc.tokenError(err.Error(), nameToc)
 		}
+		toc, err = sc.NextToken()
 		if err != nil {
-			panic(err.Error())
+			return nil, err
 		}
-		toc = sc.NextToken()
 		if toc.IsObjectClose() {
-			return root
+			return root, nil
 		}
 		if !toc.IsComma() {
-			panic(fmt.Sprintf("expected a ',' seperator. Found '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
-		} else {
-			ptoc := sc.PeekToken()
-			if ptoc.IsObjectClose() || ptoc.IsArrayClose() {
-				panic(fmt.Sprintf("found an invalid '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
-			}
+			return nil, sc.tokenError(fmt.Sprintf("expected a ',' seperator. Found '%s'", toc.GetStringValue()), toc, TT_COMMA, TT_OBJECT_CLOSE)
+		}
+		comma := toc
+		toc, err = sc.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		if toc.IsObjectClose() || toc.IsArrayClose() {
+			return nil, sc.tokenError(fmt.Sprintf("found an invalid '%s'", comma.GetStringValue()), comma, TT_QUOTED_STRING)
 		}
-
 	}
 }
 
-func parseList(sc *Scanner, name string) NodeC {
+func parseList(sc *Scanner, name string) (NodeC, error) {
 	root := NewJsonList(name)
+	toc, err := sc.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	if toc.IsArrayClose() {
+		return root, nil
+	}
 	for {
-		toc := sc.NextToken()
-		if toc.IsArrayClose() {
-			return root
-		}
-		switch toc.GetType() {
-		case TT_QUOTED_STRING:
-			root.Add(NewJsonString("", toc.GetStringValue()))
-		case TT_NUMBER:
-			root.Add(NewJsonNumber("", toc.GetNumberValue()))
-		case TT_BOOL_TRUE:
-			root.Add(NewJsonBool("", true))
-		case TT_BOOL_FALSE:
-			root.Add(NewJsonBool("", false))
-		case TT_NULL:
-			root.Add(NewJsonNull(""))
-		case TT_OBJECT_OPEN:
-			root.Add(parseObject(sc, ""))
-		default:
-			panic(fmt.Sprintf("unrecognised token '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
-		}
-		toc = sc.NextToken()
+		node, err := parseValue(sc, toc, "")
+		if err != nil {
+			return nil, err
+		}
+		root.Add(node)
+		toc, err = sc.NextToken()
+		if err != nil {
+			return nil, err
+		}
 		if toc.IsArrayClose() {
-			return root
+			return root, nil
 		}
 		if !toc.IsComma() {
-			panic(fmt.Sprintf("expected a ',' seperator. Found '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
-		} else {
-			ptoc := sc.PeekToken()
-			if ptoc.IsObjectClose() || ptoc.IsArrayClose() {
-				panic(fmt.Sprintf("found an invalid '%s'. %s ", toc.GetStringValue(), sc.Diag(toc.GetStringValue())))
-			}
+			return nil, sc.tokenError(fmt.Sprintf("expected a ',' seperator. Found '%s'", toc.GetStringValue()), toc, TT_COMMA, TT_ARRAY_CLOSE)
+		}
+		comma := toc
+		toc, err = sc.NextToken()
+		if err != nil {
+			return nil, err
+		}
+		if toc.IsObjectClose() || toc.IsArrayClose() {
+			return nil, sc.tokenError(fmt.Sprintf("found an invalid '%s'", comma.GetStringValue()), comma, valueTokens...)
 		}
 	}
 }
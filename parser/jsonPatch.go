@@ -0,0 +1,428 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Json Patch (RFC 6902) support.
+//
+// A patch is a list of operations such as:
+//   [{"op": "add", "path": "/a/0", "value": 1}, {"op": "remove", "path": "/b"}]
+// Paths are json pointers (RFC 6901).
+
+const (
+	PATCH_ADD     = "add"
+	PATCH_REMOVE  = "remove"
+	PATCH_REPLACE = "replace"
+	PATCH_MOVE    = "move"
+	PATCH_COPY    = "copy"
+	PATCH_TEST    = "test"
+)
+
+// A single patch operation. From is only used by move and copy.
+// Value is only used by add, replace and test.
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value NodeI
+}
+
+type Patch []*PatchOperation
+
+// Parse a json patch document.
+func ParsePatch(json []byte) (Patch, error) {
+	node, err := Parse(json)
+	if err != nil {
+		return nil, err
+	}
+	return NewPatch(node)
+}
+
+// Return a Patch from a parsed json patch document. The document must be a list of objects.
+func NewPatch(node NodeI) (Patch, error) {
+	list, ok := node.(*JsonList)
+	if !ok {
+		return nil, fmt.Errorf("a json patch must be a list of operations")
+	}
+	patch := make(Patch, 0, list.Len())
+	for i, v := range list.GetValues() {
+		o, ok := v.(*JsonObject)
+		if !ok {
+			return nil, fmt.Errorf("patch operation %d is not an object", i)
+		}
+		op := &PatchOperation{}
+		var err error
+		if op.Op, err = patchMember(o, "op", i); err != nil {
+			return nil, err
+		}
+		if op.Path, err = patchMember(o, "path", i); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case PATCH_MOVE, PATCH_COPY:
+			if op.From, err = patchMember(o, "from", i); err != nil {
+				return nil, err
+			}
+		case PATCH_ADD, PATCH_REPLACE, PATCH_TEST:
+			value := o.GetNodeWithName("value")
+			if value == nil {
+				return nil, fmt.Errorf("patch operation %d (%s) requires a 'value'", i, op.Op)
+			}
+			op.Value = Clone(value, "", true)
+		case PATCH_REMOVE:
+		default:
+			return nil, fmt.Errorf("patch operation %d has an unknown op '%s'", i, op.Op)
+		}
+		patch = append(patch, op)
+	}
+	return patch, nil
+}
+
+func patchMember(o *JsonObject, name string, i int) (string, error) {
+	n, ok := o.GetNodeWithName(name).(*JsonString)
+	if !ok {
+		return "", fmt.Errorf("patch operation %d requires a string '%s'", i, name)
+	}
+	if name != "op" {
+		if _, err := NewPointerPath(n.GetValue()); err != nil {
+			return "", fmt.Errorf("patch operation %d: %s", i, err.Error())
+		}
+	}
+	return n.GetValue(), nil
+}
+
+// Return the patch as a json patch document
+func (p Patch) ToJsonList() *JsonList {
+	list := NewJsonList("")
+	for _, op := range p {
+		o := NewJsonObject("")
+		o.Add(NewJsonString("op", op.Op))
+		if op.Op == PATCH_MOVE || op.Op == PATCH_COPY {
+			o.Add(NewJsonString("from", op.From))
+		}
+		o.Add(NewJsonString("path", op.Path))
+		if op.Value != nil {
+			o.Add(Clone(op.Value, "value", true))
+		}
+		list.Add(o)
+	}
+	return list
+}
+
+func (p Patch) JsonValue() string {
+	return p.ToJsonList().JsonValue()
+}
+
+func (p Patch) JsonValueIndented(tab int) string {
+	return p.ToJsonList().JsonValueIndented(tab)
+}
+
+func (p Patch) String() string {
+	return p.JsonValue()
+}
+
+// Apply the patch to root. The operations are applied in order. If any operation
+// fails the operations already applied are undone so root is left unchanged.
+// The returned node is the new root. This is root unless an operation replaced
+// the whole document (path "").
+func (p Patch) Apply(root NodeI) (NodeI, error) {
+	undo := make([]func(), 0, len(p))
+	newRoot := root
+	for i, op := range p {
+		r, u, err := op.apply(newRoot)
+		if err != nil {
+			for j := len(undo) - 1; j >= 0; j-- {
+				undo[j]()
+			}
+			return root, fmt.Errorf("patch operation %d (%s '%s') failed: %s", i, op.Op, op.Path, err.Error())
+		}
+		newRoot = r
+		undo = append(undo, u)
+	}
+	return newRoot, nil
+}
+
+func (op *PatchOperation) apply(root NodeI) (NodeI, func(), error) {
+	switch op.Op {
+	case PATCH_ADD:
+		return patchInsert(root, op.Path, Clone(op.Value, "", true))
+	case PATCH_REMOVE:
+		_, u, err := patchRemove(root, op.Path)
+		return root, u, err
+	case PATCH_REPLACE:
+		if _, err := patchGet(root, op.Path); err != nil {
+			return nil, nil, err
+		}
+		if op.Path == "" {
+			return Clone(op.Value, "", true), func() {}, nil
+		}
+		if parent, _, _ := patchParent(root, op.Path); parent.GetNodeType() == NT_OBJECT {
+			// An object member is replaced in place so the key order is kept
+			return patchInsert(root, op.Path, Clone(op.Value, "", true))
+		}
+		_, u1, err := patchRemove(root, op.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, u2, err := patchInsert(root, op.Path, Clone(op.Value, "", true))
+		if err != nil {
+			u1()
+			return nil, nil, err
+		}
+		return r, func() { u2(); u1() }, nil
+	case PATCH_MOVE:
+		if op.From == op.Path {
+			_, err := patchGet(root, op.From)
+			return root, func() {}, err
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, nil, fmt.Errorf("cannot move '%s' into one of its children", op.From)
+		}
+		node, u1, err := patchRemove(root, op.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, u2, err := patchInsert(root, op.Path, node)
+		if err != nil {
+			u1()
+			return nil, nil, err
+		}
+		return r, func() { u2(); u1() }, nil
+	case PATCH_COPY:
+		node, err := patchGet(root, op.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		return patchInsert(root, op.Path, Clone(node, "", true))
+	case PATCH_TEST:
+		node, err := patchGet(root, op.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !EqualValues(node, op.Value) {
+			return nil, nil, fmt.Errorf("test failed. Found %s", node.JsonValue())
+		}
+		return root, func() {}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown op '%s'", op.Op)
+}
+
+// Find a node from a pointer. Unlike FindPointer list elements must be indexes.
+func patchGet(root NodeI, pointer string) (NodeI, error) {
+	path, err := NewPointerPath(pointer)
+	if err != nil {
+		return nil, err
+	}
+	node := root
+	for _, v := range path.path {
+		switch n := node.(type) {
+		case *JsonList:
+			i, err := patchIndex(v, n.Len(), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n.GetNodeAt(i)
+		case *JsonObject:
+			child := n.GetNodeWithName(v)
+			if child == nil {
+				return nil, fmt.Errorf("'%s' was not found", pointer)
+			}
+			node = child
+		default:
+			return nil, fmt.Errorf("'%s' was not found", pointer)
+		}
+	}
+	return node, nil
+}
+
+// Return the container for a pointer and the last element of the pointer
+func patchParent(root NodeI, pointer string) (NodeC, string, error) {
+	path, err := NewPointerPath(pointer)
+	if err != nil {
+		return nil, "", err
+	}
+	parent, err := patchGet(root, path.PathParent().Pointer())
+	if err != nil {
+		return nil, "", err
+	}
+	if !parent.IsContainer() {
+		return nil, "", fmt.Errorf("the parent of '%s' is not an object or a list", pointer)
+	}
+	return parent.(NodeC), path.StringLast(), nil
+}
+
+func patchIndex(s string, length int, allowEnd bool) (int, error) {
+	if s == "-" && allowEnd {
+		return length, nil
+	}
+	if s == "" || (len(s) > 1 && s[0] == '0') || strings.Trim(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid list index '%s'", s)
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("index %s is out of bounds", s)
+	}
+	return i, nil
+}
+
+// Add node at pointer and return the new root and a function to undo the change.
+// An existing node in an object is replaced.
+func patchInsert(root NodeI, pointer string, node NodeI) (NodeI, func(), error) {
+	if pointer == "" {
+		return node, func() {}, nil
+	}
+	parent, name, err := patchParent(root, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldName := node.GetName()
+	switch p := parent.(type) {
+	case *JsonList:
+		i, err := patchIndex(name, p.Len(), true)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.setName("")
+		p.InsertAt(i, node)
+		return root, func() {
+			p.RemoveAt(i)
+			node.setName(oldName)
+		}, nil
+	case *JsonObject:
+		if name == "" {
+			return nil, nil, fmt.Errorf("a node in a JsonObject container must have a name")
+		}
+		node.setName(name)
+		if old, ok := p.value[name]; ok {
+			oldNode := *old
+			oldNode.setParent(nil)
+			p.value[name] = &node
+			node.setParent(p)
+			return root, func() {
+				node.setParent(nil)
+				node.setName(oldName)
+				p.value[name] = &oldNode
+				oldNode.setParent(p)
+			}, nil
+		}
+		p.Add(node)
+		return root, func() {
+			p.removeFromParent(node)
+			node.setName(oldName)
+		}, nil
+	}
+	return nil, nil, fmt.Errorf("the parent of '%s' is not an object or a list", pointer)
+}
+
+// Remove the node at pointer. Return the node and a function to undo the change.
+func patchRemove(root NodeI, pointer string) (NodeI, func(), error) {
+	if pointer == "" {
+		return nil, nil, fmt.Errorf("cannot remove the root node")
+	}
+	parent, name, err := patchParent(root, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch p := parent.(type) {
+	case *JsonList:
+		i, err := patchIndex(name, p.Len(), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		node, _ := p.RemoveAt(i)
+		return node, func() {
+			p.InsertAt(i, node)
+		}, nil
+	case *JsonObject:
+		i := p.indexOfKey(name)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("'%s' was not found", pointer)
+		}
+		node := *p.value[name]
+		p.removeFromParent(node)
+		return node, func() {
+			p.value[name] = &node
+			p.keys = append(p.keys, "")
+			copy(p.keys[i+1:], p.keys[i:])
+			p.keys[i] = name
+			node.setParent(p)
+		}, nil
+	}
+	return nil, nil, fmt.Errorf("the parent of '%s' is not an object or a list", pointer)
+}
+
+// Return a Patch that changes from into to. Applying the patch to from
+// returns a tree where EqualValues(from, to) is true.
+func CreatePatch(from, to NodeI) Patch {
+	patch := make(Patch, 0)
+	createPatch(from, to, "", &patch)
+	return patch
+}
+
+func createPatch(from, to NodeI, pointer string, patch *Patch) {
+	if from.GetNodeType() != to.GetNodeType() {
+		*patch = append(*patch, &PatchOperation{Op: PATCH_REPLACE, Path: pointer, Value: Clone(to, "", true)})
+		return
+	}
+	switch f := from.(type) {
+	case *JsonObject:
+		t := to.(*JsonObject)
+		for _, k := range f.keys {
+			p := pointer + "/" + pointerEscaper.Replace(k)
+			if tv, ok := t.value[k]; ok {
+				createPatch(*f.value[k], *tv, p, patch)
+			} else {
+				*patch = append(*patch, &PatchOperation{Op: PATCH_REMOVE, Path: p})
+			}
+		}
+		for _, k := range t.keys {
+			if _, ok := f.value[k]; !ok {
+				*patch = append(*patch, &PatchOperation{Op: PATCH_ADD, Path: pointer + "/" + pointerEscaper.Replace(k), Value: Clone(*t.value[k], "", true)})
+			}
+		}
+	case *JsonList:
+		t := to.(*JsonList)
+		common := f.Len()
+		if t.Len() < common {
+			common = t.Len()
+		}
+		for i := 0; i < common; i++ {
+			fv, tv := f.GetNodeAt(i), t.GetNodeAt(i)
+			p := fmt.Sprintf("%s/%d", pointer, i)
+			if fv.GetName() != tv.GetName() {
+				*patch = append(*patch, &PatchOperation{Op: PATCH_REPLACE, Path: p, Value: Clone(tv, "", true)})
+			} else {
+				createPatch(fv, tv, p, patch)
+			}
+		}
+		for i := f.Len() - 1; i >= common; i-- {
+			*patch = append(*patch, &PatchOperation{Op: PATCH_REMOVE, Path: fmt.Sprintf("%s/%d", pointer, i)})
+		}
+		for i := common; i < t.Len(); i++ {
+			*patch = append(*patch, &PatchOperation{Op: PATCH_ADD, Path: fmt.Sprintf("%s/%d", pointer, i), Value: Clone(t.GetNodeAt(i), "", true)})
+		}
+	default:
+		if !EqualValues(from, to) {
+			*patch = append(*patch, &PatchOperation{Op: PATCH_REPLACE, Path: pointer, Value: Clone(to, "", true)})
+		}
+	}
+}
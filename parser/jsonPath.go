@@ -0,0 +1,1020 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Json Path (RFC 9535) queries.
+//
+// A query such as "$.store.book[?@.price < 10].title" selects zero or more nodes.
+// Supported are names, wildcards, indexes, slices, unions, descendants (..) and
+// filters with the functions length(), count(), match(), search() and value().
+
+// A compiled json path query. It can be used many times and by many go routines.
+type JsonPath struct {
+	query string
+	root  *jpQuery
+}
+
+// Compile a json path query. An error is returned if the query is invalid.
+func NewJsonPath(query string) (*JsonPath, error) {
+	p := &jpParser{q: query}
+	if p.peek() != '$' {
+		return nil, p.errorf("a json path must start with '$'")
+	}
+	q, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.q) {
+		return nil, p.errorf("unexpected %s", p.found())
+	}
+	return &JsonPath{query: query, root: q}, nil
+}
+
+// Compile and run a json path query. See JsonPath.Query
+func JsonPathQuery(root NodeI, query string) ([]NodeI, []*Path, error) {
+	jp, err := NewJsonPath(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes, paths := jp.Query(root)
+	return nodes, paths, nil
+}
+
+// Return the nodes selected by the query and the Path to each node from root.
+// Nodes in lists are identified in the Path by their index.
+// The Path delim is '.' but use Path.Pointer() if names can contain a '.'.
+func (jp *JsonPath) Query(root NodeI) ([]NodeI, []*Path) {
+	found := jp.root.eval(root, root)
+	nodes := make([]NodeI, len(found))
+	paths := make([]*Path, len(found))
+	for i, f := range found {
+		nodes[i] = f.node
+		if len(f.path) == 0 {
+			paths[i] = &Path{path: parserEmptyPath, delim: "."}
+		} else {
+			paths[i] = &Path{path: f.path, delim: "."}
+		}
+	}
+	return nodes, paths
+}
+
+func (jp *JsonPath) String() string {
+	return jp.query
+}
+
+// Query structure
+type jpNode struct {
+	node NodeI
+	path []string
+}
+
+type jpQuery struct {
+	relative bool
+	segments []*jpSegment
+}
+
+type jpSegment struct {
+	descendant bool
+	selectors  []jpSelector
+}
+
+type jpSelector interface {
+	apply(n jpNode, root NodeI, out *[]jpNode)
+}
+
+type jpName string
+type jpWildcard struct{}
+type jpIndex int
+type jpSlice struct {
+	start, end, step *int
+}
+type jpFilter struct {
+	expr jpLogical
+}
+
+type jpLogical interface {
+	test(cur, root NodeI) bool
+}
+
+type jpOr []jpLogical
+type jpAnd []jpLogical
+type jpNot struct {
+	expr jpLogical
+}
+type jpExists struct {
+	query *jpQuery
+}
+type jpCompare struct {
+	op          string
+	left, right jpComparable
+}
+
+// A comparable returns nil when there is no value (Nothing)
+type jpComparable interface {
+	value(cur, root NodeI) NodeI
+}
+
+type jpLiteral struct {
+	node NodeI
+}
+type jpSingular struct {
+	query *jpQuery
+}
+
+type jpType int
+
+const (
+	jpValueType jpType = iota
+	jpLogicalType
+	jpNodesType
+)
+
+type jpFunctionDef struct {
+	result jpType
+	params []jpType
+	call   func(args []interface{}) interface{}
+}
+
+type jpFunction struct {
+	name string
+	def  *jpFunctionDef
+	args []interface{}
+}
+
+var jpFunctions = map[string]*jpFunctionDef{
+	"length": {result: jpValueType, params: []jpType{jpValueType}, call: jpLength},
+	"count":  {result: jpValueType, params: []jpType{jpNodesType}, call: jpCount},
+	"match":  {result: jpLogicalType, params: []jpType{jpValueType, jpValueType}, call: jpMatch},
+	"search": {result: jpLogicalType, params: []jpType{jpValueType, jpValueType}, call: jpSearch},
+	"value":  {result: jpValueType, params: []jpType{jpNodesType}, call: jpValue},
+}
+
+const jpMaxInt = 1<<53 - 1
+
+// Evaluation
+func (q *jpQuery) eval(cur, root NodeI) []jpNode {
+	start := root
+	if q.relative {
+		start = cur
+	}
+	nodes := []jpNode{{node: start}}
+	for _, seg := range q.segments {
+		out := make([]jpNode, 0)
+		for _, n := range nodes {
+			if seg.descendant {
+				jpDescend(n, func(d jpNode) {
+					for _, sel := range seg.selectors {
+						sel.apply(d, root, &out)
+					}
+				})
+			} else {
+				for _, sel := range seg.selectors {
+					sel.apply(n, root, &out)
+				}
+			}
+		}
+		nodes = out
+	}
+	return nodes
+}
+
+func (q *jpQuery) isSingular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		switch seg.selectors[0].(type) {
+		case jpName, jpIndex:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func jpDescend(n jpNode, visit func(jpNode)) {
+	visit(n)
+	jpChildren(n, func(c jpNode) {
+		jpDescend(c, visit)
+	})
+}
+
+func jpChildren(n jpNode, visit func(jpNode)) {
+	switch c := n.node.(type) {
+	case *JsonList:
+		for i, v := range c.value {
+			visit(jpNode{node: *v, path: jpAppend(n.path, strconv.Itoa(i))})
+		}
+	case *JsonObject:
+		for _, k := range c.keys {
+			visit(jpNode{node: *c.value[k], path: jpAppend(n.path, k)})
+		}
+	}
+}
+
+func jpAppend(path []string, element string) []string {
+	p := make([]string, len(path)+1)
+	copy(p, path)
+	p[len(path)] = element
+	return p
+}
+
+func (s jpName) apply(n jpNode, root NodeI, out *[]jpNode) {
+	if o, ok := n.node.(*JsonObject); ok {
+		if v, found := o.value[string(s)]; found {
+			*out = append(*out, jpNode{node: *v, path: jpAppend(n.path, string(s))})
+		}
+	}
+}
+
+func (s jpWildcard) apply(n jpNode, root NodeI, out *[]jpNode) {
+	jpChildren(n, func(c jpNode) {
+		*out = append(*out, c)
+	})
+}
+
+func (s jpIndex) apply(n jpNode, root NodeI, out *[]jpNode) {
+	if l, ok := n.node.(*JsonList); ok {
+		i := int(s)
+		if i < 0 {
+			i = len(l.value) + i
+		}
+		if i >= 0 && i < len(l.value) {
+			*out = append(*out, jpNode{node: *l.value[i], path: jpAppend(n.path, strconv.Itoa(i))})
+		}
+	}
+}
+
+func (s *jpSlice) apply(n jpNode, root NodeI, out *[]jpNode) {
+	l, ok := n.node.(*JsonList)
+	if !ok {
+		return
+	}
+	length := len(l.value)
+	step := 1
+	if s.step != nil {
+		step = *s.step
+	}
+	if step == 0 {
+		return
+	}
+	normal := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		if *i < 0 {
+			return length + *i
+		}
+		return *i
+	}
+	bound := func(i, lower, upper int) int {
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+	add := func(i int) {
+		*out = append(*out, jpNode{node: *l.value[i], path: jpAppend(n.path, strconv.Itoa(i))})
+	}
+	if step > 0 {
+		lower := bound(normal(s.start, 0), 0, length)
+		upper := bound(normal(s.end, length), 0, length)
+		for i := lower; i < upper; i += step {
+			add(i)
+		}
+	} else {
+		upper := bound(normal(s.start, length-1), -1, length-1)
+		lower := bound(normal(s.end, -length-1), -1, length-1)
+		for i := upper; lower < i; i += step {
+			add(i)
+		}
+	}
+}
+
+func (s *jpFilter) apply(n jpNode, root NodeI, out *[]jpNode) {
+	jpChildren(n, func(c jpNode) {
+		if s.expr.test(c.node, root) {
+			*out = append(*out, c)
+		}
+	})
+}
+
+func (e jpOr) test(cur, root NodeI) bool {
+	for _, v := range e {
+		if v.test(cur, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e jpAnd) test(cur, root NodeI) bool {
+	for _, v := range e {
+		if !v.test(cur, root) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *jpNot) test(cur, root NodeI) bool {
+	return !e.expr.test(cur, root)
+}
+
+func (e *jpExists) test(cur, root NodeI) bool {
+	return len(e.query.eval(cur, root)) > 0
+}
+
+func (e *jpCompare) test(cur, root NodeI) bool {
+	l := e.left.value(cur, root)
+	r := e.right.value(cur, root)
+	switch e.op {
+	case "==":
+		return jpEqual(l, r)
+	case "!=":
+		return !jpEqual(l, r)
+	case "<":
+		return jpLess(l, r)
+	case "<=":
+		return jpLess(l, r) || jpEqual(l, r)
+	case ">":
+		return jpLess(r, l)
+	default:
+		return jpLess(r, l) || jpEqual(l, r)
+	}
+}
+
+func jpEqual(a, b NodeI) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return EqualValues(a, b)
+}
+
+func jpLess(a, b NodeI) bool {
+	switch av := a.(type) {
+	case *JsonNumber:
+		if bv, ok := b.(*JsonNumber); ok {
+			return av.Cmp(bv) < 0
+		}
+	case *JsonString:
+		if bv, ok := b.(*JsonString); ok {
+			return av.value < bv.value
+		}
+	}
+	return false
+}
+
+func (e *jpLiteral) value(cur, root NodeI) NodeI {
+	return e.node
+}
+
+func (e *jpSingular) value(cur, root NodeI) NodeI {
+	nodes := e.query.eval(cur, root)
+	if len(nodes) == 1 {
+		return nodes[0].node
+	}
+	return nil
+}
+
+func (f *jpFunction) call(cur, root NodeI) interface{} {
+	args := make([]interface{}, len(f.args))
+	for i, a := range f.args {
+		switch v := a.(type) {
+		case jpComparable:
+			args[i] = v.value(cur, root)
+		case *jpQuery:
+			found := v.eval(cur, root)
+			nodes := make([]NodeI, len(found))
+			for j, n := range found {
+				nodes[j] = n.node
+			}
+			args[i] = nodes
+		case jpLogical:
+			args[i] = v.test(cur, root)
+		}
+	}
+	return f.def.call(args)
+}
+
+func (f *jpFunction) value(cur, root NodeI) NodeI {
+	n, _ := f.call(cur, root).(NodeI)
+	return n
+}
+
+func (f *jpFunction) test(cur, root NodeI) bool {
+	switch v := f.call(cur, root).(type) {
+	case bool:
+		return v
+	case []NodeI:
+		return len(v) > 0
+	}
+	return false
+}
+
+func jpLength(args []interface{}) interface{} {
+	switch v := args[0].(type) {
+	case *JsonString:
+		return NewJsonNumber("", float64(utf8.RuneCountInString(v.value)))
+	case *JsonList:
+		return NewJsonNumber("", float64(v.Len()))
+	case *JsonObject:
+		return NewJsonNumber("", float64(v.Len()))
+	}
+	return nil
+}
+
+func jpCount(args []interface{}) interface{} {
+	return NewJsonNumber("", float64(len(args[0].([]NodeI))))
+}
+
+func jpValue(args []interface{}) interface{} {
+	nodes := args[0].([]NodeI)
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return nil
+}
+
+func jpMatch(args []interface{}) interface{} {
+	return jpRegexpTest(args, true)
+}
+
+func jpSearch(args []interface{}) interface{} {
+	return jpRegexpTest(args, false)
+}
+
+func jpRegexpTest(args []interface{}, anchored bool) bool {
+	s, ok1 := args[0].(*JsonString)
+	p, ok2 := args[1].(*JsonString)
+	if !ok1 || !ok2 {
+		return false
+	}
+	re := jpRegexp(p.value, anchored)
+	return re != nil && re.MatchString(s.value)
+}
+
+var jpRegexpCache sync.Map
+
+// Compile an I-Regexp (RFC 9485). Returns nil if the pattern is invalid.
+// In an I-Regexp '.' does not match '\n' or '\r'.
+func jpRegexp(pattern string, anchored bool) *regexp.Regexp {
+	key := fmt.Sprintf("%t:%s", anchored, pattern)
+	if re, ok := jpRegexpCache.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+	var sb strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			sb.WriteByte(c)
+			i++
+			c = pattern[i]
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			sb.WriteString(`[^\n\r]`)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	expr := sb.String()
+	if anchored {
+		expr = "^(?:" + expr + ")$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	jpRegexpCache.Store(key, re)
+	return re
+}
+
+// Query parser
+type jpParser struct {
+	q   string
+	pos int
+}
+
+func (p *jpParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid json path '%s' at %d. %s", p.q, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jpParser) errorAt(pos int, format string, args ...interface{}) error {
+	p.pos = pos
+	return p.errorf(format, args...)
+}
+
+func (p *jpParser) peek() byte {
+	if p.pos < len(p.q) {
+		return p.q[p.pos]
+	}
+	return 0
+}
+
+func (p *jpParser) found() string {
+	if p.pos < len(p.q) {
+		return fmt.Sprintf("'%c'", p.q[p.pos])
+	}
+	return "end of path"
+}
+
+func (p *jpParser) skipBlank() {
+	for p.pos < len(p.q) && strings.IndexByte(" \t\n\r", p.q[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jpParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected '%c'. Found %s", c, p.found())
+	}
+	p.pos++
+	return nil
+}
+
+// Parse a query starting with '$' or '@'
+func (p *jpParser) parseQuery() (*jpQuery, error) {
+	q := &jpQuery{relative: p.peek() == '@', segments: make([]*jpSegment, 0)}
+	p.pos++
+	for {
+		start := p.pos
+		p.skipBlank()
+		if c := p.peek(); c != '.' && c != '[' {
+			p.pos = start
+			return q, nil
+		}
+		seg, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, seg)
+	}
+}
+
+func (p *jpParser) parseSegment() (*jpSegment, error) {
+	seg := &jpSegment{selectors: make([]jpSelector, 0)}
+	if strings.HasPrefix(p.q[p.pos:], "..") {
+		seg.descendant = true
+		p.pos += 2
+		if p.peek() == '[' {
+			return seg, p.parseBracket(seg)
+		}
+	} else if p.peek() == '.' {
+		p.pos++
+	} else {
+		return seg, p.parseBracket(seg)
+	}
+	if p.peek() == '*' {
+		p.pos++
+		seg.selectors = append(seg.selectors, jpWildcard{})
+		return seg, nil
+	}
+	start := p.pos
+	for p.pos < len(p.q) {
+		c := p.q[p.pos]
+		if c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+		} else {
+			break
+		}
+	}
+	if p.pos == start {
+		return nil, p.errorf("expected a name or '*'. Found %s", p.found())
+	}
+	seg.selectors = append(seg.selectors, jpName(p.q[start:p.pos]))
+	return seg, nil
+}
+
+func (p *jpParser) parseBracket(seg *jpSegment) error {
+	p.pos++
+	for {
+		p.skipBlank()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.skipBlank()
+		switch p.peek() {
+		case ']':
+			p.pos++
+			return nil
+		case ',':
+			p.pos++
+		default:
+			return p.errorf("expected ',' or ']'. Found %s", p.found())
+		}
+	}
+}
+
+func (p *jpParser) parseSelector() (jpSelector, error) {
+	switch p.peek() {
+	case '\'', '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return jpName(s), nil
+	case '*':
+		p.pos++
+		return jpWildcard{}, nil
+	case '?':
+		p.pos++
+		p.skipBlank()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return &jpFilter{expr: e}, nil
+	}
+	var parts [3]*int
+	n := 0
+	for {
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			i, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			parts[n] = &i
+			p.skipBlank()
+		}
+		if n == 2 || p.peek() != ':' {
+			break
+		}
+		p.pos++
+		n++
+		p.skipBlank()
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return nil, p.errorf("expected a selector. Found %s", p.found())
+		}
+		return jpIndex(*parts[0]), nil
+	}
+	return &jpSlice{start: parts[0], end: parts[1], step: parts[2]}, nil
+}
+
+func (p *jpParser) parseInt() (int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	digits := p.pos
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	s := p.q[start:p.pos]
+	if p.pos == digits || (p.q[digits] == '0' && (p.pos-digits > 1 || digits > start)) {
+		return 0, p.errorAt(start, "invalid integer '%s'", s)
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || i > jpMaxInt || i < -jpMaxInt {
+		return 0, p.errorAt(start, "integer '%s' is out of range", s)
+	}
+	return int(i), nil
+}
+
+func (p *jpParser) parseString() (string, error) {
+	quote := p.q[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.q) {
+		c := p.q[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c == '\\':
+			p.pos++
+			e := p.peek()
+			p.pos++
+			switch e {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '/', '\\', quote:
+				sb.WriteByte(e)
+			case 'u':
+				r, err := p.parseUnicode()
+				if err != nil {
+					return "", err
+				}
+				sb.WriteRune(r)
+			default:
+				return "", p.errorAt(p.pos-2, "invalid escape in string")
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jpParser) parseUnicode() (rune, error) {
+	start := p.pos - 2
+	r, ok := p.parseHex4()
+	if !ok {
+		return 0, p.errorAt(start, "invalid unicode escape")
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	if r < 0xDC00 && strings.HasPrefix(p.q[p.pos:], `\u`) {
+		p.pos += 2
+		if r2, ok := p.parseHex4(); ok && r2 >= 0xDC00 && r2 <= 0xDFFF {
+			return utf16.DecodeRune(r, r2), nil
+		}
+	}
+	return 0, p.errorAt(start, "invalid unicode surrogate pair")
+}
+
+func (p *jpParser) parseHex4() (rune, bool) {
+	if p.pos+4 > len(p.q) {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(p.q[p.pos:p.pos+4], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	p.pos += 4
+	return rune(v), true
+}
+
+func (p *jpParser) parseOr() (jpLogical, error) {
+	or := make(jpOr, 0)
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, e)
+		p.skipBlank()
+		if !strings.HasPrefix(p.q[p.pos:], "||") {
+			break
+		}
+		p.pos += 2
+		p.skipBlank()
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *jpParser) parseAnd() (jpLogical, error) {
+	and := make(jpAnd, 0)
+	for {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, e)
+		p.skipBlank()
+		if !strings.HasPrefix(p.q[p.pos:], "&&") {
+			break
+		}
+		p.pos += 2
+		p.skipBlank()
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *jpParser) parseNot() (jpLogical, error) {
+	if p.peek() != '!' {
+		e, _, err := p.parseBasic()
+		return e, err
+	}
+	p.pos++
+	p.skipBlank()
+	start := p.pos
+	e, isCompare, err := p.parseBasic()
+	if err != nil {
+		return nil, err
+	}
+	if isCompare {
+		return nil, p.errorAt(start, "'!' cannot be applied to a comparison. Use '!(...)'")
+	}
+	return &jpNot{expr: e}, nil
+}
+
+// Parse a paren expression, a comparison or a test expression.
+// The bool is true if a comparison was parsed.
+func (p *jpParser) parseBasic() (jpLogical, bool, error) {
+	if p.peek() == '(' {
+		p.pos++
+		p.skipBlank()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+		p.skipBlank()
+		return e, false, p.expect(')')
+	}
+	start := p.pos
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, false, err
+	}
+	afterLeft := p.pos
+	p.skipBlank()
+	if op := p.compareOp(); op != "" {
+		l, err := p.toComparable(left, start)
+		if err != nil {
+			return nil, false, err
+		}
+		p.skipBlank()
+		rightStart := p.pos
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, false, err
+		}
+		r, err := p.toComparable(right, rightStart)
+		if err != nil {
+			return nil, false, err
+		}
+		return &jpCompare{op: op, left: l, right: r}, true, nil
+	}
+	p.pos = afterLeft
+	switch v := left.(type) {
+	case *jpQuery:
+		return &jpExists{query: v}, false, nil
+	case *jpFunction:
+		if v.def.result == jpValueType {
+			return nil, false, p.errorAt(start, "the result of %s() must be compared", v.name)
+		}
+		return v, false, nil
+	}
+	return nil, false, p.errorAt(start, "a literal must be compared")
+}
+
+func (p *jpParser) compareOp() string {
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.q[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// Parse a query, a literal or a function call
+func (p *jpParser) parseOperand() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		return p.parseQuery()
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &jpLiteral{node: NewJsonString("", s)}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.q) && strings.IndexByte("0123456789.eE+-", p.q[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, err := NewJsonNumberFromLiteral("", p.q[start:p.pos])
+		if err != nil {
+			return nil, p.errorAt(start, "invalid number '%s'", p.q[start:p.pos])
+		}
+		return &jpLiteral{node: n}, nil
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for c := p.peek(); c == '_' || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'); c = p.peek() {
+			p.pos++
+		}
+		name := p.q[start:p.pos]
+		if p.peek() == '(' {
+			return p.parseFunction(name, start)
+		}
+		switch name {
+		case "true":
+			return &jpLiteral{node: NewJsonBool("", true)}, nil
+		case "false":
+			return &jpLiteral{node: NewJsonBool("", false)}, nil
+		case "null":
+			return &jpLiteral{node: NewJsonNull("")}, nil
+		}
+		p.pos = start
+	}
+	return nil, p.errorf("expected a query, literal or function. Found %s", p.found())
+}
+
+func (p *jpParser) toComparable(op interface{}, start int) (jpComparable, error) {
+	switch v := op.(type) {
+	case *jpLiteral:
+		return v, nil
+	case *jpQuery:
+		if !v.isSingular() {
+			return nil, p.errorAt(start, "only a singular query (names and indexes) can be compared")
+		}
+		return &jpSingular{query: v}, nil
+	case *jpFunction:
+		if v.def.result != jpValueType {
+			return nil, p.errorAt(start, "the result of %s() cannot be compared", v.name)
+		}
+		return v, nil
+	}
+	return nil, p.errorAt(start, "expected a value")
+}
+
+func (p *jpParser) parseFunction(name string, start int) (*jpFunction, error) {
+	def, ok := jpFunctions[name]
+	if !ok {
+		return nil, p.errorAt(start, "unknown function '%s'", name)
+	}
+	p.pos++
+	f := &jpFunction{name: name, def: def, args: make([]interface{}, 0, len(def.params))}
+	for i, t := range def.params {
+		p.skipBlank()
+		if i > 0 {
+			if p.peek() != ',' {
+				return nil, p.errorf("%s() requires %d arguments. Found %s", name, len(def.params), p.found())
+			}
+			p.pos++
+			p.skipBlank()
+		}
+		argStart := p.pos
+		switch t {
+		case jpValueType:
+			op, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			c, err := p.toComparable(op, argStart)
+			if err != nil {
+				return nil, err
+			}
+			f.args = append(f.args, c)
+		case jpNodesType:
+			op, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			q, ok := op.(*jpQuery)
+			if !ok {
+				return nil, p.errorAt(argStart, "argument %d of %s() must be a query", i+1, name)
+			}
+			f.args = append(f.args, q)
+		default:
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			f.args = append(f.args, e)
+		}
+	}
+	p.skipBlank()
+	if p.peek() != ')' {
+		return nil, p.errorf("%s() requires %d arguments. Found %s", name, len(def.params), p.found())
+	}
+	p.pos++
+	return f, nil
+}
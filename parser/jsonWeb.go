@@ -8,11 +8,15 @@ import (
 )
 
 func GetJsonParsed(getUrl string) (NodeI, error) {
-	data, err := GetJson(getUrl)
+	resp, err := http.Get(getUrl)
 	if err != nil {
 		return nil, err
 	}
-	n, err := Parse(data)
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get data from server. Status is not 200. Code:%d Url:%s", resp.StatusCode, getUrl)
+	}
+	n, err := ParseReader(resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,592 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Implemented by types that create their own node. The returned node must have the given name.
+type NodeMarshaler interface {
+	MarshalNode(name string) (NodeI, error)
+}
+
+// Implemented by types that read their value from a node.
+type NodeUnmarshaler interface {
+	UnmarshalNode(node NodeI) error
+}
+
+var (
+	nodeMarshalerType   = reflect.TypeOf((*NodeMarshaler)(nil)).Elem()
+	nodeUnmarshalerType = reflect.TypeOf((*NodeUnmarshaler)(nil)).Elem()
+	nodeIType           = reflect.TypeOf((*NodeI)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	jsonNumberType      = reflect.TypeOf(json.Number(""))
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// Create a node tree from a Go value. The rules of encoding/json are used.
+// Struct fields use the json tag for the name and the options omitempty and string.
+// A field with the tag "-" is not included. time.Time is a RFC 3339 string and []byte is a base64 string.
+// Map keys are sorted. A value that implements NodeMarshaler creates its own node.
+// A json.Number, json.RawMessage or a value that implements json.Marshaler is parsed.
+func Marshal(v interface{}) (NodeI, error) {
+	return marshalValue(reflect.ValueOf(v), "", false)
+}
+
+func marshalValue(rv reflect.Value, name string, quoted bool) (NodeI, error) {
+	if !rv.IsValid() || ((rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil()) {
+		return NewJsonNull(name), nil
+	}
+	if rv.Kind() == reflect.Interface {
+		return marshalValue(rv.Elem(), name, quoted)
+	}
+	if rv.Type().Implements(nodeMarshalerType) {
+		return rv.Interface().(NodeMarshaler).MarshalNode(name)
+	}
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() && rv.Addr().Type().Implements(nodeMarshalerType) {
+		return rv.Addr().Interface().(NodeMarshaler).MarshalNode(name)
+	}
+	if rv.Type().Implements(nodeIType) {
+		return Clone(rv.Interface().(NodeI), name, true), nil
+	}
+	if rv.Type() == timeType {
+		return NewJsonString(name, rv.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	}
+	switch rv.Type() {
+	case jsonNumberType:
+		return NewJsonNumberFromLiteral(name, rv.String())
+	case rawMessageType:
+		return marshalJson(rv.Bytes(), name)
+	}
+	if rv.Type().Implements(jsonMarshalerType) {
+		b, err := rv.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return marshalJson(b, name)
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if quoted {
+			return NewJsonString(name, strconv.FormatBool(rv.Bool())), nil
+		}
+		return NewJsonBool(name, rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if quoted {
+			return NewJsonString(name, strconv.FormatInt(rv.Int(), 10)), nil
+		}
+		n := NewJsonNumber(name, 0)
+		n.SetIntValue(rv.Int())
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if quoted {
+			return NewJsonString(name, strconv.FormatUint(rv.Uint(), 10)), nil
+		}
+		n := NewJsonNumber(name, 0)
+		n.SetUint64Value(rv.Uint())
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		if math.IsInf(rv.Float(), 0) || math.IsNaN(rv.Float()) {
+			return nil, fmt.Errorf("cannot marshal float %s. A json number cannot be NaN or Inf", strconv.FormatFloat(rv.Float(), 'g', -1, 64))
+		}
+		bitSize := 64
+		if rv.Kind() == reflect.Float32 {
+			bitSize = 32
+		}
+		literal := formatFloatBits(rv.Float(), bitSize)
+		if quoted {
+			return NewJsonString(name, literal), nil
+		}
+		n := NewJsonNumber(name, 0)
+		if err := n.SetLiteral(literal); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case reflect.String:
+		if quoted {
+			return NewJsonString(name, "\""+EncodeQuotedStringWith(rv.String(), ENCODE_UTF8)+"\""), nil
+		}
+		return NewJsonString(name, rv.String()), nil
+	case reflect.Ptr:
+		return marshalValue(rv.Elem(), name, quoted)
+	case reflect.Struct:
+		return marshalStruct(rv, name)
+	case reflect.Map:
+		return marshalMap(rv, name)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return NewJsonNull(name), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return NewJsonString(name, base64.StdEncoding.EncodeToString(rv.Bytes())), nil
+		}
+		return marshalList(rv, name)
+	case reflect.Array:
+		return marshalList(rv, name)
+	}
+	return nil, fmt.Errorf("cannot marshal type %s", rv.Type())
+}
+
+// Parse json from encoding/json and give the node a name
+func marshalJson(b []byte, name string) (NodeI, error) {
+	n, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	n.setName(name)
+	return n, nil
+}
+
+func marshalList(rv reflect.Value, name string) (NodeI, error) {
+	list := NewJsonList(name)
+	for i := 0; i < rv.Len(); i++ {
+		n, err := marshalValue(rv.Index(i), "", false)
+		if err != nil {
+			return nil, err
+		}
+		list.Add(n)
+	}
+	return list, nil
+}
+
+func marshalMap(rv reflect.Value, name string) (NodeI, error) {
+	if rv.IsNil() {
+		return NewJsonNull(name), nil
+	}
+	keys := make([]string, 0, rv.Len())
+	values := make(map[string]reflect.Value, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		k, err := mapKeyString(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		values[k] = iter.Value()
+	}
+	sort.Strings(keys)
+	obj := NewJsonObject(name)
+	for _, k := range keys {
+		n, err := marshalValue(values[k], k, false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := obj.Add(n); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func mapKeyString(k reflect.Value) (string, error) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cannot marshal map key type %s", k.Type())
+}
+
+func marshalStruct(rv reflect.Value, name string) (NodeI, error) {
+	obj := NewJsonObject(name)
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index, false)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		n, err := marshalValue(fv, f.name, f.quoted)
+		if err != nil {
+			return nil, err
+		}
+		obj.Add(n)
+	}
+	return obj, nil
+}
+
+// The json name and options of a struct field
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	quoted    bool
+}
+
+// Return the fields of a struct including the fields of embedded structs.
+// A field in an outer struct hides a field with the same name in an embedded struct.
+func structFields(t reflect.Type) []*structField {
+	fields := make([]*structField, 0)
+	names := make(map[string]bool)
+	embedded := make([]*structField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && opts[0] == "" && ft.Kind() == reflect.Struct {
+			for _, ef := range structFields(ft) {
+				ef.index = append([]int{i}, ef.index...)
+				embedded = append(embedded, ef)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		f := &structField{name: sf.Name, index: []int{i}}
+		if opts[0] != "" {
+			f.name = opts[0]
+		}
+		for _, o := range opts[1:] {
+			switch o {
+			case "omitempty":
+				f.omitEmpty = true
+			case "string":
+				switch ft.Kind() {
+				case reflect.Bool, reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+					f.quoted = true
+				}
+			}
+		}
+		if !names[f.name] {
+			names[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+	for _, f := range embedded {
+		if !names[f.name] {
+			names[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Return the field value. Nil embedded pointers are created if alloc is true, otherwise false is returned.
+func fieldByIndex(rv reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc || !rv.CanSet() {
+					return rv, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Copy the values in a node tree in to the Go value that v points to. The rules of encoding/json are used.
+// Object keys are matched to struct field names (see Marshal) ignoring case. Unknown keys are ignored.
+// A null value sets pointers, maps, slices and interfaces to nil and does not change other values.
+// A value that implements NodeUnmarshaler reads its own node. A value that implements json.Unmarshaler
+// reads the json value of the node. An interface{} is set to a
+// map[string]interface{}, []interface{}, float64, string, bool or nil. A NodeI is set to a copy of the node.
+func Unmarshal(node NodeI, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("unmarshal requires a non nil pointer. Actual: %T", v)
+	}
+	return unmarshalValue(node, rv.Elem(), make([]string, 0), false)
+}
+
+func unmarshalError(node NodeI, rv reflect.Value, path []string) error {
+	return fmt.Errorf("cannot unmarshal %s into %s%s", GetNodeTypeName(node.GetNodeType()), rv.Type(), unmarshalAt(path))
+}
+
+func unmarshalValue(node NodeI, rv reflect.Value, path []string, quoted bool) error {
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() && rv.Addr().Type().Implements(nodeUnmarshalerType) {
+		return rv.Addr().Interface().(NodeUnmarshaler).UnmarshalNode(node)
+	}
+	if rv.Type() == nodeIType {
+		rv.Set(reflect.ValueOf(Clone(node, node.GetName(), true)))
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		if node.GetNodeType() == NT_NULL {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.Type().Implements(nodeIType) {
+			c := reflect.ValueOf(Clone(node, node.GetName(), true))
+			if !c.Type().AssignableTo(rv.Type()) {
+				return unmarshalError(node, rv, path)
+			}
+			rv.Set(c)
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshalValue(node, rv.Elem(), path, quoted)
+	}
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		if v := ToInterface(node); v != nil {
+			rv.Set(reflect.ValueOf(v))
+		} else {
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+	if node.GetNodeType() == NT_NULL {
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+	if rv.Type() == timeType {
+		s, ok := node.(*JsonString)
+		if !ok {
+			return unmarshalError(node, rv, path)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s.GetValue())
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal %q into time.Time%s. %s", s.GetValue(), unmarshalAt(path), err.Error())
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch rv.Type() {
+	case jsonNumberType:
+		if n, ok := node.(*JsonNumber); ok {
+			rv.SetString(n.GetLiteral())
+			return nil
+		}
+		return unmarshalError(node, rv, path)
+	case rawMessageType:
+		rv.SetBytes([]byte(valueJson(node)))
+		return nil
+	}
+	if rv.CanAddr() && rv.Addr().Type().Implements(jsonUnmarshalerType) {
+		if err := rv.Addr().Interface().(json.Unmarshaler).UnmarshalJSON([]byte(valueJson(node))); err != nil {
+			return fmt.Errorf("%s%s", err.Error(), unmarshalAt(path))
+		}
+		return nil
+	}
+	if quoted {
+		s, ok := node.(*JsonString)
+		if !ok {
+			return unmarshalError(node, rv, path)
+		}
+		if rv.Kind() == reflect.String {
+			v, err := Parse([]byte(s.GetValue()))
+			str, ok := v.(*JsonString)
+			if err != nil || !ok {
+				return fmt.Errorf("cannot unmarshal %q into %s%s. The value is not quoted", s.GetValue(), rv.Type(), unmarshalAt(path))
+			}
+			rv.SetString(str.GetValue())
+			return nil
+		}
+		n, err := Parse([]byte(s.GetValue()))
+		if err != nil || n.IsContainer() {
+			return fmt.Errorf("cannot unmarshal %q into %s%s", s.GetValue(), rv.Type(), unmarshalAt(path))
+		}
+		node = n
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if b, ok := node.(*JsonBool); ok {
+			rv.SetBool(b.GetValue())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := node.(*JsonNumber); ok {
+			i, err := n.GetInt64()
+			if err != nil || rv.OverflowInt(i) {
+				return fmt.Errorf("cannot unmarshal number %s into %s%s", n.GetLiteral(), rv.Type(), unmarshalAt(path))
+			}
+			rv.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := node.(*JsonNumber); ok {
+			i, err := n.GetUint64()
+			if err != nil || rv.OverflowUint(i) {
+				return fmt.Errorf("cannot unmarshal number %s into %s%s", n.GetLiteral(), rv.Type(), unmarshalAt(path))
+			}
+			rv.SetUint(i)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := node.(*JsonNumber); ok {
+			if rv.OverflowFloat(n.GetValue()) {
+				return fmt.Errorf("cannot unmarshal number %s into %s%s", n.GetLiteral(), rv.Type(), unmarshalAt(path))
+			}
+			rv.SetFloat(n.GetValue())
+			return nil
+		}
+	case reflect.String:
+		if s, ok := node.(*JsonString); ok {
+			rv.SetString(s.GetValue())
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := node.(*JsonString); ok && rv.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(s.GetValue())
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal string into %s%s. %s", rv.Type(), unmarshalAt(path), err.Error())
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		if l, ok := node.(*JsonList); ok {
+			s := reflect.MakeSlice(rv.Type(), l.Len(), l.Len())
+			for i, v := range l.GetValues() {
+				if err := unmarshalValue(v, s.Index(i), jpAppend(path, strconv.Itoa(i)), false); err != nil {
+					return err
+				}
+			}
+			rv.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if l, ok := node.(*JsonList); ok {
+			for i := 0; i < rv.Len(); i++ {
+				if i < l.Len() {
+					if err := unmarshalValue(l.GetNodeAt(i), rv.Index(i), jpAppend(path, strconv.Itoa(i)), false); err != nil {
+						return err
+					}
+				} else {
+					rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if o, ok := node.(*JsonObject); ok {
+			return unmarshalMap(o, rv, path)
+		}
+	case reflect.Struct:
+		if o, ok := node.(*JsonObject); ok {
+			return unmarshalStruct(o, rv, path)
+		}
+	}
+	return unmarshalError(node, rv, path)
+}
+
+func unmarshalAt(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return " at " + (&Path{path: path, delim: "."}).Pointer()
+}
+
+func unmarshalMap(o *JsonObject, rv reflect.Value, path []string) error {
+	kt := rv.Type().Key()
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rv.Type()))
+	}
+	for _, k := range o.keys {
+		kv := reflect.New(kt).Elem()
+		switch kt.Kind() {
+		case reflect.String:
+			kv.SetString(k)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(k, 10, 64)
+			if err != nil || kv.OverflowInt(i) {
+				return fmt.Errorf("cannot unmarshal key '%s' into %s%s", k, kt, unmarshalAt(path))
+			}
+			kv.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			i, err := strconv.ParseUint(k, 10, 64)
+			if err != nil || kv.OverflowUint(i) {
+				return fmt.Errorf("cannot unmarshal key '%s' into %s%s", k, kt, unmarshalAt(path))
+			}
+			kv.SetUint(i)
+		default:
+			return fmt.Errorf("cannot unmarshal into map key type %s%s", kt, unmarshalAt(path))
+		}
+		vv := reflect.New(rv.Type().Elem()).Elem()
+		if existing := rv.MapIndex(kv); existing.IsValid() {
+			vv.Set(existing)
+		}
+		if err := unmarshalValue(*o.value[k], vv, jpAppend(path, k), false); err != nil {
+			return err
+		}
+		rv.SetMapIndex(kv, vv)
+	}
+	return nil
+}
+
+func unmarshalStruct(o *JsonObject, rv reflect.Value, path []string) error {
+	fields := structFields(rv.Type())
+	for _, k := range o.keys {
+		var field *structField
+		for _, f := range fields {
+			if f.name == k {
+				field = f
+				break
+			}
+			if field == nil && strings.EqualFold(f.name, k) {
+				field = f
+			}
+		}
+		if field == nil {
+			continue
+		}
+		fv, ok := fieldByIndex(rv, field.index, true)
+		if !ok {
+			return fmt.Errorf("cannot set embedded pointer to unexported struct for '%s'%s", k, unmarshalAt(path))
+		}
+		if err := unmarshalValue(*o.value[k], fv, jpAppend(path, k), field.quoted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// How DeepMerge combines two lists
+type ListStrategy int
+
+const (
+	LIST_REPLACE      ListStrategy = iota // The src list replaces the dst list
+	LIST_APPEND                           // The src values are added to the end of the dst list
+	LIST_MERGE_BY_KEY                     // Objects with the same Key value are merged. Others are appended
+)
+
+// Called by DeepMerge when dst and src both have a value at path that cannot be merged.
+// Return the node to use (for example dst or src) or an error to stop the merge.
+type MergeConflict func(path *Path, dst, src NodeI) (NodeI, error)
+
+type MergeOptions struct {
+	Lists      ListStrategy
+	Key        string        // The identity key for LIST_MERGE_BY_KEY
+	OnConflict MergeConflict // If nil the src value is used
+}
+
+// Apply a json merge patch (RFC 7386) to target. Objects in patch are merged in to target,
+// a null value removes a node and any other value replaces the node.
+// target is changed. The result is returned because it is a new node if the patch is not an object
+// or target is not an object.
+func MergePatch(target, patch NodeI) NodeI {
+	p, ok := patch.(*JsonObject)
+	if !ok {
+		return Clone(patch, target.GetName(), true)
+	}
+	t, ok := target.(*JsonObject)
+	if !ok {
+		t = NewJsonObject(target.GetName())
+	}
+	for _, k := range p.keys {
+		v := *p.value[k]
+		existing := t.GetNodeWithName(k)
+		if v.GetNodeType() == NT_NULL {
+			if existing != nil {
+				t.removeFromParent(existing)
+			}
+			continue
+		}
+		if existing == nil {
+			existing = NewJsonNull(k)
+		}
+		mergePut(t, MergePatch(existing, v))
+	}
+	return t
+}
+
+// Return a merge patch that changes from into to. MergePatch(from, CreateMergePatch(from, to))
+// equals to unless to contains null values in objects. These cannot be represented.
+func CreateMergePatch(from, to NodeI) NodeI {
+	f, ok1 := from.(*JsonObject)
+	t, ok2 := to.(*JsonObject)
+	if !ok1 || !ok2 {
+		return Clone(to, "", true)
+	}
+	patch := NewJsonObject("")
+	for _, k := range f.keys {
+		if _, ok := t.value[k]; !ok {
+			patch.Add(NewJsonNull(k))
+		}
+	}
+	for _, k := range t.keys {
+		tv := *t.value[k]
+		fv, ok := f.value[k]
+		if !ok {
+			patch.Add(Clone(tv, k, true))
+		} else if !EqualValues(*fv, tv) {
+			p := CreateMergePatch(*fv, tv)
+			p.setName(k)
+			patch.Add(p)
+		}
+	}
+	return patch
+}
+
+// Merge src in to a copy of dst and return the copy. Neither dst nor src are changed.
+// Objects are merged by name. Lists are combined using options.Lists.
+// When both have a different value at the same path options.OnConflict is called.
+func DeepMerge(dst, src NodeI, options MergeOptions) (NodeI, error) {
+	if options.Lists == LIST_MERGE_BY_KEY && options.Key == "" {
+		return nil, fmt.Errorf("a Key is required for LIST_MERGE_BY_KEY")
+	}
+	return deepMerge(Clone(dst, dst.GetName(), true), src, make([]string, 0), &options)
+}
+
+func deepMerge(dst, src NodeI, path []string, options *MergeOptions) (NodeI, error) {
+	switch d := dst.(type) {
+	case *JsonObject:
+		if s, ok := src.(*JsonObject); ok {
+			for _, k := range s.keys {
+				sv := *s.value[k]
+				dv, found := d.value[k]
+				if !found {
+					d.Add(Clone(sv, k, true))
+					continue
+				}
+				merged, err := deepMerge(*dv, sv, jpAppend(path, k), options)
+				if err != nil {
+					return nil, err
+				}
+				if err := mergePut(d, merged); err != nil {
+					return nil, err
+				}
+			}
+			return d, nil
+		}
+	case *JsonList:
+		if s, ok := src.(*JsonList); ok {
+			return mergeLists(d, s, path, options)
+		}
+	}
+	if EqualValues(dst, src) {
+		return dst, nil
+	}
+	if options.OnConflict == nil {
+		return Clone(src, dst.GetName(), true), nil
+	}
+	n, err := options.OnConflict(&Path{path: path, delim: "."}, dst, src)
+	if err != nil {
+		return nil, err
+	}
+	if n == dst {
+		return dst, nil
+	}
+	return Clone(n, dst.GetName(), true), nil
+}
+
+func mergeLists(dst, src *JsonList, path []string, options *MergeOptions) (NodeI, error) {
+	switch options.Lists {
+	case LIST_APPEND:
+		for _, v := range src.value {
+			dst.Add(Clone(*v, (*v).GetName(), true))
+		}
+	case LIST_MERGE_BY_KEY:
+		for _, v := range src.value {
+			i := indexOfKeyValue(dst, *v, options.Key)
+			if i < 0 {
+				dst.Add(Clone(*v, (*v).GetName(), true))
+				continue
+			}
+			merged, err := deepMerge(*dst.value[i], *v, jpAppend(path, strconv.Itoa(i)), options)
+			if err != nil {
+				return nil, err
+			}
+			if merged != *dst.value[i] {
+				dst.RemoveAt(i)
+				dst.InsertAt(i, merged)
+			}
+		}
+	default:
+		return Clone(src, dst.GetName(), true), nil
+	}
+	return dst, nil
+}
+
+// Return the index of the object in list with the same key value as node. Return -1 if not found
+func indexOfKeyValue(list *JsonList, node NodeI, key string) int {
+	o, ok := node.(*JsonObject)
+	if !ok {
+		return -1
+	}
+	kv := o.GetNodeWithName(key)
+	if kv == nil {
+		return -1
+	}
+	for i, v := range list.value {
+		if lo, ok := (*v).(*JsonObject); ok {
+			if lv := lo.GetNodeWithName(key); lv != nil && EqualValues(lv, kv) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Add node to the object. An existing node with the same name is replaced in the same position.
+func mergePut(o *JsonObject, node NodeI) error {
+	name := node.GetName()
+	old := o.GetNodeWithName(name)
+	if old == node {
+		return nil
+	}
+	next := ""
+	if old != nil {
+		if i := o.indexOfKey(name); i+1 < len(o.keys) {
+			next = o.keys[i+1]
+		}
+		if err := o.Remove(old); err != nil {
+			return err
+		}
+	}
+	if _, err := o.Add(node); err != nil {
+		return err
+	}
+	if next != "" {
+		return o.MoveBefore(name, next)
+	}
+	return nil
+}
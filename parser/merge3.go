@@ -0,0 +1,233 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// The side used for a conflict in a three way merge
+type MergeSide int
+
+const (
+	MERGE_OURS MergeSide = iota
+	MERGE_THEIRS
+)
+
+// The name of the annotation object used when ThreeWayOptions.ConflictMarkers is true
+const CONFLICT_KEY = "$conflict"
+
+// A node changed in different ways by ours and theirs. Base, Ours or Theirs is nil
+// if the node is not in that tree.
+type Conflict struct {
+	Path   *Path
+	Base   NodeI
+	Ours   NodeI
+	Theirs NodeI
+}
+
+type ThreeWayOptions struct {
+	Favour          MergeSide // The side used for a conflict if ConflictMarkers is false
+	ConflictMarkers bool      // Replace a conflict with {"$conflict": {"base": .., "ours": .., "theirs": ..}}
+	IdentityKey     string    // Match objects in lists that have the same value for this key
+}
+
+func (c *Conflict) String() string {
+	side := func(n NodeI) string {
+		if n == nil {
+			return "(none)"
+		}
+		return valueJson(n)
+	}
+	return fmt.Sprintf("conflict %s: base %s ours %s theirs %s", c.Path.Pointer(), side(c.Base), side(c.Ours), side(c.Theirs))
+}
+
+// Merge the changes made to base by ours and theirs. A change made by only one side is used.
+// Objects are merged by name. Lists are merged by IdentityKey if it is set, otherwise by position.
+// A list merged by position can be made longer or shorter by only one side. Any other different changes are conflicts.
+// The result is a new tree. base, ours and theirs are not changed.
+func ThreeWayMerge(base, ours, theirs NodeI, options ThreeWayOptions) (NodeI, []*Conflict) {
+	m := &merger3{options: options, conflicts: make([]*Conflict, 0)}
+	return m.merge(base, ours, theirs, make([]string, 0), ours.GetName()), m.conflicts
+}
+
+type merger3 struct {
+	options   ThreeWayOptions
+	conflicts []*Conflict
+}
+
+func sameValue(a, b NodeI) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return EqualValues(a, b)
+}
+
+func mergeCopy(n NodeI, name string) NodeI {
+	if n == nil {
+		return nil
+	}
+	return Clone(n, name, true)
+}
+
+// Return the merged node or nil if the node was removed
+func (m *merger3) merge(base, ours, theirs NodeI, path []string, name string) NodeI {
+	switch {
+	case sameValue(ours, theirs):
+		return mergeCopy(ours, name)
+	case sameValue(base, ours):
+		return mergeCopy(theirs, name)
+	case sameValue(base, theirs):
+		return mergeCopy(ours, name)
+	}
+	switch o := ours.(type) {
+	case *JsonObject:
+		if t, ok := theirs.(*JsonObject); ok {
+			b, _ := base.(*JsonObject)
+			return m.mergeObjects(b, o, t, path, name)
+		}
+	case *JsonList:
+		if t, ok := theirs.(*JsonList); ok {
+			if b, ok := base.(*JsonList); ok {
+				if r := m.mergeLists(b, o, t, path, name); r != nil {
+					return r
+				}
+			}
+		}
+	}
+	return m.conflict(base, ours, theirs, path, name)
+}
+
+func (m *merger3) mergeObjects(base, ours, theirs *JsonObject, path []string, name string) NodeI {
+	result := NewJsonObject(name)
+	get := func(o *JsonObject, k string) NodeI {
+		if o == nil {
+			return nil
+		}
+		return o.GetNodeWithName(k)
+	}
+	keys := append(make([]string, 0), ours.keys...)
+	for _, k := range theirs.keys {
+		if _, ok := ours.value[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if r := m.merge(get(base, k), get(ours, k), get(theirs, k), jpAppend(path, k), k); r != nil {
+			result.Add(r)
+		}
+	}
+	return result
+}
+
+// Return the merged list or nil if the lists cannot be merged
+func (m *merger3) mergeLists(base, ours, theirs *JsonList, path []string, name string) NodeI {
+	result := NewJsonList(name)
+	if m.options.IdentityKey != "" && m.allKeyed(base, ours, theirs) {
+		get := func(l *JsonList, n NodeI) NodeI {
+			if i := indexOfKeyValue(l, n, m.options.IdentityKey); i >= 0 {
+				return l.GetNodeAt(i)
+			}
+			return nil
+		}
+		items := append(make([]NodeI, 0), ours.GetValues()...)
+		for _, v := range theirs.GetValues() {
+			if get(ours, v) == nil {
+				items = append(items, v)
+			}
+		}
+		// Values removed by one side and not changed by the other are dropped by merge
+		for _, v := range items {
+			p := jpAppend(path, strconv.Itoa(result.Len()))
+			if r := m.merge(get(base, v), get(ours, v), get(theirs, v), p, v.GetName()); r != nil {
+				result.Add(r)
+			}
+		}
+		return result
+	}
+	// Only one side can change the length. The values past the end of a list are nil
+	// so a value appended or removed by one side is merged like a new or removed member.
+	if ours.Len() != base.Len() && theirs.Len() != base.Len() && ours.Len() != theirs.Len() {
+		return nil
+	}
+	get := func(l *JsonList, i int) NodeI {
+		if i < l.Len() {
+			return l.GetNodeAt(i)
+		}
+		return nil
+	}
+	size := base.Len()
+	if ours.Len() > size {
+		size = ours.Len()
+	}
+	if theirs.Len() > size {
+		size = theirs.Len()
+	}
+	for i := 0; i < size; i++ {
+		b, o, t := get(base, i), get(ours, i), get(theirs, i)
+		name := ""
+		for _, n := range []NodeI{o, t, b} {
+			if n != nil {
+				name = n.GetName()
+				break
+			}
+		}
+		if r := m.merge(b, o, t, jpAppend(path, strconv.Itoa(i)), name); r != nil {
+			result.Add(r)
+		}
+	}
+	return result
+}
+
+// Return true if every value in the lists is an object with an IdentityKey
+func (m *merger3) allKeyed(lists ...*JsonList) bool {
+	for _, l := range lists {
+		for _, v := range l.GetValues() {
+			o, ok := v.(*JsonObject)
+			if !ok || o.GetNodeWithName(m.options.IdentityKey) == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (m *merger3) conflict(base, ours, theirs NodeI, path []string, name string) NodeI {
+	if len(path) == 0 {
+		path = parserEmptyPath
+	}
+	m.conflicts = append(m.conflicts, &Conflict{Path: &Path{path: path, delim: "."}, Base: base, Ours: ours, Theirs: theirs})
+	if m.options.ConflictMarkers {
+		sides := NewJsonObject(CONFLICT_KEY)
+		for _, s := range []struct {
+			name string
+			node NodeI
+		}{{"base", base}, {"ours", ours}, {"theirs", theirs}} {
+			if s.node != nil {
+				sides.Add(Clone(s.node, s.name, true))
+			}
+		}
+		annotation := NewJsonObject(name)
+		annotation.Add(sides)
+		return annotation
+	}
+	if m.options.Favour == MERGE_THEIRS {
+		return mergeCopy(theirs, name)
+	}
+	return mergeCopy(ours, name)
+}
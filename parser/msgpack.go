@@ -0,0 +1,431 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	mpNil       = 0xc0
+	mpFalse     = 0xc2
+	mpTrue      = 0xc3
+	mpBin8      = 0xc4
+	mpBin16     = 0xc5
+	mpBin32     = 0xc6
+	mpExt8      = 0xc7
+	mpExt16     = 0xc8
+	mpExt32     = 0xc9
+	mpFloat32   = 0xca
+	mpFloat64   = 0xcb
+	mpUint8     = 0xcc
+	mpUint16    = 0xcd
+	mpUint32    = 0xce
+	mpUint64    = 0xcf
+	mpInt8      = 0xd0
+	mpInt16     = 0xd1
+	mpInt32     = 0xd2
+	mpInt64     = 0xd3
+	mpFixExt1   = 0xd4
+	mpFixExt16  = 0xd8
+	mpStr8      = 0xd9
+	mpStr16     = 0xda
+	mpStr32     = 0xdb
+	mpArray16   = 0xdc
+	mpArray32   = 0xdd
+	mpMap16     = 0xde
+	mpMap32     = 0xdf
+	mpTimestamp = -1 // The extension type for a timestamp
+)
+
+// Return the node encoded as MessagePack.
+//
+// Numbers written without a fraction or an exponent are integers in the smallest format. Integers
+// outside the 64 bit range are an error. Other numbers are a float 32 if that holds the value or a
+// float 64. Strings are str unless options.BytesPrefix is set.
+func MsgPackValue(n NodeI, options BinaryOptions) ([]byte, error) {
+	w := &msgPackWriter{options: options}
+	if err := w.write(n, parserEmptyPath); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+type msgPackWriter struct {
+	buf     bytes.Buffer
+	options BinaryOptions
+}
+
+// Write a type byte followed by a length or value of 1, 2, 4 or 8 bytes
+func (w *msgPackWriter) typed(t byte, v uint64, size int) {
+	var b [8]byte
+	w.buf.WriteByte(t)
+	binary.BigEndian.PutUint64(b[:], v)
+	w.buf.Write(b[8-size:])
+}
+
+// Write the type for a length. fix is the fix type for lengths below fixMax. t8, t16 and t32 are 0 if not used.
+func (w *msgPackWriter) length(n int, fix byte, fixMax int, t8, t16, t32 byte) {
+	switch {
+	case n < fixMax:
+		w.buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint8 && t8 != 0:
+		w.typed(t8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		w.typed(t16, uint64(n), 2)
+	default:
+		w.typed(t32, uint64(n), 4)
+	}
+}
+
+func (w *msgPackWriter) str(s string) {
+	w.length(len(s), 0xa0, 32, mpStr8, mpStr16, mpStr32)
+	w.buf.WriteString(s)
+}
+
+func (w *msgPackWriter) write(n NodeI, path []string) error {
+	switch v := n.(type) {
+	case *JsonObject:
+		w.length(v.Len(), 0x80, 16, 0, mpMap16, mpMap32)
+		keys := binaryKeys(v, w.options.Deterministic, func(k string) []byte {
+			kw := &msgPackWriter{}
+			kw.str(k)
+			return kw.buf.Bytes()
+		})
+		for _, k := range keys {
+			w.str(k)
+			if err := w.write(*v.value[k], jpAppend(path, k)); err != nil {
+				return err
+			}
+		}
+	case *JsonList:
+		w.length(v.Len(), 0x90, 16, 0, mpArray16, mpArray32)
+		for i, item := range v.GetValues() {
+			if err := w.write(item, jpAppend(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case *JsonString:
+		if b := binaryBytesValue(v.GetValue(), w.options); b != nil {
+			w.length(len(b), 0, 0, mpBin8, mpBin16, mpBin32)
+			w.buf.Write(b)
+		} else {
+			w.str(v.GetValue())
+		}
+	case *JsonNumber:
+		return w.number(v, path)
+	case *JsonBool:
+		if v.GetValue() {
+			w.buf.WriteByte(mpTrue)
+		} else {
+			w.buf.WriteByte(mpFalse)
+		}
+	case *JsonNull:
+		w.buf.WriteByte(mpNil)
+	}
+	return nil
+}
+
+func (w *msgPackWriter) number(n *JsonNumber, path []string) error {
+	if i := binaryInteger(n); i != nil {
+		switch {
+		case i.IsInt64() && i.Int64() < 0:
+			v := i.Int64()
+			switch {
+			case v >= -32:
+				w.buf.WriteByte(byte(int8(v)))
+			case v >= math.MinInt8:
+				w.typed(mpInt8, uint64(v), 1)
+			case v >= math.MinInt16:
+				w.typed(mpInt16, uint64(v), 2)
+			case v >= math.MinInt32:
+				w.typed(mpInt32, uint64(v), 4)
+			default:
+				w.typed(mpInt64, uint64(v), 8)
+			}
+		case i.IsUint64():
+			v := i.Uint64()
+			switch {
+			case v < 128:
+				w.buf.WriteByte(byte(v))
+			case v <= math.MaxUint8:
+				w.typed(mpUint8, v, 1)
+			case v <= math.MaxUint16:
+				w.typed(mpUint16, v, 2)
+			case v <= math.MaxUint32:
+				w.typed(mpUint32, v, 4)
+			default:
+				w.typed(mpUint64, v, 8)
+			}
+		default:
+			return binaryPathError(path, "the integer %s is too large for msgpack", n.GetLiteral())
+		}
+		return nil
+	}
+	f := n.GetValue()
+	if math.IsInf(f, 0) {
+		return binaryPathError(path, "the number %s is too large for a float", n.GetLiteral())
+	}
+	if float64(float32(f)) == f {
+		w.typed(mpFloat32, uint64(math.Float32bits(float32(f))), 4)
+	} else {
+		w.typed(mpFloat64, math.Float64bits(f), 8)
+	}
+	return nil
+}
+
+// Parse a MessagePack value.
+//
+// Integers are JsonNumber with the exact value. Floats are JsonNumber with a fraction or exponent so they
+// are written back as floats. bin is a JsonString of the base64 of the bytes (see BinaryOptions). The
+// timestamp extension is a JsonString in RFC 3339 format. Other extensions are an error. Map keys must be
+// strings or integers. Errors are a *ParseError with the Offset of the value.
+func ParseMsgPack(data []byte, options BinaryOptions) (NodeI, error) {
+	r := &msgPackReader{data: data, options: options}
+	n, err := r.value("")
+	if err != nil {
+		return nil, err
+	}
+	if r.pos < len(r.data) {
+		return nil, r.error(r.pos, "unexpected data after the value")
+	}
+	return n, nil
+}
+
+type msgPackReader struct {
+	data    []byte
+	pos     int
+	depth   int
+	options BinaryOptions
+}
+
+func (r *msgPackReader) error(offset int, format string, args ...interface{}) *ParseError {
+	return &ParseError{Msg: fmt.Sprintf(format, args...), Offset: offset, Binary: true}
+}
+
+func (r *msgPackReader) take(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, r.error(len(r.data), "unexpected end of the data")
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// Read a big endian unsigned integer of size bytes
+func (r *msgPackReader) uint(size int) (uint64, error) {
+	b, err := r.take(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (r *msgPackReader) value(name string) (NodeI, error) {
+	start := r.pos
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > BINARY_MAX_DEPTH {
+		return nil, r.error(start, "the data is nested more than %d levels", BINARY_MAX_DEPTH)
+	}
+	b, err := r.take(1)
+	if err != nil {
+		return nil, err
+	}
+	t := b[0]
+	switch {
+	case t < 0x80:
+		return NewJsonNumberFromLiteral(name, strconv.Itoa(int(t)))
+	case t < 0x90:
+		return r.object(name, uint64(t&0x0f))
+	case t < 0xa0:
+		return r.array(name, uint64(t&0x0f))
+	case t < 0xc0:
+		return r.str(name, uint64(t&0x1f), start)
+	case t >= 0xe0:
+		return NewJsonNumberFromLiteral(name, strconv.Itoa(int(int8(t))))
+	}
+	switch t {
+	case mpNil:
+		return NewJsonNull(name), nil
+	case mpFalse, mpTrue:
+		return NewJsonBool(name, t == mpTrue), nil
+	case mpBin8, mpBin16, mpBin32:
+		n, err := r.uint(1 << (t - mpBin8))
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return binaryBytes(name, b, r.options), nil
+	case mpExt8, mpExt16, mpExt32:
+		n, err := r.uint(1 << (t - mpExt8))
+		if err != nil {
+			return nil, err
+		}
+		return r.ext(name, n, start)
+	case mpFloat32, mpFloat64:
+		size := 4
+		if t == mpFloat64 {
+			size = 8
+		}
+		v, err := r.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		f := math.Float64frombits(v)
+		if t == mpFloat32 {
+			f = float64(math.Float32frombits(uint32(v)))
+		}
+		n, err := binaryFloat(name, f)
+		if err != nil {
+			return nil, r.error(start, "%s", err.Error())
+		}
+		return n, nil
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		v, err := r.uint(1 << (t - mpUint8))
+		if err != nil {
+			return nil, err
+		}
+		return NewJsonNumberFromLiteral(name, strconv.FormatUint(v, 10))
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		size := 1 << (t - mpInt8)
+		v, err := r.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend to 64 bits
+		shift := 64 - 8*size
+		return NewJsonNumberFromLiteral(name, strconv.FormatInt(int64(v<<shift)>>shift, 10))
+	case mpFixExt1, mpFixExt1 + 1, mpFixExt1 + 2, mpFixExt1 + 3, mpFixExt16:
+		return r.ext(name, 1<<(t-mpFixExt1), start)
+	case mpStr8, mpStr16, mpStr32:
+		n, err := r.uint(1 << (t - mpStr8))
+		if err != nil {
+			return nil, err
+		}
+		return r.str(name, n, start)
+	case mpArray16, mpArray32:
+		n, err := r.uint(2 << (t - mpArray16))
+		if err != nil {
+			return nil, err
+		}
+		return r.array(name, n)
+	case mpMap16, mpMap32:
+		n, err := r.uint(2 << (t - mpMap16))
+		if err != nil {
+			return nil, err
+		}
+		return r.object(name, n)
+	}
+	return nil, r.error(start, "the type 0x%x is not valid", t)
+}
+
+func (r *msgPackReader) str(name string, n uint64, start int) (NodeI, error) {
+	b, err := r.take(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, r.error(start, "a string is not valid utf-8")
+	}
+	return NewJsonString(name, string(b)), nil
+}
+
+// Read an extension with n bytes of data. Only the timestamp extension is supported.
+func (r *msgPackReader) ext(name string, n uint64, start int) (NodeI, error) {
+	t, err := r.take(1)
+	if err != nil {
+		return nil, err
+	}
+	if int8(t[0]) != mpTimestamp {
+		return nil, r.error(start, "the extension type %d is not supported", int8(t[0]))
+	}
+	var sec, nsec uint64
+	switch n {
+	case 4:
+		sec, err = r.uint(4)
+	case 8:
+		sec, err = r.uint(8)
+		nsec, sec = sec>>34, sec&(1<<34-1)
+	case 12:
+		if nsec, err = r.uint(4); err == nil {
+			sec, err = r.uint(8)
+		}
+	default:
+		return nil, r.error(start, "a timestamp cannot have %d bytes", n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewJsonString(name, time.Unix(int64(sec), int64(nsec)).UTC().Format(time.RFC3339Nano)), nil
+}
+
+func (r *msgPackReader) array(name string, count uint64) (NodeI, error) {
+	// Every value is at least one byte
+	if count > uint64(len(r.data)-r.pos) {
+		return nil, r.error(len(r.data), "unexpected end of the data")
+	}
+	list := NewJsonList(name)
+	for i := uint64(0); i < count; i++ {
+		n, err := r.value("")
+		if err != nil {
+			return nil, err
+		}
+		list.Add(n)
+	}
+	return list, nil
+}
+
+func (r *msgPackReader) object(name string, count uint64) (NodeI, error) {
+	if count > uint64(len(r.data)-r.pos)/2 {
+		return nil, r.error(len(r.data), "unexpected end of the data")
+	}
+	obj := NewJsonObject(name)
+	for i := uint64(0); i < count; i++ {
+		start := r.pos
+		k, err := r.value("")
+		if err != nil {
+			return nil, err
+		}
+		key, err := binaryKey(k)
+		if err != nil {
+			return nil, r.error(start, "%s", err.Error())
+		}
+		if obj.GetNodeWithName(key) != nil {
+			return nil, r.error(start, "duplicate map key '%s'", key)
+		}
+		n, err := r.value(key)
+		if err != nil {
+			return nil, err
+		}
+		obj.Add(n)
+	}
+	return obj, nil
+}
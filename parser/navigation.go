@@ -146,7 +146,7 @@ func diagStr(node NodeI, nt NodeType, indent int, sb *strings.Builder) {
 	case NT_BOOL:
 		sb.WriteString(fmt.Sprintf("V:'%t'\n", (node.(*JsonBool)).GetValue()))
 	case NT_NUMBER:
-		sb.WriteString(fmt.Sprintf("V:'%f'\n", (node.(*JsonNumber)).GetValue()))
+		sb.WriteString(fmt.Sprintf("V:'%s'\n", (node.(*JsonNumber)).GetLiteral()))
 	case NT_NULL:
 		sb.WriteString("\n")
 	case NT_OBJECT:
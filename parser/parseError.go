@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError is returned by Parse and ParseReader when the input is not valid json.
+// Use errors.As to get the details:
+//
+//	var pe *parser.ParseError
+//	if errors.As(err, &pe) {
+//	    fmt.Printf("line %d column %d", pe.Line, pe.Column)
+//	}
+type ParseError struct {
+	Msg      string      // What went wrong
+	Offset   int         // Offset in bytes from the start of the input
+	Line     int         // Line number starting at 1
+	Column   int         // Column number starting at 1 (in bytes)
+	Token    *Token      // The offending token. nil if the error was found inside a token
+	Expected []TokenType // The tokens that would have been valid at Offset. Empty if not known
+	Context  string      // Input text around Offset. A '|' marks the Offset
+	Err      error       // The underlying error. For example an error from the io.Reader
+	Binary   bool        // The input is CBOR or MessagePack. Only Offset is set
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("parser Error: ")
+	sb.WriteString(e.Msg)
+	if e.Binary {
+		sb.WriteString(fmt.Sprintf(". Offset: %d.", e.Offset))
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf(". Line: %d Column: %d Offset: %d.", e.Line, e.Column, e.Offset))
+	if len(e.Expected) > 0 {
+		sb.WriteString(" Expected: ")
+		sb.WriteString(e.ExpectedNames())
+		sb.WriteString(".")
+	}
+	sb.WriteString(" About here >>>")
+	sb.WriteString(e.Context)
+	sb.WriteString("<<<")
+	return sb.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Return the names of the expected tokens separated by ' or '
+func (e *ParseError) ExpectedNames() string {
+	names := make([]string, len(e.Expected))
+	for i, tt := range e.Expected {
+		names[i] = GetTokenTypeName(tt)
+	}
+	return strings.Join(names, " or ")
+}
+
+// Return a ParseError for an offset in a text that is held in memory. lineStart is the offset of the start of the line.
+func textError(text []byte, offset, line, lineStart int, msg string) *ParseError {
+	f := offset - diagContext
+	if f < 0 {
+		f = 0
+	}
+	t := offset + diagContext
+	if t > len(text) {
+		t = len(text)
+	}
+	return &ParseError{Msg: msg, Offset: offset, Line: line, Column: offset - lineStart + 1, Context: fmt.Sprintf("%s|%s", text[f:offset], text[offset:t])}
+}
@@ -265,7 +265,11 @@ func (p *Path) PathParent() *Path {
 // Return a new path with a single element at N from the source path
 //
 func (p *Path) PathAt(i int) *Path {
-	return NewPath(p.StringAt(i), p.delim)
+	s := p.StringAt(i)
+	if s == "" {
+		return &Path{parserEmptyPath, p.delim}
+	}
+	return &Path{[]string{s}, p.delim}
 }
 
 func (p *Path) StringAt(i int) string {
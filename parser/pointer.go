@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Json Pointer (RFC 6901) support.
+//
+// A pointer is a string like "/address/phoneNumbers/0". Each element is
+// preceded by a '/'. A '~' in a name is written as "~0" and a '/' as "~1"
+// so any name can be used. For example the name "api.v1/x" is "/api.v1~1x".
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// Return a Path from a json pointer. The Path delim is '.' but the names in the
+// path can contain any chars including '.'. The pointer "" is the empty path.
+func NewPointerPath(pointer string) (*Path, error) {
+	if pointer == "" {
+		return NewDotPath(""), nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer '%s'. Must be empty or start with a '/'", pointer)
+	}
+	elements := strings.Split(pointer[1:], "/")
+	for i, e := range elements {
+		for j := 0; j < len(e); j++ {
+			if e[j] == '~' && (j+1 >= len(e) || (e[j+1] != '0' && e[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid json pointer '%s'. '~' must be followed by '0' or '1'", pointer)
+			}
+		}
+		elements[i] = pointerUnescaper.Replace(e)
+	}
+	return &Path{path: elements, delim: "."}, nil
+}
+
+// Return the path as a json pointer. For example the path "a.b.0" returns "/a/b/0".
+func (p *Path) Pointer() string {
+	var sb strings.Builder
+	for _, v := range p.path {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(v))
+	}
+	return sb.String()
+}
+
+// Find a node using a json pointer. The pointer "" returns node.
+func FindPointer(node NodeI, pointer string) (NodeI, error) {
+	path, err := NewPointerPath(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if path.IsEmpty() {
+		return node, nil
+	}
+	return Find(node, path)
+}
+
+// The same as CreateAndReturnNodeAtPath using a json pointer.
+// Existing nodes in lists can be addressed by index. For example "/list/0/name".
+// A node is added to the end of a list with the index "-" or the length of the list.
+// Any other index that does not exist is an error.
+func CreateAndReturnNodeAtPointer(root NodeI, pointer string, nodeType NodeType) (NodeI, error) {
+	path, err := NewPointerPath(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := Find(root, path); err == nil && !path.IsEmpty() {
+		if n.GetNodeType() != nodeType {
+			return nil, fmt.Errorf("found node at [%s] but it is not a %s node", pointer, GetNodeTypeName(nodeType))
+		}
+		return n, nil
+	}
+	//
+	// Start from the deepest existing container so list indexes are resolved by Find
+	//
+	for i := path.Len() - 1; i >= 0; i-- {
+		n := root
+		if i > 0 {
+			if n, err = Find(root, path.PathFirst(i)); err != nil {
+				continue
+			}
+		}
+		if !n.IsContainer() {
+			continue
+		}
+		rest := path.PathLast(path.Len() - i)
+		if l, ok := n.(*JsonList); ok {
+			return createInList(l, rest, nodeType)
+		}
+		return CreateAndReturnNodeAtPath(n, rest, nodeType)
+	}
+	return CreateAndReturnNodeAtPath(root, path, nodeType)
+}
+
+// Append a node to a list for the first element of the path and create the rest of the path in it
+func createInList(l *JsonList, path *Path, nodeType NodeType) (NodeI, error) {
+	index := path.StringFirst()
+	if index != "-" && index != strconv.Itoa(l.Len()) {
+		return nil, fmt.Errorf("index [%s] does not exist in a list of length %d. Use '-' or %d to add a node", index, l.Len(), l.Len())
+	}
+	if path.Len() == 1 {
+		return l.Add(NewJsonType("", nodeType))
+	}
+	n, err := l.Add(NewJsonObject(""))
+	if err != nil {
+		return nil, err
+	}
+	return CreateAndReturnNodeAtPath(n, path.PathLast(path.Len()-1), nodeType)
+}
+
+// Return the json pointer to the node from the root of its tree.
+// Nodes in lists are identified by their index.
+func PointerTo(node NodeI) string {
+	return PathTo(node).Pointer()
+}
+
+// Return the Path to the node from the root of its tree.
+// Nodes in lists are identified by their index.
+func PathTo(node NodeI) *Path {
+	elements := make([]string, 0)
+	for p := node.GetParent(); p != nil; p = p.GetParent() {
+		if p.GetNodeType() == NT_LIST {
+			elements = append(elements, fmt.Sprintf("%d", indexInList(p.(*JsonList), node)))
+		} else {
+			elements = append(elements, node.GetName())
+		}
+		node = p
+	}
+	return (&Path{path: elements, delim: "."}).BackToFront()
+}
+
+func indexInList(list *JsonList, node NodeI) int {
+	for i, v := range list.value {
+		if *v == node {
+			return i
+		}
+	}
+	return -1
+}
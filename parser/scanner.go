@@ -17,56 +17,141 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type Token struct {
 	text string
 	pos  int
+	line int
+	col  int
 	tok  TokenType
 }
 
+const (
+	readerBufferSize = 4096 // Default buffer size for a Scanner reading from an io.Reader
+	diagContext      = 20   // Chars either side of the current position shown by Diag
+)
+
 type Scanner struct {
-	text []byte
-	pos  int
-	max  int
+	text          []byte
+	pos           int
+	max           int
+	reader        io.Reader
+	base          int // Offset in the input of text[0]. Only moves when reading from an io.Reader
+	mark          int // Offset in the input that must be kept in the buffer (for PeekToken). -1 if none
+	eof           bool
+	err           error // The error returned by the reader (other than io.EOF)
+	line          int   // Line number at pos. Starts at 1
+	lineStart     int   // Offset in the input of the start of the current line
+	prevLineStart int   // Offset of the start of the previous line. Used by Back
+	validateUTF8  bool  // Return an error if a quoted string is not valid UTF-8
+	strict        bool  // Return an error for a \x escape or an unescaped control character in a quoted string
 }
 
 func NewScanner(s []byte) *Scanner {
-	return &Scanner{text: s, pos: 0, max: len(s)}
+	return &Scanner{text: s, pos: 0, max: len(s), mark: -1, eof: true, line: 1}
+}
+
+// Return a Scanner that reads from r as required. Only a window of the input
+// is held in memory so large documents do not need to be read in to a []byte first.
+func NewReaderScanner(r io.Reader) *Scanner {
+	return NewReaderScannerSize(r, readerBufferSize)
+}
+
+func NewReaderScannerSize(r io.Reader, size int) *Scanner {
+	if size < 1 {
+		size = readerBufferSize
+	}
+	return &Scanner{text: make([]byte, size), pos: 0, max: 0, reader: r, mark: -1, line: 1}
+}
+
+// If validate is true then quoted strings that are not valid UTF-8 return an error from NextToken.
+// Strict checks are also made (see SetStrict).
+func (s *Scanner) SetValidateUTF8(validate bool) *Scanner {
+	s.validateUTF8 = validate
+	return s
+}
+
+// If strict is true then a \x escape or a control character (below 0x20) that is not escaped
+// in a quoted string returns an error from NextToken. By default they are accepted.
+func (s *Scanner) SetStrict(strict bool) *Scanner {
+	s.strict = strict
+	return s
+}
+
+// Return the offset of the next char in the input
+func (s *Scanner) Pos() int {
+	return s.base + s.pos
+}
+
+// Return the line number (from 1) of the next char in the input
+func (s *Scanner) Line() int {
+	return s.line
+}
+
+// Return the column number (from 1) of the next char in the input. Columns are counted in bytes.
+func (s *Scanner) Column() int {
+	return s.Pos() - s.lineStart + 1
 }
 
 func (s *Scanner) Diag(tok string) string {
-	f := s.pos - 20
+	return fmt.Sprintf("Scanner: pos: %d len: %d. About here >>>%s<<<", s.Pos(), s.base+s.max, s.context(s.Pos()-len(tok)))
+}
+
+// Return the text around offset in the input with a '|' at offset.
+// Only text still held in the buffer can be shown.
+func (s *Scanner) context(offset int) string {
+	s.ensure(diagContext)
+	p := offset - s.base
+	if p < 0 {
+		p = 0
+	}
+	if p > s.max {
+		p = s.max
+	}
+	f := p - diagContext
 	if f < 0 {
 		f = 0
 	}
-	t := f + 40
+	t := p + diagContext
 	if t > s.max {
 		t = s.max
 	}
-	p := s.pos - len(tok)
-	return fmt.Sprintf("Scanner: pos: %d len: %d. About here >>>%s|%s<<<", s.pos, s.max, s.text[f:p], s.text[p:t])
+	return fmt.Sprintf("%s|%s", s.text[f:p], s.text[p:t])
 }
 
 func (s *Scanner) Next() byte {
-	if s.pos < s.max {
+	if s.HasNext() {
 		c := s.text[s.pos]
 		s.pos++
+		if c == '\n' {
+			s.prevLineStart = s.lineStart
+			s.lineStart = s.Pos()
+			s.line++
+		}
 		return c
 	}
 	return 0
 }
 
 func (s *Scanner) HasNext() bool {
-	return (s.pos < s.max)
+	return s.pos < s.max || s.fill()
 }
 
 func (s *Scanner) Back() *Scanner {
 	if s.pos > 0 {
 		s.pos--
+		if s.text[s.pos] == '\n' {
+			s.lineStart = s.prevLineStart
+			s.line--
+		}
 	}
 	return s
 }
@@ -92,107 +177,204 @@ func (s *Scanner) SkipToNext(c byte) *Scanner {
 }
 
 func (s *Scanner) IsNext(mask uint16) bool {
-	return CharIsAny(s.text[s.pos], mask)
+	return s.HasNext() && CharIsAny(s.text[s.pos], mask)
 }
 
+// Return to the start of the input. When reading from an io.Reader this is
+// the start of the data still held in the buffer.
 func (s *Scanner) Reset() *Scanner {
 	s.pos = 0
+	if s.base == 0 {
+		s.line = 1
+		s.lineStart = 0
+		s.prevLineStart = 0
+	}
 	return s
 }
-func (s *Scanner) PeekToken() *Token {
-	p := s.pos
-	t := s.NextToken()
-	s.pos = p
-	return t
+
+func (s *Scanner) PeekToken() (*Token, error) {
+	p := s.Pos()
+	line, lineStart, prevLineStart := s.line, s.lineStart, s.prevLineStart
+	s.mark = p
+	t, err := s.NextToken()
+	s.mark = -1
+	s.pos = p - s.base
+	s.line, s.lineStart, s.prevLineStart = line, lineStart, prevLineStart
+	return t, err
 }
 
-func (s *Scanner) NextToken() *Token {
-	s.SkipSpace()
-	p := s.pos
-	if s.HasNext() {
-		c := s.Next()
-		if c == '{' {
-			return NewToken(string(c), p, TT_OBJECT_OPEN)
-		}
-		if c == '}' {
-			return NewToken(string(c), p, TT_OBJECT_CLOSE)
-		}
-		if c == ',' {
-			return NewToken(string(c), p, TT_COMMA)
-		}
-		if c == ':' {
-			return NewToken(string(c), p, TT_COLON)
-		}
-		if c == '"' {
-			return NewToken(s.scanQuotedString(c), p, TT_QUOTED_STRING)
-		}
-		if c == '[' {
-			return NewToken(string(c), p, TT_ARRAY_OPEN)
+// Read more data from the reader in to the buffer. Data before the current
+// position is discarded except for some context for Diag, Back and PeekToken.
+// Returns false if no more data is available.
+func (s *Scanner) fill() bool {
+	if s.eof || s.reader == nil {
+		return false
+	}
+	keep := s.pos - (diagContext * 2)
+	if s.mark >= 0 && s.mark-s.base < keep {
+		keep = s.mark - s.base
+	}
+	if keep > 0 {
+		copy(s.text, s.text[keep:s.max])
+		s.base = s.base + keep
+		s.pos = s.pos - keep
+		s.max = s.max - keep
+	}
+	if s.max == len(s.text) {
+		t := make([]byte, len(s.text)*2)
+		copy(t, s.text[:s.max])
+		s.text = t
+	}
+	for {
+		n, err := s.reader.Read(s.text[s.max:])
+		s.max = s.max + n
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			s.eof = true
+			return n > 0
 		}
-		if c == ']' {
-			return NewToken(string(c), p, TT_ARRAY_CLOSE)
+		if n > 0 {
+			return true
 		}
-		if c == 't' {
-			i := s.skipValueWithMask(TRUE_C)
-			if i != 4 {
-				panic(fmt.Sprintf("Boolean 'true' Must be 4 chars long starting with 't' containing 'r' 'u' and 'e'. %s", s.Diag("")))
-			}
-			return NewToken("true", p, TT_BOOL_TRUE)
+	}
+}
+
+// Try to make sure there are at least n chars available after the current position
+func (s *Scanner) ensure(n int) {
+	for s.max-s.pos < n && s.fill() {
+	}
+}
+
+// Return a ParseError for the current position in the input. If the
+// reader failed then the read error is returned instead.
+func (s *Scanner) error(msg string, expected ...TokenType) *ParseError {
+	if s.err != nil {
+		return &ParseError{Msg: fmt.Sprintf("failed to read input. %s", s.err.Error()), Offset: s.Pos(), Line: s.line, Column: s.Column(), Context: s.context(s.Pos()), Err: s.err}
+	}
+	return &ParseError{Msg: msg, Offset: s.Pos(), Line: s.line, Column: s.Column(), Expected: expected, Context: s.context(s.Pos())}
+}
+
+// Return a ParseError for a token that was found but was not valid at that point in the input.
+func (s *Scanner) tokenError(msg string, t *Token, expected ...TokenType) *ParseError {
+	return &ParseError{Msg: msg, Offset: t.pos, Line: t.line, Column: t.col, Token: t, Expected: expected, Context: s.context(t.pos)}
+}
+
+func (s *Scanner) NextToken() (*Token, error) {
+	s.SkipSpace()
+	t := &Token{pos: s.Pos(), line: s.line, col: s.Column()}
+	if !s.HasNext() {
+		return nil, s.error("unexpected end of input")
+	}
+	c := s.Next()
+	switch c {
+	case '{':
+		return t.set(string(c), TT_OBJECT_OPEN), nil
+	case '}':
+		return t.set(string(c), TT_OBJECT_CLOSE), nil
+	case ',':
+		return t.set(string(c), TT_COMMA), nil
+	case ':':
+		return t.set(string(c), TT_COLON), nil
+	case '[':
+		return t.set(string(c), TT_ARRAY_OPEN), nil
+	case ']':
+		return t.set(string(c), TT_ARRAY_CLOSE), nil
+	case '"':
+		str, err := s.scanQuotedString(c)
+		if err != nil {
+			return nil, err
 		}
-		if c == 'f' {
-			i := s.skipValueWithMask(FALSE_C)
-			if i != 5 {
-				panic(fmt.Sprintf("Boolean 'false' Must be 5 chars long starting with 'f' containing 'a' 'l' 's' and 'e'. %s", s.Diag("")))
-			}
-			return NewToken("false", p, TT_BOOL_FALSE)
+		if s.validateUTF8 && !utf8.ValidString(str) {
+			return nil, s.tokenError("invalid UTF-8 in quoted String", t.set(str, TT_UNKNOWN))
 		}
-		if c == 'n' {
-			i := s.skipValueWithMask(NULL_C)
-			if i != 4 {
-				panic(fmt.Sprintf("'null' Must be 4 chars long starting with 'n' containing 'a' 'l' 's' and 'e'. %s", s.Diag("")))
-			}
-			return NewToken("null", p, TT_NULL)
+		return t.set(str, TT_QUOTED_STRING), nil
+	}
+	if CharIsAny(c, ALF) {
+		s.Back()
+		word := s.scanValueWithMask(ALF)
+		switch word {
+		case "true":
+			return t.set(word, TT_BOOL_TRUE), nil
+		case "false":
+			return t.set(word, TT_BOOL_FALSE), nil
+		case "null":
+			return t.set(word, TT_NULL), nil
 		}
-		if CharIsAny(c, NUM) {
-			s.Back()
-			return NewToken(s.scanValueWithMask(NUM), p, TT_NUMBER)
+		return nil, s.tokenError(fmt.Sprintf("unrecognised token. '%s'. Must be 'true', 'false' or 'null'", word), t.set(word, TT_UNKNOWN))
+	}
+	if CharIsAny(c, NUM) {
+		s.Back()
+		num := s.scanNumber()
+		if !IsJsonNumber(num) {
+			return nil, s.tokenError(fmt.Sprintf("invalid number '%s'", num), t.set(num, TT_UNKNOWN))
 		}
-		panic(fmt.Sprintf("unrecognised token. '%c'. %s", rune(c), s.Diag(" ")))
+		return t.set(num, TT_NUMBER), nil
 	}
-	panic(fmt.Sprintf("unexpected end of input. %s", s.Diag("")))
+	s.Back()
+	return nil, s.error(fmt.Sprintf("unrecognised token. '%c'", rune(c)))
+}
+
+// The way non ASCII chars are written by EncodeQuotedString, JsonValue and JsonValueIndented
+type StringEncoding int
+
+const (
+	ENCODE_ASCII StringEncoding = iota // Non ASCII chars are written as \u escapes. Chars above 0xFFFF use a surrogate pair
+	ENCODE_UTF8                        // Non ASCII chars are written as UTF-8
+)
+
+var (
+	stringEncoding = ENCODE_ASCII
+)
+
+// Set the way non ASCII chars are written when nodes are rendered as json. The default is ENCODE_ASCII
+func SetStringEncoding(enc StringEncoding) {
+	stringEncoding = enc
+}
+
+func GetStringEncoding() StringEncoding {
+	return stringEncoding
 }
 
+// Return the string with json escapes. The quotes are not added.
+// Non ASCII chars are written as defined by SetStringEncoding.
 func EncodeQuotedString(inStr string) string {
+	return EncodeQuotedStringWith(inStr, stringEncoding)
+}
+
+// Return the string with json escapes using the given encoding. Only the escapes
+// defined by RFC 8259 are used. Invalid UTF-8 is replaced with U+FFFD.
+func EncodeQuotedStringWith(inStr string, enc StringEncoding) string {
 	var sb strings.Builder
 	for _, c := range inStr {
 		switch c {
 		case 0x0A: // Line Feed
 			sb.WriteString("\\n")
 		case 0x0D: // Carrage return
+			sb.WriteString("\\r")
 		case 0x08: // Back Space
 			sb.WriteString("\\b")
 		case 0x0C: // Form feed
 			sb.WriteString("\\f")
 		case 0x09: // Horizontal tab
 			sb.WriteString("\\t")
-		case '\\': // Horizontal tab
+		case '\\': // Back slash
 			sb.WriteString("\\\\")
 		case '"': //Double quotes need to be escaped
 			sb.WriteString("\\\"")
 		default:
-			if c > 255 {
-				r := IntToHexChar4(uint(c))
-				sb.WriteString("\\u")
-				sb.WriteRune(r[0])
-				sb.WriteRune(r[1])
-				sb.WriteRune(r[2])
-				sb.WriteRune(r[3])
+			if c < 0x20 {
+				writeUnicodeEscape(&sb, uint(c))
 			} else {
-				if c > 127 {
-					r := IntToHexChar2(uint(c))
-					sb.WriteString("\\x")
-					sb.WriteRune(r[0])
-					sb.WriteRune(r[1])
+				if c > 127 && enc == ENCODE_ASCII {
+					if c > 0xFFFF {
+						r1, r2 := utf16.EncodeRune(c)
+						writeUnicodeEscape(&sb, uint(r1))
+						writeUnicodeEscape(&sb, uint(r2))
+					} else {
+						writeUnicodeEscape(&sb, uint(c))
+					}
 				} else {
 					sb.WriteRune(c)
 				}
@@ -202,79 +384,166 @@ func EncodeQuotedString(inStr string) string {
 	return sb.String()
 }
 
-func (s *Scanner) scanQuotedString(delim byte) string {
+func writeUnicodeEscape(sb *strings.Builder, c uint) {
+	r := IntToHexChar4(c)
+	sb.WriteString("\\u")
+	sb.WriteRune(r[0])
+	sb.WriteRune(r[1])
+	sb.WriteRune(r[2])
+	sb.WriteRune(r[3])
+}
+
+func (s *Scanner) scanQuotedString(delim byte) (string, error) {
 	var sb strings.Builder
 	for s.HasNext() {
 		c := s.Next()
-		if c == '\\' {
-			c = s.Next()
-			switch c {
-			case 'b': // 0x08
-				sb.WriteString("\b")
-			case 'r': // 0x0D
-				sb.WriteString("\r")
-			case 'n': // 0x0A
-				sb.WriteString("\n")
-			case 'f': // 0x0C
-				sb.WriteString("\f")
-			case 't': // 0x09
-				sb.WriteString("\t")
-			case 'u':
-				b1 := s.readUInt16()
-				b2 := s.readUInt16()
-				sb.WriteRune(rune((b1 << 8) | b2))
-			case 'x':
-				b1 := s.readUInt16()
-				sb.WriteRune(rune(b1))
-			default:
-				sb.WriteByte(c)
+		if c == delim {
+			return sb.String(), nil
+		}
+		if c < 0x20 && (s.strict || s.validateUTF8) {
+			s.Back()
+			return "", s.error(fmt.Sprintf("control character 0x%02X must be escaped in quoted String", c))
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		if !s.HasNext() {
+			break
+		}
+		c = s.Next()
+		switch c {
+		case '"', '\\', '/':
+			sb.WriteByte(c)
+		case 'b': // 0x08
+			sb.WriteString("\b")
+		case 'r': // 0x0D
+			sb.WriteString("\r")
+		case 'n': // 0x0A
+			sb.WriteString("\n")
+		case 'f': // 0x0C
+			sb.WriteString("\f")
+		case 't': // 0x09
+			sb.WriteString("\t")
+		case 'u':
+			r, err := s.readUnicodeEscape()
+			if err != nil {
+				return "", err
 			}
-		} else {
-			if c == delim {
-				return sb.String()
+			sb.WriteRune(r)
+		case 'x':
+			// Not json. Accepted unless strict as older versions of EncodeQuotedString wrote chars 128..255 like this.
+			if s.strict || s.validateUTF8 {
+				s.Back()
+				return "", s.error("invalid escape '\\x' in quoted String")
 			}
-			if s.HasNext() {
-				if s.text[s.pos] == delim {
-					s.Next()
-					sb.WriteByte(c)
-					return sb.String()
-				}
-			} else {
-				panic(fmt.Sprintf("unterminated quoted String. %s", s.Diag(" ")))
+			r, err := s.readHex(2)
+			if err != nil {
+				return "", err
 			}
-			sb.WriteByte(c)
+			sb.WriteRune(rune(r))
+		default:
+			s.Back()
+			return "", s.error(fmt.Sprintf("invalid escape '\\%c' in quoted String", rune(c)))
 		}
 	}
-	return sb.String()
+	return "", s.error("unterminated quoted String")
 }
 
-func (s *Scanner) readUInt16() uint16 {
-	var b0 uint16
-	var b1 uint16
-	if s.HasNext() {
-		b0 = HexCharToInt(s.Next())
-	} else {
-		panic(fmt.Sprintf("Unexpected end of input. %s", s.Diag(" ")))
+// Read the 4 hex digits after a \u. If the value is the first half of a UTF-16
+// surrogate pair the second \uXXXX is also read. Unpaired surrogates return U+FFFD.
+func (s *Scanner) readUnicodeEscape() (rune, error) {
+	r1, err := s.readHex(4)
+	if err != nil {
+		return 0, err
 	}
-	if s.HasNext() {
-		b1 = HexCharToInt(s.Next())
-	} else {
-		panic(fmt.Sprintf("Unexpected end of input. %s", s.Diag(" ")))
+	if !utf16.IsSurrogate(rune(r1)) {
+		return rune(r1), nil
+	}
+	s.ensure(2)
+	if r1 >= 0xDC00 || s.max-s.pos < 2 || s.text[s.pos] != '\\' || s.text[s.pos+1] != 'u' {
+		return utf8.RuneError, nil
+	}
+	p, line, lineStart := s.Pos(), s.line, s.lineStart
+	s.Next()
+	s.Next()
+	r2, err := s.readHex(4)
+	if err != nil {
+		return 0, err
+	}
+	r := utf16.DecodeRune(rune(r1), rune(r2))
+	if r == utf8.RuneError {
+		// Not a pair. Return U+FFFD for the first one and read the second one again
+		s.pos, s.line, s.lineStart = p-s.base, line, lineStart
+	}
+	return r, nil
+}
+
+// Read n hex digits and return the value
+func (s *Scanner) readHex(n int) (uint16, error) {
+	var v uint16
+	for i := 0; i < n; i++ {
+		if !s.HasNext() {
+			return 0, s.error("unexpected end of input")
+		}
+		h, ok := hexValue(s.Next())
+		if !ok {
+			s.Back()
+			return 0, s.error("invalid hex digit in escape sequence")
+		}
+		v = (v << 4) + h
 	}
-	return (b0 << 4) + b1
+	return v, nil
 }
 
-func (s *Scanner) skipValueWithMask(mask uint16) int {
-	i := 1
+// Read the chars that can be in a number. IsJsonNumber is used to check the result
+func (s *Scanner) scanNumber() string {
+	var sb strings.Builder
 	for s.HasNext() {
 		c := s.Next()
-		if !CharIsAny(c, mask) {
+		if CharIsAny(c, NUM) || c == 'e' || c == 'E' {
+			sb.WriteByte(c)
+		} else {
 			s.Back()
-			return i
+			return sb.String()
+		}
+	}
+	return sb.String()
+}
+
+// Return true if num is a valid json number. For example -1, 10.5 or 1.5e-9.
+// Leading zeros are accepted.
+func IsJsonNumber(num string) bool {
+	i := 0
+	digits := func() bool {
+		st := i
+		for i < len(num) && num[i] >= '0' && num[i] <= '9' {
+			i++
+		}
+		return i > st
+	}
+	if i < len(num) && num[i] == '-' {
+		i++
+	}
+	if !digits() {
+		return false
+	}
+	if i < len(num) && num[i] == '.' {
+		i++
+		if !digits() {
+			return false
 		}
+	}
+	if i < len(num) && (num[i] == 'e' || num[i] == 'E') {
 		i++
+		if i < len(num) && (num[i] == '+' || num[i] == '-') {
+			i++
+		}
+		if !digits() {
+			return false
+		}
 	}
-	return i
+	return i == len(num)
 }
 
 func (s *Scanner) scanValueWithMask(mask uint16) string {
@@ -298,12 +567,34 @@ func NewToken(txt string, pos int, tokenType TokenType) *Token {
 	return &Token{text: txt, pos: pos, tok: tokenType}
 }
 
+func (t *Token) set(txt string, tokenType TokenType) *Token {
+	t.text = txt
+	t.tok = tokenType
+	return t
+}
+
+// Return the offset of the token in the input
+func (t *Token) GetPos() int {
+	return t.pos
+}
+
+// Return the line (from 1) of the token in the input. 0 if not known
+func (t *Token) GetLine() int {
+	return t.line
+}
+
+// Return the column (from 1) of the token in the input. 0 if not known
+func (t *Token) GetColumn() int {
+	return t.col
+}
+
 func (t *Token) GetStringValue() string {
 	return t.text
 }
 
 func (t *Token) GetNumberValue() float64 {
-	if s, err := strconv.ParseFloat(t.text, 64); err == nil {
+	s, err := strconv.ParseFloat(t.text, 64)
+	if err == nil || errors.Is(err, strconv.ErrRange) {
 		return s
 	}
 	panic("Number conversion error")
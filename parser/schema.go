@@ -0,0 +1,449 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Json Schema (2020-12) validation.
+//
+// The supported keywords are type, enum, const, properties, required, additionalProperties,
+// items, minimum, maximum, exclusiveMinimum, exclusiveMaximum, minLength, maxLength,
+// minItems, maxItems, minProperties, maxProperties, pattern, allOf, anyOf, oneOf and $ref.
+// A $ref must refer to the same document. For example "#/$defs/address".
+// Other keywords are ignored.
+
+// A compiled schema. It can be used to validate many documents.
+type Schema struct {
+	root     NodeI
+	patterns map[string]*regexp.Regexp
+}
+
+// A value that is not valid. Path is the path to the value in the document.
+// SchemaPath is the location of the keyword in the schema. For example "#/properties/age/minimum".
+type Violation struct {
+	Path       *Path
+	SchemaPath string
+	Keyword    string
+	Message    string
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("#%s: %s (%s)", v.Path.Pointer(), v.Message, v.SchemaPath)
+}
+
+var schemaTypes = []string{"null", "boolean", "object", "array", "number", "string", "integer"}
+
+// Parse and compile a schema
+func ParseSchema(json []byte) (*Schema, error) {
+	node, err := Parse(json)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(node)
+}
+
+// Compile a schema. An error is returned if a supported keyword has an invalid value
+// or a $ref cannot be resolved.
+func NewSchema(schema NodeI) (*Schema, error) {
+	s := &Schema{root: schema, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.compile(schema, "#", make(map[NodeI]bool)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Return the violations found in node. An empty list is returned if node is valid.
+func (s *Schema) Validate(node NodeI) []*Violation {
+	v := &schemaValidator{schema: s, active: make(map[schemaVisit]bool)}
+	return v.validate(s.root, "#", "", node, make([]string, 0))
+}
+
+// Return true if node is valid
+func (s *Schema) IsValid(node NodeI) bool {
+	return len(s.Validate(node)) == 0
+}
+
+func (s *Schema) resolve(ref string) (NodeI, string, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, "", fmt.Errorf("only a $ref within the schema is supported. Found '%s'", ref)
+	}
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid $ref '%s'", ref)
+	}
+	n, err := patchGet(s.root, pointer)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot resolve $ref '%s'", ref)
+	}
+	return n, "#" + pointer, nil
+}
+
+// Check a schema and the schemas it contains or refers to. compiled stops a $ref cycle.
+func (s *Schema) compile(schema NodeI, sp string, compiled map[NodeI]bool) error {
+	if compiled[schema] {
+		return nil
+	}
+	compiled[schema] = true
+	if _, ok := schema.(*JsonBool); ok {
+		return nil
+	}
+	o, ok := schema.(*JsonObject)
+	if !ok {
+		return fmt.Errorf("invalid schema at '%s'. A schema must be an object or a boolean", sp)
+	}
+	invalid := func(k, msg string) error {
+		return fmt.Errorf("invalid schema at '%s/%s'. %s", sp, pointerEscaper.Replace(k), msg)
+	}
+	for _, k := range o.keys {
+		kv := *o.value[k]
+		ksp := sp + "/" + pointerEscaper.Replace(k)
+		switch k {
+		case "$ref":
+			ref, ok := kv.(*JsonString)
+			if !ok {
+				return invalid(k, "must be a string")
+			}
+			target, tsp, err := s.resolve(ref.GetValue())
+			if err != nil {
+				return invalid(k, err.Error())
+			}
+			if err := s.compile(target, tsp, compiled); err != nil {
+				return err
+			}
+		case "type":
+			names := []NodeI{kv}
+			if l, ok := kv.(*JsonList); ok {
+				names = l.GetValues()
+			}
+			for _, n := range names {
+				sn, ok := n.(*JsonString)
+				if !ok || indexOf(schemaTypes, sn.GetValue()) < 0 {
+					return invalid(k, fmt.Sprintf("must be one of %s", strings.Join(schemaTypes, ", ")))
+				}
+			}
+		case "properties", "$defs", "definitions":
+			props, ok := kv.(*JsonObject)
+			if !ok {
+				return invalid(k, "must be an object")
+			}
+			for _, pk := range props.keys {
+				if err := s.compile(*props.value[pk], ksp+"/"+pointerEscaper.Replace(pk), compiled); err != nil {
+					return err
+				}
+			}
+		case "additionalProperties", "items":
+			if err := s.compile(kv, ksp, compiled); err != nil {
+				return err
+			}
+		case "allOf", "anyOf", "oneOf":
+			l, ok := kv.(*JsonList)
+			if !ok || l.Len() == 0 {
+				return invalid(k, "must be a list of schemas")
+			}
+			for i, sub := range l.GetValues() {
+				if err := s.compile(sub, ksp+"/"+strconv.Itoa(i), compiled); err != nil {
+					return err
+				}
+			}
+		case "required":
+			l, ok := kv.(*JsonList)
+			if !ok {
+				return invalid(k, "must be a list of strings")
+			}
+			for _, n := range l.GetValues() {
+				if _, ok := n.(*JsonString); !ok {
+					return invalid(k, "must be a list of strings")
+				}
+			}
+		case "enum":
+			if _, ok := kv.(*JsonList); !ok {
+				return invalid(k, "must be a list")
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if _, ok := kv.(*JsonNumber); !ok {
+				return invalid(k, "must be a number")
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			n, ok := kv.(*JsonNumber)
+			if !ok || !n.IsInteger() || n.Cmp(NewJsonNumber("", 0)) < 0 {
+				return invalid(k, "must be a non-negative integer")
+			}
+		case "pattern":
+			p, ok := kv.(*JsonString)
+			if !ok {
+				return invalid(k, "must be a string")
+			}
+			re, err := regexp.Compile(p.GetValue())
+			if err != nil {
+				return invalid(k, err.Error())
+			}
+			s.patterns[p.GetValue()] = re
+		}
+	}
+	return nil
+}
+
+type schemaVisit struct {
+	schema NodeI
+	node   NodeI
+}
+
+type schemaValidator struct {
+	schema *Schema
+	active map[schemaVisit]bool
+}
+
+func newViolation(path []string, sp, keyword, format string, args ...interface{}) *Violation {
+	if len(path) == 0 {
+		path = parserEmptyPath
+	}
+	return &Violation{Path: &Path{path: path, delim: "."}, SchemaPath: sp, Keyword: keyword, Message: fmt.Sprintf(format, args...)}
+}
+
+// Return the json schema type name of a node
+func schemaTypeOf(node NodeI) string {
+	switch n := node.(type) {
+	case *JsonNull:
+		return "null"
+	case *JsonBool:
+		return "boolean"
+	case *JsonObject:
+		return "object"
+	case *JsonList:
+		return "array"
+	case *JsonNumber:
+		if n.IsInteger() {
+			return "integer"
+		}
+		return "number"
+	}
+	return "string"
+}
+
+func schemaTypeMatch(types NodeI, node NodeI) bool {
+	names := []NodeI{types}
+	if l, ok := types.(*JsonList); ok {
+		names = l.GetValues()
+	}
+	t := schemaTypeOf(node)
+	for _, n := range names {
+		name := n.(*JsonString).GetValue()
+		if name == t || (name == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaTypeNames(types NodeI) string {
+	if l, ok := types.(*JsonList); ok {
+		names := make([]string, 0, l.Len())
+		for _, n := range l.GetValues() {
+			names = append(names, n.(*JsonString).GetValue())
+		}
+		return strings.Join(names, " or ")
+	}
+	return types.(*JsonString).GetValue()
+}
+
+func schemaCount(n NodeI) int {
+	return int(n.(*JsonNumber).GetIntValue())
+}
+
+// Validate node against schema. keyword is the keyword in the parent schema that holds schema.
+// It is used for a violation if schema is not valid. For example when the schema was changed after it was compiled.
+func (v *schemaValidator) validate(schema NodeI, sp, keyword string, node NodeI, path []string) []*Violation {
+	out := make([]*Violation, 0)
+	if b, ok := schema.(*JsonBool); ok {
+		if !b.GetValue() {
+			out = append(out, newViolation(path, sp, "false", "no value is allowed"))
+		}
+		return out
+	}
+	s, ok := schema.(*JsonObject)
+	if !ok {
+		return append(out, newViolation(path, sp, keyword, "invalid schema. A schema must be an object or a boolean"))
+	}
+	//
+	// A $ref that refers back to a schema for the same node would never end
+	//
+	visit := schemaVisit{schema: schema, node: node}
+	if v.active[visit] {
+		return out
+	}
+	v.active[visit] = true
+	defer delete(v.active, visit)
+
+	for _, k := range s.keys {
+		kv := *s.value[k]
+		ksp := sp + "/" + pointerEscaper.Replace(k)
+		switch k {
+		case "$ref":
+			ref, ok := kv.(*JsonString)
+			if !ok {
+				out = append(out, newViolation(path, ksp, k, "invalid schema. $ref must be a string"))
+				continue
+			}
+			target, tsp, err := v.schema.resolve(ref.GetValue())
+			if err != nil {
+				out = append(out, newViolation(path, ksp, k, "invalid schema. %s", err.Error()))
+				continue
+			}
+			out = append(out, v.validate(target, tsp, k, node, path)...)
+		case "type":
+			if !schemaTypeMatch(kv, node) {
+				out = append(out, newViolation(path, ksp, k, "expected type %s. Found %s", schemaTypeNames(kv), schemaTypeOf(node)))
+			}
+		case "enum":
+			found := false
+			for _, e := range kv.(*JsonList).GetValues() {
+				if EqualValues(e, node) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				out = append(out, newViolation(path, ksp, k, "value %s is not one of %s", valueJson(node), valueJson(kv)))
+			}
+		case "const":
+			if !EqualValues(kv, node) {
+				out = append(out, newViolation(path, ksp, k, "value %s must be %s", valueJson(node), valueJson(kv)))
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if n, ok := node.(*JsonNumber); ok {
+				if msg := schemaCompare(k, n.Cmp(kv.(*JsonNumber))); msg != "" {
+					out = append(out, newViolation(path, ksp, k, "value %s %s %s", n.GetLiteral(), msg, kv.(*JsonNumber).GetLiteral()))
+				}
+			}
+		case "minLength", "maxLength":
+			if str, ok := node.(*JsonString); ok {
+				out = v.checkCount(out, path, ksp, k, "length", utf8.RuneCountInString(str.GetValue()), schemaCount(kv))
+			}
+		case "minItems", "maxItems":
+			if l, ok := node.(*JsonList); ok {
+				out = v.checkCount(out, path, ksp, k, "number of items", l.Len(), schemaCount(kv))
+			}
+		case "minProperties", "maxProperties":
+			if o, ok := node.(*JsonObject); ok {
+				out = v.checkCount(out, path, ksp, k, "number of properties", o.Len(), schemaCount(kv))
+			}
+		case "pattern":
+			if str, ok := node.(*JsonString); ok {
+				p := kv.(*JsonString).GetValue()
+				if !v.schema.patterns[p].MatchString(str.GetValue()) {
+					out = append(out, newViolation(path, ksp, k, "value %s does not match the pattern '%s'", valueJson(node), p))
+				}
+			}
+		case "required":
+			if o, ok := node.(*JsonObject); ok {
+				for _, r := range kv.(*JsonList).GetValues() {
+					name := r.(*JsonString).GetValue()
+					if o.GetNodeWithName(name) == nil {
+						out = append(out, newViolation(path, ksp, k, "required property '%s' is missing", name))
+					}
+				}
+			}
+		case "properties":
+			if o, ok := node.(*JsonObject); ok {
+				props := kv.(*JsonObject)
+				for _, pk := range props.keys {
+					if child, found := o.value[pk]; found {
+						out = append(out, v.validate(*props.value[pk], ksp+"/"+pointerEscaper.Replace(pk), k, *child, jpAppend(path, pk))...)
+					}
+				}
+			}
+		case "additionalProperties":
+			if o, ok := node.(*JsonObject); ok {
+				props, _ := s.GetNodeWithName("properties").(*JsonObject)
+				for _, key := range o.keys {
+					if props != nil && props.GetNodeWithName(key) != nil {
+						continue
+					}
+					if b, isBool := kv.(*JsonBool); isBool && !b.GetValue() {
+						out = append(out, newViolation(jpAppend(path, key), ksp, k, "property '%s' is not allowed", key))
+					} else {
+						out = append(out, v.validate(kv, ksp, k, *o.value[key], jpAppend(path, key))...)
+					}
+				}
+			}
+		case "items":
+			if l, ok := node.(*JsonList); ok {
+				for i, item := range l.GetValues() {
+					out = append(out, v.validate(kv, ksp, k, item, jpAppend(path, strconv.Itoa(i)))...)
+				}
+			}
+		case "allOf":
+			for i, sub := range kv.(*JsonList).GetValues() {
+				out = append(out, v.validate(sub, ksp+"/"+strconv.Itoa(i), k, node, path)...)
+			}
+		case "anyOf", "oneOf":
+			matched := 0
+			for i, sub := range kv.(*JsonList).GetValues() {
+				if len(v.validate(sub, ksp+"/"+strconv.Itoa(i), k, node, path)) == 0 {
+					matched++
+				}
+			}
+			if k == "anyOf" && matched == 0 {
+				out = append(out, newViolation(path, ksp, k, "value does not match any of the schemas"))
+			}
+			if k == "oneOf" && matched != 1 {
+				out = append(out, newViolation(path, ksp, k, "value matches %d of the schemas. It must match exactly one", matched))
+			}
+		}
+	}
+	return out
+}
+
+func (v *schemaValidator) checkCount(out []*Violation, path []string, sp, keyword, what string, count, limit int) []*Violation {
+	if strings.HasPrefix(keyword, "min") && count < limit {
+		return append(out, newViolation(path, sp, keyword, "%s %d is less than %d", what, count, limit))
+	}
+	if strings.HasPrefix(keyword, "max") && count > limit {
+		return append(out, newViolation(path, sp, keyword, "%s %d is more than %d", what, count, limit))
+	}
+	return out
+}
+
+// Return the message for a number that is outside a limit. c is the result of comparing the number to the limit.
+func schemaCompare(keyword string, c int) string {
+	switch keyword {
+	case "minimum":
+		if c < 0 {
+			return "is less than the minimum"
+		}
+	case "maximum":
+		if c > 0 {
+			return "is more than the maximum"
+		}
+	case "exclusiveMinimum":
+		if c <= 0 {
+			return "must be more than"
+		}
+	case "exclusiveMaximum":
+		if c >= 0 {
+			return "must be less than"
+		}
+	}
+	return ""
+}
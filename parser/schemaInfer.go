@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+const SCHEMA_2020_12 = "https://json-schema.org/draft/2020-12/schema"
+
+type InferOptions struct {
+	// Strings with this many different values or less become an enum. 0 for no enums.
+	// A value must be seen more than once so a list of unique names is not an enum.
+	EnumMaxValues int
+	// Do not add minimum and maximum for numbers
+	NoRanges bool
+}
+
+// Return a schema that all of the samples are valid for. Objects keys in every sample are required.
+// Strings with 5 different values or less that are repeated become an enum.
+func InferSchema(samples ...NodeI) *JsonObject {
+	return InferSchemaWithOptions(InferOptions{EnumMaxValues: 5}, samples...)
+}
+
+// Return a schema that all of the samples are valid for using the options.
+func InferSchemaWithOptions(options InferOptions, samples ...NodeI) *JsonObject {
+	shape := newInferShape()
+	for _, s := range samples {
+		shape.add(s, &options)
+	}
+	schema := NewJsonObject("")
+	schema.Add(NewJsonString("$schema", SCHEMA_2020_12))
+	shape.write(schema, &options)
+	return schema
+}
+
+// What is known about the values found at one place in the samples
+type inferShape struct {
+	types      []string
+	total      int
+	objects    int
+	keys       []string
+	properties map[string]*inferShape
+	items      *inferShape
+	min, max   *JsonNumber
+	strings    int
+	values     []string
+	seen       map[string]int
+	nulls      bool
+}
+
+func newInferShape() *inferShape {
+	return &inferShape{types: make([]string, 0), keys: make([]string, 0), properties: make(map[string]*inferShape), values: make([]string, 0), seen: make(map[string]int)}
+}
+
+func (s *inferShape) addType(t string) {
+	if indexOf(s.types, t) < 0 {
+		s.types = append(s.types, t)
+	}
+}
+
+func (s *inferShape) add(node NodeI, options *InferOptions) {
+	s.total++
+	s.addType(schemaTypeOf(node))
+	switch n := node.(type) {
+	case *JsonObject:
+		s.objects++
+		for _, k := range n.keys {
+			p, ok := s.properties[k]
+			if !ok {
+				p = newInferShape()
+				s.properties[k] = p
+				s.keys = append(s.keys, k)
+			}
+			p.add(*n.value[k], options)
+		}
+	case *JsonList:
+		if s.items == nil {
+			s.items = newInferShape()
+		}
+		for _, v := range n.GetValues() {
+			s.items.add(v, options)
+		}
+	case *JsonNumber:
+		if s.min == nil || n.Cmp(s.min) < 0 {
+			s.min = n
+		}
+		if s.max == nil || n.Cmp(s.max) > 0 {
+			s.max = n
+		}
+	case *JsonString:
+		s.strings++
+		v := n.GetValue()
+		if _, ok := s.seen[v]; !ok && len(s.values) <= options.EnumMaxValues {
+			s.values = append(s.values, v)
+		}
+		s.seen[v]++
+	case *JsonNull:
+		s.nulls = true
+	}
+}
+
+func (s *inferShape) write(schema *JsonObject, options *InferOptions) {
+	types := make([]string, 0, len(s.types))
+	for _, t := range s.types {
+		if t == "integer" && indexOf(s.types, "number") >= 0 {
+			continue
+		}
+		types = append(types, t)
+	}
+	if len(types) == 1 {
+		schema.Add(NewJsonString("type", types[0]))
+	} else if len(types) > 1 {
+		tl := NewJsonList("type")
+		for _, t := range types {
+			tl.Add(NewJsonString("", t))
+		}
+		schema.Add(tl)
+	}
+	if s.objects > 0 {
+		props := NewJsonObject("properties")
+		required := NewJsonList("required")
+		for _, k := range s.keys {
+			p := s.properties[k]
+			ps := NewJsonObject(k)
+			p.write(ps, options)
+			props.Add(ps)
+			if p.total == s.objects {
+				required.Add(NewJsonString("", k))
+			}
+		}
+		schema.Add(props)
+		if required.Len() > 0 {
+			schema.Add(required)
+		}
+	}
+	if s.items != nil && len(s.items.types) > 0 {
+		items := NewJsonObject("items")
+		s.items.write(items, options)
+		schema.Add(items)
+	}
+	if s.min != nil && !options.NoRanges {
+		schema.Add(Clone(s.min, "minimum", true))
+		schema.Add(Clone(s.max, "maximum", true))
+	}
+	if s.isEnum(options) {
+		enum := NewJsonList("enum")
+		for _, v := range s.values {
+			enum.Add(NewJsonString("", v))
+		}
+		if s.nulls {
+			enum.Add(NewJsonNull(""))
+		}
+		schema.Add(enum)
+	}
+}
+
+// Strings are an enum if they are the only type (other than null), there are
+// EnumMaxValues different values or less and at least one value is repeated.
+func (s *inferShape) isEnum(options *InferOptions) bool {
+	if s.strings == 0 || len(s.values) > options.EnumMaxValues || len(s.seen) >= s.strings {
+		return false
+	}
+	for _, t := range s.types {
+		if t != "string" && t != "null" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,704 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlInt     = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+	tomlHex     = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$`)
+	tomlOct     = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBin     = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloat   = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+	tomlInf     = regexp.MustCompile(`^[-+]?(inf|nan)$`)
+	tomlDate    = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}([Tt ][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[-+][0-9]{2}:[0-9]{2})?)?$`)
+	tomlTime    = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?$`)
+	tomlEscapes = map[byte]rune{'b': '\b', 't': '\t', 'n': '\n', 'f': '\f', 'r': '\r', 'e': 0x1b, '"': '"', '\\': '\\'}
+)
+
+// How a table or an array was created. This controls what can be added to it later.
+type tomlDef int
+
+const (
+	tomlImplicit    tomlDef = iota // The parent of a table in a [a.b] header
+	tomlHeaderTable                // A [table] header
+	tomlDotted                     // A dotted key a.b = 1
+	tomlFixed                      // An inline table or an array value. It cannot be changed
+	tomlArray                      // An array of tables [[a]]
+)
+
+// Parse a toml 1.0 document. Tables and inline tables are returned as JsonObject. Arrays and
+// arrays of tables are returned as JsonList. Dates and times are returned as JsonString.
+// Errors are a *ParseError with the line and column.
+func ParseTOML(toml []byte) (NodeC, error) {
+	p := &tomlParser{text: bytes.ReplaceAll(toml, []byte("\r\n"), []byte("\n")), line: 1, defs: make(map[NodeC]tomlDef)}
+	return p.parse()
+}
+
+type tomlParser struct {
+	text      []byte
+	pos       int
+	line      int
+	lineStart int
+	defs      map[NodeC]tomlDef
+}
+
+func (p *tomlParser) errorAt(pos, line, lineStart int, msg string) *ParseError {
+	return textError(p.text, pos, line, lineStart, msg)
+}
+
+func (p *tomlParser) error(msg string) *ParseError {
+	return textError(p.text, p.pos, p.line, p.lineStart, msg)
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.text)
+}
+
+// Return the byte at offset i from the current position. 0 is returned at the end of the text.
+func (p *tomlParser) peekAt(i int) byte {
+	if p.pos+i >= len(p.text) {
+		return 0
+	}
+	return p.text[p.pos+i]
+}
+
+func (p *tomlParser) peek() byte {
+	return p.peekAt(0)
+}
+
+func (p *tomlParser) newLine() {
+	p.pos++
+	p.line++
+	p.lineStart = p.pos
+}
+
+// Skip spaces and comments. Line breaks are skipped if lines is true.
+func (p *tomlParser) skipSpace(lines bool) {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t':
+			p.pos++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		case '\n':
+			if !lines {
+				return
+			}
+			p.newLine()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) parse() (NodeC, error) {
+	root := NewJsonObject("")
+	current := root
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return root, nil
+		}
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if !p.eof() && p.peek() != '\n' {
+			return nil, p.error("expected the end of the line")
+		}
+	}
+}
+
+// Return keys as toml text. For example a."b.c"
+func tomlKeys(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = tomlKey(k)
+	}
+	return strings.Join(quoted, ".")
+}
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return quoteUnicode(key)
+}
+
+// Parse a [table] or [[array of tables]] header. Return the table.
+func (p *tomlParser) parseHeader(root *JsonObject) (*JsonObject, error) {
+	pos, line, lineStart := p.pos, p.line, p.lineStart
+	array := p.peekAt(1) == '['
+	close := "]"
+	if array {
+		close = "]]"
+	}
+	p.pos += len(close)
+	p.skipSpace(false)
+	keys, err := p.parseKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace(false)
+	if !bytes.HasPrefix(p.text[p.pos:], []byte(close)) {
+		return nil, p.error(fmt.Sprintf("expected '%s'", close))
+	}
+	p.pos += len(close)
+	parent, err := p.tables(root, keys[:len(keys)-1], pos, line, lineStart)
+	if err != nil {
+		return nil, err
+	}
+	name := keys[len(keys)-1]
+	existing := parent.GetNodeWithName(name)
+	if array {
+		list, ok := existing.(*JsonList)
+		if existing == nil {
+			list = NewJsonList(name)
+			parent.Add(list)
+			p.defs[list] = tomlArray
+		} else if !ok || p.defs[list] != tomlArray {
+			return nil, p.errorAt(pos, line, lineStart, fmt.Sprintf("'%s' is already defined", tomlKeys(keys)))
+		}
+		table := NewJsonObject("")
+		list.Add(table)
+		p.defs[table] = tomlHeaderTable
+		return table, nil
+	}
+	if existing == nil {
+		table := NewJsonObject(name)
+		parent.Add(table)
+		p.defs[table] = tomlHeaderTable
+		return table, nil
+	}
+	if table, ok := existing.(*JsonObject); ok && p.defs[table] == tomlImplicit {
+		p.defs[table] = tomlHeaderTable
+		return table, nil
+	}
+	return nil, p.errorAt(pos, line, lineStart, fmt.Sprintf("the table [%s] is already defined", tomlKeys(keys)))
+}
+
+// Return the table for the keys of a header. Tables that do not exist are created. The last table in an array of tables is used.
+func (p *tomlParser) tables(root *JsonObject, keys []string, pos, line, lineStart int) (*JsonObject, error) {
+	t := root
+	for i, k := range keys {
+		switch n := t.GetNodeWithName(k).(type) {
+		case nil:
+			table := NewJsonObject(k)
+			t.Add(table)
+			p.defs[table] = tomlImplicit
+			t = table
+		case *JsonObject:
+			if p.defs[n] == tomlFixed {
+				return nil, p.errorAt(pos, line, lineStart, fmt.Sprintf("the inline table '%s' cannot be changed", tomlKeys(keys[:i+1])))
+			}
+			t = n
+		case *JsonList:
+			if p.defs[n] != tomlArray {
+				return nil, p.errorAt(pos, line, lineStart, fmt.Sprintf("the array '%s' cannot be changed", tomlKeys(keys[:i+1])))
+			}
+			t = n.GetNodeAt(n.Len() - 1).(*JsonObject)
+		default:
+			return nil, p.errorAt(pos, line, lineStart, fmt.Sprintf("'%s' is already defined as a value", tomlKeys(keys[:i+1])))
+		}
+	}
+	return t, nil
+}
+
+// Parse key = value and add it to the table
+func (p *tomlParser) parseKeyValue(table *JsonObject) error {
+	pos, line, lineStart := p.pos, p.line, p.lineStart
+	keys, err := p.parseKeys()
+	if err != nil {
+		return err
+	}
+	p.skipSpace(false)
+	if p.peek() != '=' {
+		return p.error("expected '=' after a key")
+	}
+	p.pos++
+	p.skipSpace(false)
+	t := table
+	for i, k := range keys {
+		switch n := t.GetNodeWithName(k).(type) {
+		case nil:
+			if i == len(keys)-1 {
+				break
+			}
+			dotted := NewJsonObject(k)
+			t.Add(dotted)
+			p.defs[dotted] = tomlDotted
+			t = dotted
+		case *JsonObject:
+			if p.defs[n] == tomlDotted && i < len(keys)-1 {
+				t = n
+				break
+			}
+			return p.errorAt(pos, line, lineStart, fmt.Sprintf("'%s' is already defined", tomlKeys(keys[:i+1])))
+		default:
+			return p.errorAt(pos, line, lineStart, fmt.Sprintf("'%s' is already defined", tomlKeys(keys[:i+1])))
+		}
+	}
+	value, err := p.parseValue(keys[len(keys)-1])
+	if err != nil {
+		return err
+	}
+	t.Add(value)
+	return nil
+}
+
+// Parse a dotted key. For example a."b.c".d
+func (p *tomlParser) parseKeys() ([]string, error) {
+	keys := make([]string, 0)
+	for {
+		var key string
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			if p.peekAt(1) == c && p.peekAt(2) == c {
+				return nil, p.error("a key cannot be a multi line string")
+			}
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for c := p.peek(); c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'; c = p.peek() {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.error("expected a key")
+			}
+			key = string(p.text[start:p.pos])
+		}
+		keys = append(keys, key)
+		pos := p.pos
+		p.skipSpace(false)
+		if p.peek() != '.' {
+			p.pos = pos
+			return keys, nil
+		}
+		p.pos++
+		p.skipSpace(false)
+	}
+}
+
+func (p *tomlParser) parseValue(name string) (NodeI, error) {
+	pos := p.pos
+	switch p.peek() {
+	case '"', '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return NewJsonString(name, s), nil
+	case '[':
+		return p.parseArray(name)
+	case '{':
+		return p.parseInlineTable(name)
+	}
+	for c := p.peek(); c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '+' || c == '.' || c == ':'; c = p.peek() {
+		p.pos++
+		// A space can separate a date and a time
+		if p.pos-pos == 10 && p.peek() == ' ' && p.peekAt(1) >= '0' && p.peekAt(1) <= '9' && tomlDate.Match(p.text[pos:p.pos]) {
+			p.pos++
+		}
+	}
+	token := string(p.text[pos:p.pos])
+	switch {
+	case token == "":
+		return nil, p.error("expected a value")
+	case token == "true":
+		return NewJsonBool(name, true), nil
+	case token == "false":
+		return NewJsonBool(name, false), nil
+	case tomlDate.MatchString(token) || tomlTime.MatchString(token):
+		return NewJsonString(name, token), nil
+	case tomlInf.MatchString(token):
+		return nil, p.errorAt(pos, p.line, p.lineStart, fmt.Sprintf("the number '%s' cannot be held in a JsonNumber", token))
+	}
+	clean := strings.TrimPrefix(strings.ReplaceAll(token, "_", ""), "+")
+	var i big.Int
+	switch {
+	case tomlInt.MatchString(token):
+		i.SetString(clean, 10)
+	case tomlHex.MatchString(token):
+		i.SetString(clean[2:], 16)
+	case tomlOct.MatchString(token):
+		i.SetString(clean[2:], 8)
+	case tomlBin.MatchString(token):
+		i.SetString(clean[2:], 2)
+	case tomlFloat.MatchString(token):
+		return NewJsonNumberFromLiteral(name, clean)
+	default:
+		return nil, p.errorAt(pos, p.line, p.lineStart, fmt.Sprintf("invalid value '%s'", token))
+	}
+	if !i.IsInt64() {
+		return nil, p.errorAt(pos, p.line, p.lineStart, fmt.Sprintf("the integer '%s' is out of range", token))
+	}
+	return NewJsonNumberFromLiteral(name, i.String())
+}
+
+// Parse a basic ("), literal ('), multi line basic (""") or multi line literal (”') string
+func (p *tomlParser) parseString() (string, error) {
+	pos, line, lineStart := p.pos, p.line, p.lineStart
+	q := p.peek()
+	multi := p.peekAt(1) == q && p.peekAt(2) == q
+	if multi {
+		p.pos += 3
+		if p.peek() == '\n' { // A line break after the quotes is not part of the string
+			p.newLine()
+		}
+	} else {
+		p.pos++
+	}
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorAt(pos, line, lineStart, "the string is not closed")
+		}
+		c := p.peek()
+		switch {
+		case c == q && !multi:
+			p.pos++
+			return sb.String(), nil
+		case c == q && p.peekAt(1) == q && p.peekAt(2) == q:
+			n := 3
+			for p.peekAt(n) == q {
+				n++
+			}
+			if n > 5 {
+				return "", p.error("too many quotes at the end of a multi line string")
+			}
+			sb.WriteString(strings.Repeat(string(q), n-3))
+			p.pos += n
+			return sb.String(), nil
+		case c == '\n':
+			if !multi {
+				return "", p.error("a string cannot contain a line break. Use a multi line string")
+			}
+			sb.WriteByte('\n')
+			p.newLine()
+		case c == '\\' && q == '"':
+			p.pos++
+			if multi && p.lineEndingBackslash() {
+				continue
+			}
+			r, err := p.readEscape()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			return "", p.error("control characters must be escaped")
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// Skip white space and line breaks after a '\' at the end of a line
+func (p *tomlParser) lineEndingBackslash() bool {
+	i := 0
+	for p.peekAt(i) == ' ' || p.peekAt(i) == '\t' {
+		i++
+	}
+	if p.peekAt(i) != '\n' {
+		return false
+	}
+	for c := p.peek(); c == ' ' || c == '\t' || c == '\n'; c = p.peek() {
+		if c == '\n' {
+			p.newLine()
+		} else {
+			p.pos++
+		}
+	}
+	return true
+}
+
+// Read the escape after a '\' in a basic string
+func (p *tomlParser) readEscape() (rune, error) {
+	pos := p.pos - 1
+	c := p.peek()
+	p.pos++
+	if r, ok := tomlEscapes[c]; ok {
+		return r, nil
+	}
+	n := map[byte]int{'u': 4, 'U': 8}[c]
+	var r rune
+	for i := 0; i < n; i++ {
+		h, ok := hexValue(p.peek())
+		if !ok {
+			n = 0
+			break
+		}
+		r = r<<4 | rune(h)
+		p.pos++
+	}
+	if n == 0 || !utf8.ValidRune(r) {
+		return 0, p.errorAt(pos, p.line, p.lineStart, fmt.Sprintf("invalid escape '%s'", p.text[pos:p.pos]))
+	}
+	return r, nil
+}
+
+func (p *tomlParser) parseArray(name string) (NodeI, error) {
+	pos, line, lineStart := p.pos, p.line, p.lineStart
+	p.pos++
+	list := NewJsonList(name)
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return nil, p.errorAt(pos, line, lineStart, "the array is not closed")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			p.freeze(list)
+			return list, nil
+		}
+		value, err := p.parseValue("")
+		if err != nil {
+			return nil, err
+		}
+		list.Add(value)
+		p.skipSpace(true)
+		if p.peek() == ',' {
+			p.pos++
+		} else if p.peek() != ']' && !p.eof() {
+			return nil, p.error("expected ',' or ']'")
+		}
+	}
+}
+
+// Parse an inline table { a = 1, b = 2 }. It must be on one line.
+func (p *tomlParser) parseInlineTable(name string) (NodeI, error) {
+	p.pos++
+	table := NewJsonObject(name)
+	p.skipSpace(false)
+	if p.peek() == '}' {
+		p.pos++
+		p.freeze(table)
+		return table, nil
+	}
+	for {
+		p.skipSpace(false)
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			p.freeze(table)
+			return table, nil
+		case '\n', 0:
+			return nil, p.error("an inline table must be closed on the same line")
+		default:
+			return nil, p.error("expected ',' or '}'")
+		}
+	}
+}
+
+// Mark a value and the tables and arrays in it so they cannot be changed
+func (p *tomlParser) freeze(n NodeI) {
+	if c, ok := n.(NodeC); ok {
+		p.defs[c] = tomlFixed
+		for _, v := range c.GetValues() {
+			p.freeze(v)
+		}
+	}
+}
+
+// An error from TOMLValue for a node that toml cannot hold. Path is the path to the node.
+type TOMLError struct {
+	Path *Path
+	Msg  string
+}
+
+func (e *TOMLError) Error() string {
+	if e.Path.IsEmpty() {
+		return fmt.Sprintf("%s at the root", e.Msg)
+	}
+	return fmt.Sprintf("%s at '%s'", e.Msg, e.Path.Pointer())
+}
+
+func tomlError(path []string, msg string) error {
+	return &TOMLError{Path: &Path{path: path, delim: "."}, Msg: msg}
+}
+
+// Return the object as toml. Objects are written as [tables] and lists of objects as
+// [[arrays of tables]]. Objects and lists in a list are written inline.
+// A *TOMLError is returned for a value that toml cannot hold: a null, a list with values of
+// different types or an integer that is not an int64. The root must be a JsonObject.
+func TOMLValue(n NodeI) (string, error) {
+	root, ok := n.(*JsonObject)
+	if !ok {
+		return "", tomlError(parserEmptyPath, fmt.Sprintf("the root must be an OBJECT not a %s", GetNodeTypeName(n.GetNodeType())))
+	}
+	var sb strings.Builder
+	if err := tomlWriteTable(&sb, root, parserEmptyPath, parserEmptyPath); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func tomlArrayOfTables(n NodeI) bool {
+	l, ok := n.(*JsonList)
+	if !ok || l.Len() == 0 {
+		return false
+	}
+	for _, v := range l.GetValues() {
+		if v.GetNodeType() != NT_OBJECT {
+			return false
+		}
+	}
+	return true
+}
+
+// Write the key values of a table followed by the tables in it. keys are the keys of the
+// table header. path is the path to the table. It includes the index in an array of tables.
+func tomlWriteTable(sb *strings.Builder, t *JsonObject, keys, path []string) error {
+	tables := make([]string, 0)
+	for _, k := range t.keys {
+		v := *t.value[k]
+		if v.GetNodeType() == NT_OBJECT || tomlArrayOfTables(v) {
+			tables = append(tables, k)
+			continue
+		}
+		sb.WriteString(tomlKey(k))
+		sb.WriteString(" = ")
+		if err := tomlInline(sb, v, jpAppend(path, k)); err != nil {
+			return err
+		}
+		sb.WriteByte('\n')
+	}
+	for _, k := range tables {
+		hk, p := jpAppend(keys, k), jpAppend(path, k)
+		if obj, ok := (*t.value[k]).(*JsonObject); ok {
+			// A table that only has tables in it does not need a header
+			if obj.Len() == 0 || tomlHasValues(obj) {
+				tomlHeader(sb, "["+tomlKeys(hk)+"]")
+			}
+			if err := tomlWriteTable(sb, obj, hk, p); err != nil {
+				return err
+			}
+			continue
+		}
+		for i, v := range (*t.value[k]).(*JsonList).GetValues() {
+			tomlHeader(sb, "[["+tomlKeys(hk)+"]]")
+			if err := tomlWriteTable(sb, v.(*JsonObject), hk, jpAppend(p, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tomlHeader(sb *strings.Builder, header string) {
+	if sb.Len() > 0 {
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(header)
+	sb.WriteByte('\n')
+}
+
+func tomlHasValues(t *JsonObject) bool {
+	for _, v := range t.GetValues() {
+		if v.GetNodeType() != NT_OBJECT && !tomlArrayOfTables(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a value on one line. Objects are inline tables.
+func tomlInline(sb *strings.Builder, n NodeI, path []string) error {
+	switch v := n.(type) {
+	case *JsonNull:
+		return tomlError(path, "a null cannot be written as toml")
+	case *JsonString:
+		sb.WriteString(quoteUnicode(v.GetValue()))
+	case *JsonNumber:
+		literal := v.GetLiteral()
+		if !strings.ContainsAny(literal, ".eE") {
+			if _, err := v.GetInt64(); err != nil {
+				return tomlError(path, fmt.Sprintf("the integer %s is too large for toml", literal))
+			}
+		}
+		sb.WriteString(literal)
+	case *JsonList:
+		values := v.GetValues()
+		for i, item := range values {
+			if item.GetNodeType() == NT_NULL {
+				return tomlError(jpAppend(path, strconv.Itoa(i)), "a null cannot be written as toml")
+			}
+			if item.GetNodeType() != values[0].GetNodeType() {
+				return tomlError(path, fmt.Sprintf("a list with a %s and a %s cannot be written as toml", GetNodeTypeName(values[0].GetNodeType()), GetNodeTypeName(item.GetNodeType())))
+			}
+		}
+		sb.WriteByte('[')
+		for i, item := range values {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := tomlInline(sb, item, jpAppend(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte(']')
+	case *JsonObject:
+		if v.Len() == 0 {
+			sb.WriteString("{}")
+			return nil
+		}
+		sb.WriteString("{ ")
+		for i, k := range v.keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(tomlKey(k))
+			sb.WriteString(" = ")
+			if err := tomlInline(sb, *v.value[k], jpAppend(path, k)); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(" }")
+	default:
+		sb.WriteString(n.String())
+	}
+	return nil
+}
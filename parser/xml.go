@@ -0,0 +1,513 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type XMLNamespaces int
+
+const (
+	XML_NS_PREFIX XMLNamespaces = iota // Names have a prefix (soap:Body). Namespace declarations are @xmlns attributes
+	XML_NS_LOCAL                       // Names are the local name (Body). Namespace declarations are dropped
+	XML_NS_URI                         // Names start with the namespace URI in braces ({http://...}Body). Namespace declarations are dropped
+)
+
+const xmlURL = "http://www.w3.org/XML/1998/namespace"
+
+// Options for converting between xml and nodes. The zero value uses the defaults.
+type XMLOptions struct {
+	AttrPrefix   string            // The prefix of the names of attributes. Default "@"
+	TextKey      string            // The name of the text of an element with attributes or child elements. Default "#text"
+	Namespaces   XMLNamespaces     // How the names of elements and attributes in a namespace are written
+	Prefixes     map[string]string // The prefix to use for a namespace URI with XML_NS_PREFIX. Replaces the prefix in the document
+	ListElements []string          // Names of elements that are always in a JsonList. Other elements are only in a list if they repeat
+	RootName     string            // The name of the root element for XMLValue if there is no single root key. Default "root"
+	Indent       int               // The indent used by XMLValue. 0 writes the xml on one line
+}
+
+func (o XMLOptions) withDefaults() XMLOptions {
+	if o.AttrPrefix == "" {
+		o.AttrPrefix = "@"
+	}
+	if o.TextKey == "" {
+		o.TextKey = "#text"
+	}
+	if o.RootName == "" {
+		o.RootName = "root"
+	}
+	return o
+}
+
+// Parse an xml document. The root is a JsonObject with one key, the name of the root element.
+//
+// An element with no attributes and no child elements is a JsonString with its text (JsonNull if it is empty).
+// Other elements are a JsonObject. Attributes are JsonString named with the AttrPrefix ("@id"). Child elements
+// are named by the element name. Elements that repeat are a JsonList. The text is named TextKey ("#text").
+// Comments and processing instructions are ignored.
+func ParseXML(data []byte, options XMLOptions) (NodeC, error) {
+	return ParseXMLReader(bytes.NewReader(data), options)
+}
+
+// Parse xml read from r. The input is read as it is parsed so it is not held in memory.
+func ParseXMLReader(r io.Reader, options XMLOptions) (NodeC, error) {
+	x := newXMLReader(r, options)
+	root := NewJsonObject("")
+	for {
+		tok, err := x.d.Token()
+		if err == io.EOF {
+			if root.Len() == 0 {
+				return nil, x.errorAt("no xml element found", nil)
+			}
+			return root, nil
+		}
+		if err != nil {
+			return nil, x.error(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if root.Len() > 0 {
+				return nil, x.errorAt("only one root element is allowed", nil)
+			}
+			node, err := x.element(start)
+			if err != nil {
+				return nil, err
+			}
+			root.Add(node)
+		}
+	}
+}
+
+// Read xml from r and call onElement for each element with the name. Names are the same as in the
+// nodes returned by ParseXML. The elements are not kept so a large document can be read a piece at a
+// time. An element inside a matching element is part of that element and is not matched on its own.
+// An error returned by onElement stops the parse and is returned.
+func ParseXMLElements(r io.Reader, name string, options XMLOptions, onElement func(node NodeI) error) error {
+	x := newXMLReader(r, options)
+	for {
+		tok, err := x.d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return x.error(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			x.push(t)
+			if x.name(t.Name, false) != name {
+				continue
+			}
+			x.pop()
+			node, err := x.element(t)
+			if err != nil {
+				return err
+			}
+			if err := onElement(node); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			x.pop()
+		}
+	}
+}
+
+type xmlReader struct {
+	d       *xml.Decoder
+	in      *xmlInput
+	options XMLOptions
+	scopes  []map[string]string // The prefix for each namespace URI declared by the open elements
+	lists   map[string]bool
+}
+
+func newXMLReader(r io.Reader, options XMLOptions) *xmlReader {
+	in := &xmlInput{r: bufio.NewReader(r), line: 1}
+	x := &xmlReader{d: xml.NewDecoder(in), in: in, options: options.withDefaults(), lists: make(map[string]bool)}
+	x.scopes = []map[string]string{{xmlURL: "xml"}}
+	for _, l := range options.ListElements {
+		x.lists[l] = true
+	}
+	return x
+}
+
+func (x *xmlReader) error(err error) error {
+	var se *xml.SyntaxError
+	if errors.As(err, &se) {
+		return x.errorAt(se.Msg, err)
+	}
+	return x.errorAt(fmt.Sprintf("failed to read input. %s", err.Error()), err)
+}
+
+// Return a ParseError at the position of the decoder
+func (x *xmlReader) errorAt(msg string, err error) *ParseError {
+	offset := int(x.d.InputOffset())
+	pe := x.in.errorAt(offset, msg)
+	pe.Err = err
+	return pe
+}
+
+// The input of the decoder. The decoder reads a byte at a time so the line and the
+// recent bytes are known when there is an error.
+type xmlInput struct {
+	r             *bufio.Reader
+	pos           int
+	line          int
+	lineStart     int
+	prevLineStart int    // The decoder can step back one byte to the previous line
+	recent        []byte // The bytes before pos for the error context
+}
+
+func (in *xmlInput) ReadByte() (byte, error) {
+	b, err := in.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	in.pos++
+	if b == '\n' {
+		in.line++
+		in.prevLineStart, in.lineStart = in.lineStart, in.pos
+	}
+	if len(in.recent) >= diagContext*2 {
+		in.recent = append(in.recent[:0], in.recent[len(in.recent)-diagContext:]...)
+	}
+	in.recent = append(in.recent, b)
+	return b, nil
+}
+
+func (in *xmlInput) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	p[0] = b
+	return 1, nil
+}
+
+func (in *xmlInput) errorAt(offset int, msg string) *ParseError {
+	back := in.pos - offset
+	if back < 0 || back > len(in.recent) {
+		back = 0
+		offset = in.pos
+	}
+	line, lineStart := in.line, in.lineStart
+	if offset < lineStart {
+		line, lineStart = line-1, in.prevLineStart
+	}
+	before := in.recent[:len(in.recent)-back]
+	if len(before) > diagContext {
+		before = before[len(before)-diagContext:]
+	}
+	after := string(in.recent[len(in.recent)-back:])
+	if len(after) < diagContext {
+		next, _ := in.r.Peek(diagContext - len(after))
+		after = after + string(next)
+	}
+	return &ParseError{Msg: msg, Offset: offset, Line: line, Column: offset - lineStart + 1, Context: fmt.Sprintf("%s|%s", before, after)}
+}
+
+// Add the namespaces declared by an element
+func (x *xmlReader) push(start xml.StartElement) {
+	scope := make(map[string]string)
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" {
+			scope[a.Value] = a.Name.Local
+		} else if a.Name.Space == "" && a.Name.Local == "xmlns" {
+			scope[a.Value] = ""
+		}
+	}
+	x.scopes = append(x.scopes, scope)
+}
+
+func (x *xmlReader) pop() {
+	x.scopes = x.scopes[:len(x.scopes)-1]
+}
+
+// Return the prefix for a namespace URI
+func (x *xmlReader) prefix(uri string) (string, bool) {
+	if p, ok := x.options.Prefixes[uri]; ok {
+		return p, true
+	}
+	for i := len(x.scopes) - 1; i >= 0; i-- {
+		if p, ok := x.scopes[i][uri]; ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Return the name of an element or an attribute. The decoder has replaced the prefix with the namespace URI.
+func (x *xmlReader) name(n xml.Name, attr bool) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	switch x.options.Namespaces {
+	case XML_NS_LOCAL:
+		return n.Local
+	case XML_NS_URI:
+		return "{" + n.Space + "}" + n.Local
+	}
+	if p, ok := x.prefix(n.Space); ok {
+		if p == "" && !attr {
+			return n.Local
+		}
+		if p != "" {
+			return p + ":" + n.Local
+		}
+	}
+	return n.Space + ":" + n.Local // The prefix was not declared
+}
+
+// Return the attribute name for a namespace declaration. Declarations are only kept with XML_NS_PREFIX.
+func (x *xmlReader) declaration(a xml.Attr) (string, bool) {
+	if x.options.Namespaces != XML_NS_PREFIX {
+		return "", false
+	}
+	p := a.Name.Local
+	if a.Name.Space == "" {
+		p = ""
+	}
+	if mapped, ok := x.options.Prefixes[a.Value]; ok {
+		p = mapped
+	}
+	if p == "" {
+		return "xmlns", true
+	}
+	return "xmlns:" + p, true
+}
+
+// Read an element up to its end tag and return its node
+func (x *xmlReader) element(start xml.StartElement) (NodeI, error) {
+	x.push(start)
+	defer x.pop()
+	name := x.name(start.Name, false)
+	attrs := make([]NodeI, 0)
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			if n, ok := x.declaration(a); ok {
+				attrs = append(attrs, NewJsonString(x.options.AttrPrefix+n, a.Value))
+			}
+			continue
+		}
+		attrs = append(attrs, NewJsonString(x.options.AttrPrefix+x.name(a.Name, true), a.Value))
+	}
+	order := make([]string, 0)
+	children := make(map[string][]NodeI)
+	var text strings.Builder
+	for {
+		tok, err := x.d.Token()
+		if err != nil {
+			return nil, x.error(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := x.element(t)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := children[child.GetName()]; !ok {
+				order = append(order, child.GetName())
+			}
+			children[child.GetName()] = append(children[child.GetName()], child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			return x.build(name, attrs, order, children, text.String())
+		}
+	}
+}
+
+func (x *xmlReader) build(name string, attrs []NodeI, order []string, children map[string][]NodeI, text string) (NodeI, error) {
+	if len(attrs) == 0 && len(order) == 0 {
+		if text == "" {
+			return NewJsonNull(name), nil
+		}
+		return NewJsonString(name, text), nil
+	}
+	obj := NewJsonObject(name)
+	for _, a := range attrs {
+		if _, err := obj.Add(a); err != nil {
+			return nil, err
+		}
+	}
+	// Text between child elements is usually only white space used for indentation
+	if len(order) > 0 {
+		text = strings.TrimSpace(text)
+	}
+	if strings.TrimSpace(text) != "" {
+		if _, err := obj.Add(NewJsonString(x.options.TextKey, text)); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range order {
+		nodes := children[n]
+		var child NodeI = nodes[0]
+		if len(nodes) > 1 || x.lists[n] {
+			list := NewJsonList(n)
+			for _, c := range nodes {
+				c.setName("")
+				list.Add(c)
+			}
+			child = list
+		}
+		if _, err := obj.Add(child); err != nil {
+			return nil, fmt.Errorf("the element '%s' has the same name as an attribute or the text", n)
+		}
+	}
+	return obj, nil
+}
+
+// Return the node as xml. If the node is an object with a single key that is not an attribute
+// or the text then the key is the root element. Otherwise the node is the content of an element
+// named options.RootName. Objects are written as elements using the same names as ParseXML.
+// A JsonList is written as an element for each value. Names that are not valid in xml are an error.
+func XMLValue(n NodeI, options XMLOptions) (string, error) {
+	w := &xmlWriter{options: options.withDefaults()}
+	var sb strings.Builder
+	w.e = xml.NewEncoder(&sb)
+	if w.options.Indent > 0 {
+		w.e.Indent("", strings.Repeat(" ", w.options.Indent))
+	}
+	name, content, path := w.options.RootName, n, parserEmptyPath
+	if obj, ok := n.(*JsonObject); ok && obj.Len() == 1 {
+		k := obj.keys[0]
+		if !strings.HasPrefix(k, w.options.AttrPrefix) && k != w.options.TextKey {
+			name, content, path = k, *obj.value[k], []string{k}
+		}
+	}
+	if content.GetNodeType() == NT_LIST && len(path) == 0 {
+		return "", fmt.Errorf("a list must be the value of a key to be written as xml")
+	}
+	if err := w.element(name, content, path, ""); err != nil {
+		return "", err
+	}
+	if err := w.e.Flush(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+type xmlWriter struct {
+	e       *xml.Encoder
+	options XMLOptions
+}
+
+// Return the xml name for a node name. A name of the form {uri}local is in the namespace uri.
+func (w *xmlWriter) xmlName(name string, path []string) (xml.Name, error) {
+	n := xml.Name{Local: name}
+	if strings.HasPrefix(name, "{") && strings.Contains(name, "}") {
+		i := strings.Index(name, "}")
+		n = xml.Name{Space: name[1:i], Local: name[i+1:]}
+	}
+	for i, r := range n.Local {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.' || r == ':'))) {
+			return n, fmt.Errorf("'%s' is not a valid xml name at '%s'", name, (&Path{path: path}).Pointer())
+		}
+	}
+	if n.Local == "" {
+		return n, fmt.Errorf("an empty name cannot be written as xml at '%s'", (&Path{path: path}).Pointer())
+	}
+	return n, nil
+}
+
+// Write an element. defaultNS is the default namespace of the parent element.
+func (w *xmlWriter) element(name string, n NodeI, path []string, defaultNS string) error {
+	if l, ok := n.(*JsonList); ok {
+		for i, v := range l.GetValues() {
+			p := jpAppend(path, strconv.Itoa(i))
+			if v.GetNodeType() == NT_LIST {
+				return fmt.Errorf("a list in a list cannot be written as xml at '%s'", (&Path{path: p}).Pointer())
+			}
+			if err := w.element(name, v, p, defaultNS); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	xn, err := w.xmlName(name, path)
+	if err != nil {
+		return err
+	}
+	// The namespace is written as the default namespace when it changes. With XML_NS_URI
+	// a name with no namespace inside a default namespace has xmlns="". With the other
+	// options a name with no namespace is in the default namespace of its parent.
+	start := xml.StartElement{Name: xml.Name{Local: xn.Local}, Attr: make([]xml.Attr, 0)}
+	ns := xn.Space
+	obj, isObject := n.(*JsonObject)
+	declared := isObject && obj.GetNodeWithName(w.options.AttrPrefix+"xmlns") != nil
+	if declared {
+		ns = obj.GetNodeWithName(w.options.AttrPrefix + "xmlns").String()
+	} else if xn.Space != defaultNS && (xn.Space != "" || w.options.Namespaces == XML_NS_URI) {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: xn.Space})
+	} else {
+		ns = defaultNS
+	}
+	if isObject {
+		for _, k := range obj.keys {
+			if !strings.HasPrefix(k, w.options.AttrPrefix) {
+				continue
+			}
+			v := *obj.value[k]
+			p := jpAppend(path, k)
+			if v.IsContainer() {
+				return fmt.Errorf("the attribute '%s' must be a string, number or bool at '%s'", k, (&Path{path: p}).Pointer())
+			}
+			an, err := w.xmlName(k[len(w.options.AttrPrefix):], p)
+			if err != nil {
+				return err
+			}
+			if v.GetNodeType() != NT_NULL {
+				start.Attr = append(start.Attr, xml.Attr{Name: an, Value: v.String()})
+			}
+		}
+	}
+	if err := w.e.EncodeToken(start); err != nil {
+		return err
+	}
+	switch {
+	case isObject:
+		for _, k := range obj.keys {
+			v := *obj.value[k]
+			switch {
+			case strings.HasPrefix(k, w.options.AttrPrefix):
+			case k == w.options.TextKey:
+				if err := w.e.EncodeToken(xml.CharData(v.String())); err != nil {
+					return err
+				}
+			default:
+				if err := w.element(k, v, jpAppend(path, k), ns); err != nil {
+					return err
+				}
+			}
+		}
+	case n.GetNodeType() != NT_NULL:
+		if err := w.e.EncodeToken(xml.CharData(n.String())); err != nil {
+			return err
+		}
+	}
+	return w.e.EncodeToken(start.End())
+}
@@ -0,0 +1,1086 @@
+/*
+ * Copyright (C) 2021 Stuart Davies (stuartdd)
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The maximum number of nodes that aliases can create. Stops a small document expanding to a huge tree.
+const YAML_MAX_ALIAS_NODES = 1000000
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOct   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlInf   = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+	// Yaml 1.1 booleans. They are strings in yaml 1.2 but are quoted so older readers do not change them.
+	yamlOldBool = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|on|On|ON|off|Off|OFF)$`)
+)
+
+// Parse a yaml 1.2 document. Mappings are returned as JsonObject, sequences as JsonList and
+// scalars as JsonString, JsonNumber, JsonBool or JsonNull using the yaml core schema.
+// Block and flow styles, quoted, plain and block (| and >) scalars are supported.
+// Aliases are replaced with a copy of the anchored node and merge keys (<<) are applied.
+// An empty document is a JsonNull. Use ParseYAMLDocuments if there can be more than one document.
+func ParseYAML(yaml []byte) (NodeI, error) {
+	docs, err := ParseYAMLDocuments(yaml)
+	if err != nil {
+		return nil, err
+	}
+	switch len(docs) {
+	case 0:
+		return NewJsonNull(""), nil
+	case 1:
+		return docs[0], nil
+	}
+	return nil, fmt.Errorf("expected one yaml document but found %d. Use ParseYAMLDocuments", len(docs))
+}
+
+// Parse a yaml stream that can contain many documents separated by '---'
+func ParseYAMLDocuments(yaml []byte) ([]NodeI, error) {
+	p := &yamlParser{text: bytes.ReplaceAll(yaml, []byte("\r\n"), []byte("\n")), line: 1}
+	return p.parseDocuments()
+}
+
+type yamlParser struct {
+	text       []byte
+	pos        int
+	line       int // Line number starting at 1
+	lineStart  int // Offset of the start of the line
+	anchors    map[string]NodeI
+	aliasNodes int
+}
+
+// A position in the text so the parser can look ahead and go back
+type yamlMark struct {
+	pos, line, lineStart int
+}
+
+func (p *yamlParser) mark() yamlMark {
+	return yamlMark{pos: p.pos, line: p.line, lineStart: p.lineStart}
+}
+
+func (p *yamlParser) reset(m yamlMark) {
+	p.pos, p.line, p.lineStart = m.pos, m.line, m.lineStart
+}
+
+func (p *yamlParser) errorAt(m yamlMark, msg string) *ParseError {
+	return textError(p.text, m.pos, m.line, m.lineStart, msg)
+}
+
+func (p *yamlParser) error(msg string) *ParseError {
+	return p.errorAt(p.mark(), msg)
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.text)
+}
+
+// Return the byte at offset i from the current position. 0 is returned at the end of the text.
+func (p *yamlParser) peekAt(i int) byte {
+	if p.pos+i >= len(p.text) {
+		return 0
+	}
+	return p.text[p.pos+i]
+}
+
+func (p *yamlParser) peek() byte {
+	return p.peekAt(0)
+}
+
+func (p *yamlParser) next() byte {
+	c := p.text[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+		p.lineStart = p.pos
+	}
+	return c
+}
+
+func (p *yamlParser) col() int {
+	return p.pos - p.lineStart
+}
+
+func yamlSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == 0
+}
+
+func yamlFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+// Skip spaces and a comment. The end of the line is not skipped.
+func (p *yamlParser) skipInline() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// Skip spaces, comments and empty lines
+func (p *yamlParser) skipBlank() {
+	for {
+		p.skipInline()
+		if p.peek() != '\n' {
+			return
+		}
+		p.next()
+	}
+}
+
+func (p *yamlParser) atLineEnd() bool {
+	return p.eof() || p.peek() == '\n'
+}
+
+// Return true if there are only spaces before the current position on the line
+func (p *yamlParser) atLineStart() bool {
+	return len(bytes.TrimLeft(p.text[p.lineStart:p.pos], " ")) == 0
+}
+
+func (p *yamlParser) isDocMarker() bool {
+	if p.col() != 0 || p.pos+3 > len(p.text) {
+		return false
+	}
+	m := string(p.text[p.pos : p.pos+3])
+	return (m == "---" || m == "...") && yamlSpace(p.peekAt(3))
+}
+
+func (p *yamlParser) isSeqDash() bool {
+	return p.peek() == '-' && yamlSpace(p.peekAt(1))
+}
+
+// Return true if the content at the current position is more indented than indent. A mapping
+// value can be a sequence with the same indent as the key.
+func (p *yamlParser) indented(indent int, inMapping bool) bool {
+	if p.eof() || p.isDocMarker() {
+		return false
+	}
+	return p.col() > indent || (inMapping && p.col() == indent && p.isSeqDash())
+}
+
+func (p *yamlParser) parseDocuments() ([]NodeI, error) {
+	docs := make([]NodeI, 0)
+	for {
+		p.skipBlank()
+		for p.col() == 0 && p.peek() == '%' { // Directives such as %YAML 1.2
+			for !p.atLineEnd() {
+				p.pos++
+			}
+			p.skipBlank()
+		}
+		if p.eof() {
+			return docs, nil
+		}
+		if p.isDocMarker() {
+			if p.peek() == '.' {
+				p.pos += 3
+				continue
+			}
+			p.pos += 3
+		}
+		p.anchors = make(map[string]NodeI)
+		doc, err := p.parseValue("", -1, false)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+		if err := p.endOfEntry(); err != nil {
+			return nil, err
+		}
+		if !p.eof() && !p.isDocMarker() {
+			return nil, p.error("unexpected text after the end of the document. Check the indentation")
+		}
+	}
+}
+
+// Parse the value of a mapping entry or a sequence entry. indent is the column of the key or the '-'.
+// A block collection can only start on the same line after a '-'.
+func (p *yamlParser) parseValue(name string, indent int, inMapping bool) (NodeI, error) {
+	p.skipInline()
+	if !p.atLineEnd() {
+		return p.parseNode(name, indent, !inMapping, inMapping)
+	}
+	p.skipBlank()
+	if !p.indented(indent, inMapping) {
+		return NewJsonNull(name), nil
+	}
+	return p.parseNode(name, indent, true, inMapping)
+}
+
+// Parse a node with optional anchor and tag properties
+func (p *yamlParser) parseNode(name string, indent int, block, inMapping bool) (NodeI, error) {
+	start := p.mark()
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	var node NodeI
+	if (anchor != "" || tag != "") && p.atLineEnd() {
+		p.skipBlank()
+		if p.indented(indent, inMapping) {
+			node, err = p.parseContent(name, indent, true, tag)
+		} else {
+			node, err = p.emptyNode(name, tag, start)
+		}
+	} else {
+		node, err = p.parseContent(name, indent, block, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (tag == "map" && node.GetNodeType() != NT_OBJECT) || (tag == "seq" && node.GetNodeType() != NT_LIST) {
+		return nil, p.errorAt(start, fmt.Sprintf("a %s cannot have the tag !!%s", GetNodeTypeName(node.GetNodeType()), tag))
+	}
+	if anchor != "" {
+		p.anchors[anchor] = node
+	}
+	return node, nil
+}
+
+// Read anchor (&name) and tag (!tag) properties. Tags in the yaml core schema are returned
+// without the prefix, for example "str" for "!!str". Other tags are ignored.
+func (p *yamlParser) parseProperties() (anchor, tag string, err error) {
+	for {
+		c := p.peek()
+		if c != '&' && c != '!' {
+			return anchor, tag, nil
+		}
+		start := p.pos
+		for !yamlSpace(p.peek()) && (c == '!' || !yamlFlowIndicator(p.peek())) {
+			p.pos++
+		}
+		text := string(p.text[start+1 : p.pos])
+		if c == '&' {
+			if text == "" {
+				return "", "", p.error("an anchor must have a name")
+			}
+			anchor = text
+		} else {
+			text = strings.TrimPrefix(strings.TrimPrefix(text, "!"), "<tag:yaml.org,2002:")
+			switch text = strings.TrimSuffix(text, ">"); text {
+			case "str", "int", "float", "bool", "null", "map", "seq":
+				tag = text
+			}
+		}
+		p.skipInline()
+	}
+}
+
+// Return the node for a tag with no value
+func (p *yamlParser) emptyNode(name, tag string, start yamlMark) (NodeI, error) {
+	switch tag {
+	case "map":
+		return NewJsonObject(name), nil
+	case "seq":
+		return NewJsonList(name), nil
+	}
+	return p.scalar(name, "", true, tag, start)
+}
+
+// Parse a node at the current position. block is true if a block mapping or sequence can start here.
+func (p *yamlParser) parseContent(name string, indent int, block bool, tag string) (NodeI, error) {
+	start := p.mark()
+	switch c := p.peek(); {
+	case c == '*':
+		return p.parseAlias(name)
+	case p.isSeqDash():
+		if !block {
+			return nil, p.error("a block sequence cannot start on this line")
+		}
+		return p.parseSequence(name, p.col())
+	case c == '[' || c == '{':
+		return p.parseFlow(name)
+	case c == '|' || c == '>':
+		text, err := p.readBlockScalar(indent)
+		if err != nil {
+			return nil, err
+		}
+		return p.scalar(name, text, false, tag, start)
+	case c == '?':
+		return nil, p.error("complex mapping keys (?) are not supported")
+	}
+	if p.isKey() {
+		if !block {
+			return nil, p.error("a mapping cannot start on this line")
+		}
+		return p.parseMapping(name, p.col())
+	}
+	text, plain, err := p.readScalar(indent, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return p.scalar(name, text, plain, tag, start)
+}
+
+func (p *yamlParser) parseAlias(name string) (NodeI, error) {
+	start := p.mark()
+	p.pos++
+	for !yamlSpace(p.peek()) && !yamlFlowIndicator(p.peek()) {
+		p.pos++
+	}
+	alias := string(p.text[start.pos+1 : p.pos])
+	node, ok := p.anchors[alias]
+	if !ok {
+		return nil, p.errorAt(start, fmt.Sprintf("the alias '%s' has no anchor", alias))
+	}
+	p.aliasNodes += yamlCount(node)
+	if p.aliasNodes > YAML_MAX_ALIAS_NODES {
+		return nil, p.errorAt(start, fmt.Sprintf("aliases created more than %d nodes", YAML_MAX_ALIAS_NODES))
+	}
+	return Clone(node, name, true), nil
+}
+
+func yamlCount(n NodeI) int {
+	count := 1
+	if c, ok := n.(NodeC); ok {
+		for _, v := range c.GetValues() {
+			count += yamlCount(v)
+		}
+	}
+	return count
+}
+
+// Return true if the current line has a key followed by ':'
+func (p *yamlParser) isKey() bool {
+	m := p.mark()
+	defer p.reset(m)
+	if _, _, err := p.readScalar(-1, false, false); err != nil || p.line != m.line {
+		return false
+	}
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+	return p.peek() == ':' && yamlSpace(p.peekAt(1))
+}
+
+// Check the end of a mapping or sequence entry and skip to the next content
+func (p *yamlParser) endOfEntry() error {
+	p.skipInline()
+	if p.atLineEnd() {
+		p.skipBlank()
+		return nil
+	}
+	if p.atLineStart() {
+		return nil
+	}
+	return p.error(fmt.Sprintf("unexpected '%c'", p.peek()))
+}
+
+func (p *yamlParser) parseMapping(name string, indent int) (NodeI, error) {
+	obj := NewJsonObject(name)
+	merged := make(map[string]bool)
+	for {
+		start := p.mark()
+		if !p.isKey() {
+			return nil, p.error("expected a mapping key")
+		}
+		key, plain, _ := p.readScalar(-1, false, false)
+		for p.peek() != ':' {
+			p.pos++
+		}
+		p.pos++
+		value, err := p.parseValue(key, indent, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.addEntry(obj, key, plain, value, merged, start); err != nil {
+			return nil, err
+		}
+		if err := p.endOfEntry(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.isDocMarker() || p.col() < indent {
+			return obj, nil
+		}
+		if p.col() > indent {
+			return nil, p.error("bad indentation of a mapping entry")
+		}
+	}
+}
+
+// Add a value to a mapping. A merge key (<<) adds the entries of the mappings in the value
+// that are not in obj. These entries are replaced by entries with the same key.
+func (p *yamlParser) addEntry(obj *JsonObject, key string, plain bool, value NodeI, merged map[string]bool, start yamlMark) error {
+	if plain && key == "<<" {
+		sources := []NodeI{value}
+		if l, ok := value.(*JsonList); ok {
+			sources = l.GetValues()
+		}
+		for _, s := range sources {
+			src, ok := s.(*JsonObject)
+			if !ok {
+				return p.errorAt(start, "the value of a merge key (<<) must be a mapping or a list of mappings")
+			}
+			for _, k := range src.keys {
+				if _, found := obj.value[k]; !found {
+					obj.Add(Clone(*src.value[k], k, true))
+					merged[k] = true
+				}
+			}
+		}
+		return nil
+	}
+	if key == "" {
+		return p.errorAt(start, "a mapping key cannot be empty")
+	}
+	if existing, found := obj.value[key]; found {
+		if !merged[key] {
+			return p.errorAt(start, fmt.Sprintf("duplicate key '%s'", key))
+		}
+		obj.Remove(*existing)
+		delete(merged, key)
+	}
+	obj.Add(value)
+	return nil
+}
+
+func (p *yamlParser) parseSequence(name string, indent int) (NodeI, error) {
+	list := NewJsonList(name)
+	for {
+		p.pos++ // The '-'
+		item, err := p.parseValue("", indent, false)
+		if err != nil {
+			return nil, err
+		}
+		list.Add(item)
+		if err := p.endOfEntry(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.isDocMarker() || p.col() < indent || (p.col() == indent && !p.isSeqDash()) {
+			return list, nil
+		}
+		if p.col() > indent {
+			return nil, p.error("bad indentation of a sequence entry")
+		}
+	}
+}
+
+// Skip white space, line breaks and comments in a flow collection
+func (p *yamlParser) skipFlowSpace(start yamlMark) error {
+	p.skipBlank()
+	if p.eof() {
+		return p.errorAt(start, fmt.Sprintf("the '%c' is not closed", p.text[start.pos]))
+	}
+	return nil
+}
+
+// Parse a flow sequence [a, b] or a flow mapping {a: 1, b: 2}
+func (p *yamlParser) parseFlow(name string) (NodeI, error) {
+	start := p.mark()
+	if p.next() == '[' {
+		list := NewJsonList(name)
+		for {
+			if err := p.skipFlowSpace(start); err != nil {
+				return nil, err
+			}
+			if p.peek() == ']' {
+				p.pos++
+				return list, nil
+			}
+			itemStart := p.mark()
+			item, err := p.parseFlowNode("")
+			if err != nil {
+				return nil, err
+			}
+			if err := p.skipFlowSpace(start); err != nil {
+				return nil, err
+			}
+			if p.peek() == ':' && !item.IsContainer() { // A single pair mapping [a: 1]
+				p.pos++
+				value, err := p.parseFlowValue(item.String(), start)
+				if err != nil {
+					return nil, err
+				}
+				obj := NewJsonObject("")
+				if err := p.addEntry(obj, value.GetName(), false, value, nil, itemStart); err != nil {
+					return nil, err
+				}
+				item = obj
+			}
+			list.Add(item)
+			if err := p.endOfFlowEntry(start, ']'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	obj := NewJsonObject(name)
+	merged := make(map[string]bool)
+	for {
+		if err := p.skipFlowSpace(start); err != nil {
+			return nil, err
+		}
+		if p.peek() == '}' {
+			p.pos++
+			return obj, nil
+		}
+		keyStart := p.mark()
+		if p.peek() == '?' || p.peek() == '[' || p.peek() == '{' {
+			return nil, p.error("complex mapping keys are not supported")
+		}
+		key, plain, err := p.readScalar(-1, true, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skipFlowSpace(start); err != nil {
+			return nil, err
+		}
+		var value NodeI = NewJsonNull(key)
+		if p.peek() == ':' {
+			p.pos++
+			if value, err = p.parseFlowValue(key, start); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.addEntry(obj, key, plain, value, merged, keyStart); err != nil {
+			return nil, err
+		}
+		if err := p.endOfFlowEntry(start, '}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *yamlParser) endOfFlowEntry(start yamlMark, close byte) error {
+	if err := p.skipFlowSpace(start); err != nil {
+		return err
+	}
+	switch p.peek() {
+	case ',':
+		p.pos++
+		return nil
+	case close:
+		return nil
+	}
+	return p.error(fmt.Sprintf("expected ',' or '%c'", close))
+}
+
+// Parse the value after a ':' in a flow collection. The value can be empty.
+func (p *yamlParser) parseFlowValue(name string, start yamlMark) (NodeI, error) {
+	if err := p.skipFlowSpace(start); err != nil {
+		return nil, err
+	}
+	if c := p.peek(); c == ',' || c == '}' || c == ']' {
+		return NewJsonNull(name), nil
+	}
+	return p.parseFlowNode(name)
+}
+
+func (p *yamlParser) parseFlowNode(name string) (NodeI, error) {
+	start := p.mark()
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	var node NodeI
+	switch c := p.peek(); {
+	case c == '*':
+		node, err = p.parseAlias(name)
+	case c == '[' || c == '{':
+		node, err = p.parseFlow(name)
+	case c == ',' || c == ']' || c == '}':
+		node, err = p.emptyNode(name, tag, start)
+	default:
+		var text string
+		var plain bool
+		if text, plain, err = p.readScalar(-1, true, true); err == nil {
+			node, err = p.scalar(name, text, plain, tag, start)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = node
+	}
+	return node, nil
+}
+
+// Read a quoted or plain scalar. Plain scalars can continue on the following lines if
+// multiLine is true and the lines are indented more than indent.
+func (p *yamlParser) readScalar(indent int, flow, multiLine bool) (string, bool, error) {
+	switch c := p.peek(); c {
+	case '"', '\'':
+		s, err := p.readQuoted()
+		return s, false, err
+	case ',', '[', ']', '{', '}', '#', '&', '*', '!', '|', '>', '%', '@', '`':
+		return "", false, p.error(fmt.Sprintf("a plain value cannot start with '%c'", c))
+	}
+	return p.readPlain(indent, flow, multiLine), true, nil
+}
+
+// Read a plain (unquoted) scalar. It ends at ': ', ' #' or the end of the line. In a flow
+// collection it also ends at ',', '[', ']', '{' and '}'. Line breaks are folded in to a space.
+func (p *yamlParser) readPlain(indent int, flow, multiLine bool) string {
+	var sb strings.Builder
+	fold := ""
+	for {
+		start, end := p.pos, p.pos
+		for !p.eof() {
+			c := p.peek()
+			if c == '\n' || (flow && yamlFlowIndicator(c)) {
+				break
+			}
+			if c == ':' && (yamlSpace(p.peekAt(1)) || (flow && yamlFlowIndicator(p.peekAt(1)))) {
+				break
+			}
+			if c == '#' && p.pos > start && (p.text[p.pos-1] == ' ' || p.text[p.pos-1] == '\t') {
+				break
+			}
+			p.pos++
+			if c != ' ' && c != '\t' {
+				end = p.pos
+			}
+		}
+		if end == start && fold != "" {
+			return sb.String()
+		}
+		sb.WriteString(fold)
+		sb.Write(p.text[start:end])
+		p.pos = end
+		if !multiLine {
+			return sb.String()
+		}
+		// Look for a continuation line
+		m := p.mark()
+		lines := 0
+		for {
+			for p.peek() == ' ' || p.peek() == '\t' {
+				p.pos++
+			}
+			if p.peek() != '\n' {
+				break
+			}
+			p.next()
+			lines++
+		}
+		if lines == 0 || p.eof() || p.isDocMarker() || p.peek() == '#' || (!flow && p.col() <= indent) {
+			p.reset(m)
+			return sb.String()
+		}
+		fold = " "
+		if lines > 1 {
+			fold = strings.Repeat("\n", lines-1)
+		}
+	}
+}
+
+// Read a single or double quoted scalar. Line breaks are folded in to a space.
+func (p *yamlParser) readQuoted() (string, error) {
+	start := p.mark()
+	quote := p.next()
+	buf := make([]byte, 0)
+	keep := 0 // Spaces after keep are removed at a line break
+	for {
+		if p.eof() {
+			return "", p.errorAt(start, "the quoted value is not closed")
+		}
+		c := p.next()
+		switch {
+		case c == quote && quote == '\'' && p.peek() == '\'':
+			p.pos++
+			buf = append(buf, '\'')
+			keep = len(buf)
+		case c == quote:
+			return string(buf), nil
+		case c == '\n':
+			buf = buf[:keep]
+			lines := 0
+			for {
+				for p.peek() == ' ' || p.peek() == '\t' {
+					p.pos++
+				}
+				if p.peek() != '\n' {
+					break
+				}
+				p.next()
+				lines++
+			}
+			if lines == 0 {
+				buf = append(buf, ' ')
+			} else {
+				buf = append(buf, strings.Repeat("\n", lines)...)
+			}
+			keep = len(buf)
+		case c == '\\' && quote == '"':
+			if p.peek() == '\n' { // An escaped line break joins the lines
+				p.next()
+				for p.peek() == ' ' || p.peek() == '\t' {
+					p.pos++
+				}
+				continue
+			}
+			r, err := p.readEscape()
+			if err != nil {
+				return "", err
+			}
+			buf = utf8.AppendRune(buf, r)
+			keep = len(buf)
+		default:
+			buf = append(buf, c)
+			if c != ' ' && c != '\t' {
+				keep = len(buf)
+			}
+		}
+	}
+}
+
+var yamlEscapes = map[byte]rune{'0': 0, 'a': '\a', 'b': '\b', 't': '\t', '\t': '\t', 'n': '\n', 'v': '\v', 'f': '\f', 'r': '\r', 'e': 0x1b,
+	' ': ' ', '"': '"', '/': '/', '\\': '\\', 'N': 0x85, '_': 0xa0, 'L': 0x2028, 'P': 0x2029}
+
+// Read the escape after a '\' in a double quoted scalar
+func (p *yamlParser) readEscape() (rune, error) {
+	start := p.mark()
+	if p.eof() {
+		return 0, p.error("the quoted value is not closed")
+	}
+	c := p.next()
+	if r, ok := yamlEscapes[c]; ok {
+		return r, nil
+	}
+	n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if n == 0 || p.pos+n > len(p.text) {
+		return 0, p.errorAt(start, fmt.Sprintf("invalid escape '\\%c'", c))
+	}
+	var r rune
+	for i := 0; i < n; i++ {
+		h, ok := hexValue(p.next())
+		if !ok {
+			return 0, p.errorAt(start, fmt.Sprintf("invalid escape '\\%s'", p.text[start.pos:p.pos]))
+		}
+		r = r<<4 | rune(h)
+	}
+	return r, nil
+}
+
+// Read a literal (|) or folded (>) block scalar. indent is the indent of the parent node.
+func (p *yamlParser) readBlockScalar(indent int) (string, error) {
+	folded := p.next() == '>'
+	chomp := byte(0)
+	content := -1
+	for i := 0; i < 2; i++ {
+		if c := p.peek(); c == '+' || c == '-' {
+			chomp = c
+			p.pos++
+		} else if c >= '1' && c <= '9' {
+			content = int(c-'0') + indent
+			if indent < 0 {
+				content++
+			}
+			p.pos++
+		}
+	}
+	if c := p.peek(); c != ' ' && c != '\t' && c != '\n' && c != 0 {
+		return "", p.error(fmt.Sprintf("invalid block scalar indicator '%c'", c))
+	}
+	p.skipInline()
+	if !p.atLineEnd() {
+		return "", p.error("a block scalar must start on the next line")
+	}
+	lines := make([]string, 0)
+	for !p.eof() {
+		p.next() // The line break
+		start := p.pos
+		sp := 0
+		for p.peekAt(sp) == ' ' {
+			sp++
+		}
+		if c := p.peekAt(sp); c == '\n' || c == 0 {
+			if content >= 0 && sp > content {
+				lines = append(lines, string(p.text[start+content:start+sp]))
+			} else {
+				lines = append(lines, "")
+			}
+			p.pos += sp
+			continue
+		}
+		if content < 0 {
+			content = sp
+		}
+		if sp < content || sp <= indent || (sp == 0 && p.isDocMarker()) {
+			break
+		}
+		p.pos = start + content
+		for !p.atLineEnd() {
+			p.pos++
+		}
+		lines = append(lines, string(p.text[start+content:p.pos]))
+	}
+	last := len(lines) - 1
+	for last >= 0 && lines[last] == "" {
+		last--
+	}
+	body, trailing := lines[:last+1], len(lines)-last-1
+	var sb strings.Builder
+	more := false
+	empty := 0
+	for i, l := range body {
+		if l == "" {
+			empty++
+			continue
+		}
+		lineMore := l[0] == ' ' || l[0] == '\t'
+		switch {
+		case i == empty:
+			sb.WriteString(strings.Repeat("\n", empty))
+		case !folded || more || lineMore:
+			sb.WriteString(strings.Repeat("\n", empty+1))
+		case empty == 0:
+			sb.WriteByte(' ')
+		default:
+			sb.WriteString(strings.Repeat("\n", empty))
+		}
+		sb.WriteString(l)
+		more, empty = lineMore, 0
+	}
+	switch {
+	case chomp == '+':
+		if len(body) > 0 {
+			trailing++
+		}
+		sb.WriteString(strings.Repeat("\n", trailing))
+	case chomp == 0 && len(body) > 0:
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
+
+// Return the node for a scalar. A plain scalar without a tag is a null, bool, number or string using the core schema.
+func (p *yamlParser) scalar(name, text string, plain bool, tag string, start yamlMark) (NodeI, error) {
+	if tag == "" && !plain || tag == "str" {
+		return NewJsonString(name, text), nil
+	}
+	node, err := yamlResolve(name, text)
+	if err != nil {
+		return nil, p.errorAt(start, err.Error())
+	}
+	switch {
+	case tag == "int" || tag == "float":
+		if node.GetNodeType() != NT_NUMBER || (tag == "int" && !node.(*JsonNumber).IsInteger()) {
+			return nil, p.errorAt(start, fmt.Sprintf("'%s' is not a valid !!%s", text, tag))
+		}
+	case tag == "bool" && node.GetNodeType() != NT_BOOL, tag == "null" && node.GetNodeType() != NT_NULL:
+		return nil, p.errorAt(start, fmt.Sprintf("'%s' is not a valid !!%s", text, tag))
+	case tag == "map" || tag == "seq":
+		return nil, p.errorAt(start, fmt.Sprintf("a scalar cannot have the tag !!%s", tag))
+	}
+	return node, nil
+}
+
+// Resolve a plain scalar using the yaml 1.2 core schema
+func yamlResolve(name, text string) (NodeI, error) {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return NewJsonNull(name), nil
+	case "true", "True", "TRUE":
+		return NewJsonBool(name, true), nil
+	case "false", "False", "FALSE":
+		return NewJsonBool(name, false), nil
+	}
+	if yamlInf.MatchString(text) {
+		return nil, fmt.Errorf("the number '%s' cannot be held in a JsonNumber", text)
+	}
+	literal := strings.TrimPrefix(text, "+")
+	var i big.Int
+	switch {
+	case yamlOct.MatchString(text):
+		i.SetString(text[2:], 8)
+	case yamlHex.MatchString(text):
+		i.SetString(text[2:], 16)
+	case yamlInt.MatchString(text):
+		i.SetString(literal, 10)
+	case yamlFloat.MatchString(text):
+		if n, err := NewJsonNumberFromLiteral(name, literal); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("the number '%s' cannot be held in a JsonNumber", text)
+		}
+		return NewJsonNumber(name, f), nil
+	default:
+		return NewJsonString(name, text), nil
+	}
+	return NewJsonNumberFromLiteral(name, i.String())
+}
+
+// Return the node as yaml. Nested mappings and sequences are indented by 2 spaces.
+// The name of n is not written.
+func YAMLValue(n NodeI) string {
+	return YAMLValueIndented(n, 2)
+}
+
+// Return the node as yaml. Nested mappings and sequences are indented by tab spaces (at least 2).
+func YAMLValueIndented(n NodeI, tab int) string {
+	if tab < 2 {
+		tab = 2
+	}
+	var sb strings.Builder
+	yamlValueTabIndent(&sb, n, tab, 0, true)
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// Write the value of n. The key or '-' has been written. indent is the level of the key or '-'.
+// first is true if the first entry of a container goes on the current line.
+func yamlValueTabIndent(sb *strings.Builder, n NodeI, tab, indent int, first bool) {
+	switch v := n.(type) {
+	case *JsonObject:
+		if v.Len() == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		for i, k := range v.keys {
+			if i > 0 || !first {
+				sb.WriteString(Padding(tab, indent, INDENT_ON))
+			}
+			sb.WriteString(yamlString(k, true))
+			sb.WriteByte(':')
+			yamlEntry(sb, *v.value[k], tab, indent, false)
+		}
+	case *JsonList:
+		if v.Len() == 0 {
+			sb.WriteString("[]")
+			return
+		}
+		for i, item := range v.GetValues() {
+			if i > 0 || !first {
+				sb.WriteString(Padding(tab, indent, INDENT_ON))
+			}
+			sb.WriteByte('-')
+			yamlEntry(sb, item, tab, indent, true)
+		}
+	case *JsonString:
+		s := v.GetValue()
+		if !yamlLiteral(s) {
+			sb.WriteString(yamlString(s, false))
+			return
+		}
+		body := strings.TrimRight(s, "\n")
+		switch len(s) - len(body) {
+		case 0:
+			sb.WriteString("|-")
+		case 1:
+			sb.WriteString("|")
+		default:
+			sb.WriteString("|+")
+		}
+		for _, l := range strings.Split(body, "\n") {
+			if l == "" {
+				sb.WriteByte('\n')
+			} else {
+				sb.WriteString(Padding(tab, indent+1, INDENT_ON))
+				sb.WriteString(l)
+			}
+		}
+		if len(s)-len(body) > 1 {
+			sb.WriteString(strings.Repeat("\n", len(s)-len(body)-1))
+		}
+	case *JsonNumber:
+		sb.WriteString(v.GetLiteral())
+	default:
+		sb.WriteString(n.String())
+	}
+}
+
+// Write the value of a mapping or sequence entry. A non empty mapping or sequence in a sequence
+// starts on the same line as the '-'. In a mapping it starts on the next line.
+func yamlEntry(sb *strings.Builder, n NodeI, tab, indent int, inList bool) {
+	if c, ok := n.(NodeC); ok && c.Len() > 0 {
+		if inList {
+			sb.WriteString(strings.Repeat(" ", tab-1))
+		}
+		yamlValueTabIndent(sb, n, tab, indent+1, inList)
+		return
+	}
+	sb.WriteByte(' ')
+	yamlValueTabIndent(sb, n, tab, indent, true)
+}
+
+// Return true if a string is written as a literal block scalar (|)
+func yamlLiteral(s string) bool {
+	if !strings.Contains(s, "\n") || s[0] == ' ' || s[0] == '\n' {
+		return false
+	}
+	for _, r := range s {
+		if r != '\n' && r != '\t' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Return a string as a plain scalar if it would be read back as the same string. Otherwise
+// return it double quoted.
+func yamlString(s string, key bool) string {
+	if yamlPlain(s) && !(key && s == "<<") {
+		return s
+	}
+	return quoteUnicode(s)
+}
+
+// Return s in double quotes with json escapes. Non ASCII chars are UTF-8 unless they are not
+// printable. These are \u or \U escapes. Surrogate pairs are not used so the text is valid yaml and toml.
+func quoteUnicode(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range EncodeQuotedStringWith(s, ENCODE_UTF8) {
+		switch {
+		case r < 0x7f || unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r > 0xffff:
+			sb.WriteString(fmt.Sprintf("\\U%08X", r))
+		default:
+			sb.WriteString(fmt.Sprintf("\\u%04X", r))
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` \t") || strings.HasPrefix(s, "...") {
+		return false
+	}
+	if strings.HasSuffix(s, " ") || strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	if yamlOldBool.MatchString(s) {
+		return false
+	}
+	n, err := yamlResolve("", s)
+	return err == nil && n.GetNodeType() == NT_STRING
+}
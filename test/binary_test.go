@@ -0,0 +1,220 @@
+package test
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+// Examples from RFC 8949 Appendix A
+var cborExamples = []struct{ json, cbor string }{
+	{`0`, "00"}, {`23`, "17"}, {`24`, "1818"}, {`100`, "1864"}, {`1000`, "1903e8"}, {`1000000`, "1a000f4240"},
+	{`1000000000000`, "1b000000e8d4a51000"}, {`18446744073709551615`, "1bffffffffffffffff"},
+	{`18446744073709551616`, "c249010000000000000000"}, {`-18446744073709551616`, "3bffffffffffffffff"},
+	{`-18446744073709551617`, "c349010000000000000000"}, {`-1`, "20"}, {`-10`, "29"}, {`-100`, "3863"}, {`-1000`, "3903e7"},
+	{`0.0`, "f90000"}, {`-0.0`, "f98000"}, {`1.0`, "f93c00"}, {`1.1`, "fb3ff199999999999a"}, {`1.5`, "f93e00"},
+	{`65504.0`, "f97bff"}, {`100000.0`, "fa47c35000"}, {`3.4028234663852886e+38`, "fa7f7fffff"}, {`1e+300`, "fb7e37e43c8800759c"},
+	{`5.960464477539063e-8`, "f90001"}, {`0.00006103515625`, "f90400"}, {`-4.0`, "f9c400"}, {`-4.1`, "fbc010666666666666"},
+	{`false`, "f4"}, {`true`, "f5"}, {`null`, "f6"}, {`""`, "60"}, {`"a"`, "6161"}, {`"IETF"`, "6449455446"},
+	{`"\"\\"`, "62225c"}, {`"ü"`, "62c3bc"}, {`"水"`, "63e6b0b4"}, {`[]`, "80"}, {`[1, 2, 3]`, "83010203"},
+	{`[1, [2, 3], [4, 5]]`, "8301820203820405"}, {`{}`, "a0"}, {`{"a": 1, "b": [2, 3]}`, "a26161016162820203"},
+	{`["a", {"b": "c"}]`, "826161a161626163"},
+}
+
+func TestCBOR(t *testing.T) {
+	for _, e := range cborExamples {
+		checkBinary(t, "cbor", e.json, e.cbor, parser.BinaryOptions{})
+	}
+	// Other forms that are read but not written
+	checkParseBinary(t, "cbor", "5f42010243030405ff", `"AQIDBAU="`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "7f657374726561646d696e67ff", `"streaming"`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "9fff", `[]`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "bf61610161629f0203ffff", `{"a": 1, "b": [2, 3]}`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "c074323031332d30332d32315432303a30343a30305a", `"2013-03-21T20:04:00Z"`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "a2016161026162", `{"1": "a", "2": "b"}`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "f7", `null`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "fa3dcccccd", `0.10000000149011612`, parser.BinaryOptions{})
+	checkParseBinary(t, "cbor", "fb4000000000000000", `2.0`, parser.BinaryOptions{})
+
+	checkBinary(t, "cbor", `{"b": 1, "aa": 2, "a": 3}`, "a3 616103 616201 62616102", parser.BinaryOptions{Deterministic: true})
+	checkBinary(t, "cbor", `{"b": 1, "aa": 2}`, "a2616201626161 02", parser.BinaryOptions{})
+	checkBinary(t, "cbor", `["b64:AQID", "b64:not base64", "AQID"]`, "83 43010203 6e6236343a6e6f7420626173653634 6441514944", parser.BinaryOptions{BytesPrefix: "b64:"})
+	checkParseBinary(t, "cbor", "4401020304", `"AQIDBA=="`, parser.BinaryOptions{})
+
+	checkBinaryError(t, "cbor", "", 0, "unexpected end of the data")
+	checkBinaryError(t, "cbor", "1a0000", 3, "unexpected end of the data")
+	checkBinaryError(t, "cbor", "8301", 2, "unexpected end of the data")
+	checkBinaryError(t, "cbor", "0101", 1, "unexpected data after the value")
+	checkBinaryError(t, "cbor", "1c", 0, "invalid additional information 28 for major type 0")
+	checkBinaryError(t, "cbor", "ff", 0, "unexpected break")
+	checkBinaryError(t, "cbor", "62c328", 0, "a text string is not valid utf-8")
+	checkBinaryError(t, "cbor", "a2616101616102", 4, "duplicate map key 'a'")
+	checkBinaryError(t, "cbor", "a1f501", 1, "a map key must be a string or an integer not a BOOL")
+	checkBinaryError(t, "cbor", "a16001", 1, "a map key cannot be empty")
+	checkBinaryError(t, "cbor", "f97c00", 0, "the number '+Inf' cannot be held in a JsonNumber")
+	checkBinaryError(t, "cbor", "f0", 0, "the simple value 16 is not supported")
+	checkBinaryError(t, "cbor", "5f6161ff", 1, "a chunk of an indefinite length string must be a definite length string of the same type")
+	checkBinaryError(t, "cbor", "c201", 0, "a bignum must be a byte string")
+	checkBinaryError(t, "cbor", strings.Repeat("81", 1001)+"00", 1000, "the data is nested more than 1000 levels")
+
+	checkBinaryValueError(t, "cbor", `{"a": [1e400]}`, "the number 1e400 is too large for a float at '/a/0'")
+}
+
+func TestMsgPack(t *testing.T) {
+	checkBinary(t, "msgpack", `0`, "00", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `127`, "7f", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `128`, "cc80", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `256`, "cd0100", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `65536`, "ce00010000", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `4294967296`, "cf0000000100000000", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `18446744073709551615`, "cfffffffffffffffff", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `-1`, "ff", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `-32`, "e0", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `-33`, "d0df", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `-129`, "d1ff7f", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `-32769`, "d2ffff7fff", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `-9223372036854775808`, "d38000000000000000", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `1.5`, "ca3fc00000", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `1.0`, "ca3f800000", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `1.1`, "cb3ff199999999999a", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `null`, "c0", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `[true, false]`, "92c3c2", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `{"a": 1, "b": "ü"}`, "82a16101a162a2c3bc", parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `"`+strings.Repeat("x", 32)+`"`, "d920"+strings.Repeat("78", 32), parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `[`+strings.Repeat("0,", 15)+`0]`, "dc0010"+strings.Repeat("00", 16), parser.BinaryOptions{})
+	checkBinary(t, "msgpack", `{"b": 1, "aa": 2, "a": 3}`, "83a16103a16201a2616102", parser.BinaryOptions{Deterministic: true})
+	checkBinary(t, "msgpack", `"b64:AQID"`, "c403010203", parser.BinaryOptions{BytesPrefix: "b64:"})
+	checkParseBinary(t, "msgpack", "c403010203", `"AQID"`, parser.BinaryOptions{})
+	checkParseBinary(t, "msgpack", "d6ff00000001", `"1970-01-01T00:00:01Z"`, parser.BinaryOptions{})
+	checkParseBinary(t, "msgpack", "d7ff0000000400000002", `"1970-01-01T00:00:02.000000001Z"`, parser.BinaryOptions{})
+	checkParseBinary(t, "msgpack", "c70cff00000001fffffffffffffffe", `"1969-12-31T23:59:58.000000001Z"`, parser.BinaryOptions{})
+	checkParseBinary(t, "msgpack", "81a16101", `{"a": 1}`, parser.BinaryOptions{})
+	checkParseBinary(t, "msgpack", "810102", `{"1": 2}`, parser.BinaryOptions{})
+
+	checkBinaryError(t, "msgpack", "", 0, "unexpected end of the data")
+	checkBinaryError(t, "msgpack", "c1", 0, "the type 0xc1 is not valid")
+	checkBinaryError(t, "msgpack", "cd01", 2, "unexpected end of the data")
+	checkBinaryError(t, "msgpack", "0000", 1, "unexpected data after the value")
+	checkBinaryError(t, "msgpack", "a2c328", 0, "a string is not valid utf-8")
+	checkBinaryError(t, "msgpack", "82a16101a16102", 4, "duplicate map key 'a'")
+	checkBinaryError(t, "msgpack", "d40100", 0, "the extension type 1 is not supported")
+	checkBinaryError(t, "msgpack", "d5ff0000", 0, "a timestamp cannot have 2 bytes")
+	checkBinaryError(t, "msgpack", "cb7ff8000000000001", 0, "the number 'NaN' cannot be held in a JsonNumber")
+
+	checkBinaryValueError(t, "msgpack", `{"a": 18446744073709551616}`, "the integer 18446744073709551616 is too large for msgpack at '/a'")
+	checkBinaryValueError(t, "msgpack", `[-9223372036854775809]`, "the integer -9223372036854775809 is too large for msgpack at '/0'")
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	node := parseAny(t, `{"id": 123456789012, "temp": 21.5, "ratio": 0.1, "whole": 2.0, "ok": true, "none": null,
+		"tags": ["a", "ü😀"], "nested": {"list": [[], {}], "big": -12345678901234567890}, "raw": "bin:AAEC/w=="}`)
+	options := parser.BinaryOptions{Deterministic: true, BytesPrefix: "bin:"}
+	cbor, err := parser.CBORValue(node, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := parser.ParseCBOR(cbor, options)
+	if err != nil || !parser.EqualValues(back, node) {
+		t.Errorf("cbor did not parse back to %s. got %v error %v", node.JsonValue(), back, err)
+	}
+	again, _ := parser.CBORValue(back, options)
+	if hex.EncodeToString(again) != hex.EncodeToString(cbor) {
+		t.Errorf("deterministic cbor changed. %x expected %x", again, cbor)
+	}
+	// MessagePack cannot hold the big integer
+	nested := node.(*parser.JsonObject).GetNodeWithName("nested").(*parser.JsonObject)
+	nested.Remove(nested.GetNodeWithName("big"))
+	mp, err := parser.MsgPackValue(node, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err = parser.ParseMsgPack(mp, options)
+	if err != nil || !parser.EqualValues(back, node) {
+		t.Errorf("msgpack did not parse back to %s. got %v error %v", node.JsonValue(), back, err)
+	}
+	if back.(*parser.JsonObject).GetNodeWithName("whole").String() != "2.0" {
+		t.Errorf("a float with no fraction should stay a float. got %s", back.(*parser.JsonObject).GetNodeWithName("whole").String())
+	}
+}
+
+func binaryFunctions(format string) (func(parser.NodeI, parser.BinaryOptions) ([]byte, error), func([]byte, parser.BinaryOptions) (parser.NodeI, error)) {
+	if format == "cbor" {
+		return parser.CBORValue, parser.ParseCBOR
+	}
+	return parser.MsgPackValue, parser.ParseMsgPack
+}
+
+func binaryHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Check the json is encoded as the hex and the hex is parsed back to a node that encodes to the same hex
+func checkBinary(t *testing.T, format, json, expected string, options parser.BinaryOptions) {
+	t.Helper()
+	encode, decode := binaryFunctions(format)
+	b, err := encode(parseAny(t, json), options)
+	if err != nil {
+		t.Errorf("%s: failed to encode %s: %s", format, json, err)
+		return
+	}
+	if hex.EncodeToString(b) != hex.EncodeToString(binaryHex(t, expected)) {
+		t.Errorf("%s: %s encoded as %x expected %s", format, json, b, expected)
+	}
+	checkParseBinary(t, format, expected, json, options)
+	node, err := decode(b, options)
+	if err != nil {
+		return
+	}
+	if again, _ := encode(node, options); hex.EncodeToString(again) != hex.EncodeToString(b) {
+		t.Errorf("%s: %x parsed as %s and encoded as %x", format, b, node.JsonValue(), again)
+	}
+}
+
+func checkParseBinary(t *testing.T, format, data, expected string, options parser.BinaryOptions) {
+	t.Helper()
+	_, decode := binaryFunctions(format)
+	node, err := decode(binaryHex(t, data), options)
+	if err != nil {
+		t.Errorf("%s: failed to parse %s: %s", format, data, err)
+		return
+	}
+	if !parser.EqualValues(node, parseAny(t, expected)) {
+		t.Errorf("%s: %s parsed as %s expected %s", format, data, node.JsonValue(), parseAny(t, expected).JsonValue())
+	}
+}
+
+func checkBinaryError(t *testing.T, format, data string, offset int, msg string) {
+	t.Helper()
+	_, decode := binaryFunctions(format)
+	_, err := decode(binaryHex(t, data), parser.BinaryOptions{})
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("%s: %s expected a ParseError. Got %v", format, data, err)
+		return
+	}
+	if pe.Offset != offset || pe.Msg != msg {
+		t.Errorf("%s: %s error %d %q expected %d %q", format, data, pe.Offset, pe.Msg, offset, msg)
+	}
+	// Binary input has no lines or columns
+	if expected := fmt.Sprintf("parser Error: %s. Offset: %d.", msg, offset); err.Error() != expected {
+		t.Errorf("%s: %s error %q expected %q", format, data, err.Error(), expected)
+	}
+}
+
+func checkBinaryValueError(t *testing.T, format, json, msg string) {
+	t.Helper()
+	encode, _ := binaryFunctions(format)
+	_, err := encode(parseAny(t, json), parser.BinaryOptions{})
+	if err == nil || err.Error() != msg {
+		t.Errorf("%s: %s error %v expected %q", format, json, err, msg)
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/cli"
+)
+
+const cliDoc = `{"a": {"b": [1, 2, {"c": "x"}]}, "d": "hi"}`
+
+func TestCliFmt(t *testing.T) {
+	checkCli(t, cliDoc, cli.EXIT_OK, "{\n  \"a\": {\n    \"b\": [\n      1,\n      2,\n      {\n        \"c\": \"x\"\n      }\n    ]\n  },\n  \"d\": \"hi\"\n}\n", "fmt", "-indent", "2")
+	checkCli(t, cliDoc, cli.EXIT_OK, "{\"a\": {\"b\": [1,2,{\"c\": \"x\"}]},\"d\": \"hi\"}\n", "fmt", "-compact", "-")
+	checkCli(t, `{"a": }`, cli.EXIT_PARSE, "", "fmt")
+	checkCli(t, "", cli.EXIT_USAGE, "", "fmt", "-bad")
+
+	file := filepath.Join(t.TempDir(), "doc.json")
+	os.WriteFile(file, []byte(cliDoc), 0600)
+	checkCli(t, "", cli.EXIT_OK, "", "fmt", "-compact", "-w", file)
+	dat, _ := os.ReadFile(file)
+	if string(dat) != "{\"a\": {\"b\": [1,2,{\"c\": \"x\"}]},\"d\": \"hi\"}\n" {
+		t.Errorf("file was not formatted in place: %s", string(dat))
+	}
+	checkCli(t, "", cli.EXIT_ERROR, "", "fmt", filepath.Join(t.TempDir(), "missing.json"))
+	checkCli(t, "", cli.EXIT_ERROR, "", "fmt", t.TempDir())
+}
+
+func TestCliGet(t *testing.T) {
+	checkCli(t, cliDoc, cli.EXIT_OK, "\"x\"\n", "get", "a.b.2.c")
+	checkCli(t, cliDoc, cli.EXIT_OK, "x\n", "get", "-raw", "/a/b/2/c")
+	checkCli(t, cliDoc, cli.EXIT_OK, "2\n", "get", "/a/b/1")
+	checkCli(t, cliDoc, cli.EXIT_OK, "{\n    \"c\": \"x\"\n}\n", "get", "a.b.2")
+	checkCli(t, cliDoc, cli.EXIT_NOT_FOUND, "", "get", "a.x")
+	checkCli(t, cliDoc, cli.EXIT_NOT_FOUND, "", "get", "/a/b/9")
+	checkCli(t, `[1,`, cli.EXIT_PARSE, "", "get", "a")
+	checkCli(t, cliDoc, cli.EXIT_USAGE, "", "get")
+	checkCli(t, cliDoc, cli.EXIT_USAGE, "", "get", "/a~2")
+}
+
+func TestCliSetDelete(t *testing.T) {
+	checkCli(t, `{"a": [1]}`, cli.EXIT_OK, "{\n    \"a\": [\n        1\n    ],\n    \"b\": \"x y\"\n}\n", "set", "-string", "b", "x y")
+	checkCli(t, `{"a": [1]}`, cli.EXIT_OK, "{\n    \"a\": [\n        {\n            \"n\": true\n        }\n    ]\n}\n", "set", "a.0", `{"n": true}`)
+	checkCli(t, `{"a": [1]}`, cli.EXIT_OK, "{\n    \"a\": [\n        1,\n        2\n    ]\n}\n", "set", "/a/-", `2`)
+	checkCli(t, `{"a": [1]}`, cli.EXIT_OK, "[\n    true\n]\n", "set", "", `[true]`)
+	checkCli(t, `{"a": [1]}`, cli.EXIT_NOT_FOUND, "", "set", "x.y", `1`)
+	checkCli(t, `{"a": [1]}`, cli.EXIT_PARSE, "", "set", "a", `x y`)
+	checkCli(t, `{"a": [1], "b": 2}`, cli.EXIT_OK, "{\n    \"b\": 2\n}\n", "delete", "a")
+	checkCli(t, `{"a": [1]}`, cli.EXIT_NOT_FOUND, "", "delete", "b")
+	checkCli(t, `{"a": [1]}`, cli.EXIT_USAGE, "", "delete", "")
+	checkCli(t, `{"a": [1]}`, cli.EXIT_USAGE, "", "delete", "-w", "a")
+}
+
+func TestCliValidateDiag(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.json")
+	os.WriteFile(schema, []byte(`{"type": "object", "required": ["name"]}`), 0600)
+	checkCli(t, cliDoc, cli.EXIT_OK, "stdin: ok\n", "validate")
+	checkCli(t, `{"a"}`, cli.EXIT_PARSE, "", "validate")
+	checkCli(t, `{"name": 1}`, cli.EXIT_OK, "stdin: ok\n", "validate", "-schema", schema)
+	checkCli(t, `{"a": 1}`, cli.EXIT_INVALID, "stdin: #: required property 'name' is missing (#/required)\n", "validate", "-schema", schema, "-")
+	checkCli(t, `{"a": 1}`, cli.EXIT_ERROR, "", "validate", "-schema", filepath.Join(dir, "none.json"))
+	checkCli(t, `{"a": [true]}`, cli.EXIT_OK, "Diag\nOBJECT: N:''\n  LIST: N:'a' \n    BOOL: N:'' V:'true'\n", "diag")
+	checkCli(t, `{"a": [true]}`, cli.EXIT_OK, "type Root struct {\n\tA []bool `json:\"a\"`\n}\n", "gostruct")
+	checkCli(t, "", cli.EXIT_USAGE, "", "unknown")
+	checkCli(t, "", cli.EXIT_USAGE, "")
+}
+
+func checkCli(t *testing.T, stdin string, rc int, stdout string, args ...string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	if len(args) == 1 && args[0] == "" {
+		args = []string{}
+	}
+	actual := cli.Run("jp", args, strings.NewReader(stdin), &out, &errOut)
+	if actual != rc {
+		t.Errorf("%v: exit code %d expected %d. stderr: %s", args, actual, rc, errOut.String())
+	}
+	if out.String() != stdout {
+		t.Errorf("%v: stdout\n%q\nexpected\n%q", args, out.String(), stdout)
+	}
+	if rc != cli.EXIT_OK && rc != cli.EXIT_INVALID && errOut.Len() == 0 {
+		t.Errorf("%v: expected a message on stderr", args)
+	}
+}
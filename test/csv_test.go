@@ -0,0 +1,112 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const csvRecords = `[
+	{"id": 1, "name": "Joe, Jr", "address": {"city": "London", "geo": {"lat": 51.5}}, "tags": ["a", "b"]},
+	{"id": 2, "name": "Sue \"S\"", "active": true, "address": {}, "tags": [], "note": null},
+	{"name": " lead", "tags": ["c"], "id": 3}
+]`
+
+func TestCSVValue(t *testing.T) {
+	checkCSVValue(t, csvRecords, parser.CSVOptions{}, `id,name,address.city,address.geo.lat,tags.0,tags.1,active,address,tags,note
+1,"Joe, Jr",London,51.5,a,b,,,,
+2,"Sue ""S""",,,,,true,{},[],
+3," lead",,,c,,,,,
+`)
+	checkCSVValue(t, csvRecords, parser.CSVOptions{Delimiter: '\t', PathDelim: "/", Columns: []string{"name", "address/city", "missing"}}, "name\taddress/city\tmissing\nJoe, Jr\tLondon\t\n\"Sue \"\"S\"\"\"\t\t\n\" lead\"\t\t\n")
+	checkCSVValue(t, `[{"a": 1, "b": "x", "c": null}]`, parser.CSVOptions{Quote: parser.CSV_QUOTE_ALL}, "\"a\",\"b\",\"c\"\n\"1\",\"x\",\"\"\n")
+	checkCSVValue(t, `[{"a": 1, "b": "x", "c": null}]`, parser.CSVOptions{Quote: parser.CSV_QUOTE_STRINGS}, "\"a\",\"b\",\"c\"\n1,\"x\",\n")
+	checkCSVValue(t, `[]`, parser.CSVOptions{}, "\n")
+
+	checkCSVValueError(t, `{"a": 1}`, "csv needs a list of objects not a OBJECT")
+	checkCSVValueError(t, `[{"a": 1}, 2]`, "the value at '/1' is a NUMBER not an object")
+}
+
+func TestParseCSV(t *testing.T) {
+	checkCSV(t, "id,name,address.city,tags.0,tags.1,ok,code\n1,\"Joe, Jr\",London,a,b,true,007\n2.5e3,\"Sue \"\"S\"\"\",,c,,false,-0\n", parser.CSVOptions{},
+		`[{"id": 1, "name": "Joe, Jr", "address": {"city": "London"}, "tags": ["a", "b"], "ok": true, "code": "007"},
+		{"id": 2.5e3, "name": "Sue \"S\"", "tags": ["c", null], "ok": false, "code": -0}]`)
+	checkCSV(t, "a.b\tc\n1\tTrue\n", parser.CSVOptions{Delimiter: '\t', Flat: true}, `[{"a.b": 1, "c": "True"}]`)
+	checkCSV(t, "a/b,c\n1,\n", parser.CSVOptions{PathDelim: "/", NoInference: true}, `[{"a": {"b": "1"}, "c": ""}]`)
+	checkCSV(t, "", parser.CSVOptions{}, `[]`)
+	checkCSV(t, "a,b\n", parser.CSVOptions{}, `[]`)
+
+	checkCSVError(t, "a,b\n1,2,3\n", 2, 1, "wrong number of fields")
+	checkCSVError(t, "a,b\n1,\"2\n", 2, 6, "extraneous or missing \" in quoted-field")
+	checkCSVError(t, "a,a\n1,2\n", 2, 3, "the column 'a' is repeated or is also a nested value")
+	checkCSVError(t, "a,a.b\n1,2\n", 2, 3, "the column 'a.b' is inside a value that is not an object")
+
+	// A round trip keeps the values that csv can hold
+	list := parseAny(t, `[{"a": {"b": [1, "x"]}, "c": true}, {"a": {"b": [2, "y"]}, "c": false}]`)
+	csv, err := parser.CSVValue(list, parser.CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := parser.ParseCSV([]byte(csv), parser.CSVOptions{})
+	if err != nil || !parser.EqualValues(back, list) {
+		t.Errorf("csv\n%s\ndid not parse back to %s. error %v", csv, list.JsonValue(), err)
+	}
+}
+
+func TestCSVRoundTripDifferentColumns(t *testing.T) {
+	// Missing nested values do not create objects. Missing keys and empty strings in a row are null
+	list := parseAny(t, `[{"a": 1, "b": {"c": 2, "d": [3, 4]}}, {"a": 5, "e": "x", "f": ""}, {"a": 6, "b": {"c": 7}}]`)
+	csv, err := parser.CSVValue(list, parser.CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCSV(t, csv, parser.CSVOptions{}, `[{"a": 1, "b": {"c": 2, "d": [3, 4]}, "e": null, "f": null},
+		{"a": 5, "e": "x", "f": null}, {"a": 6, "b": {"c": 7}, "e": null, "f": null}]`)
+	checkCSV(t, "a,b.c,b.d.0,b.d.1\n1,,,x\n2,,,\n", parser.CSVOptions{NoInference: true}, `[{"a": "1", "b": {"c": "", "d": ["", "x"]}}, {"a": "2"}]`)
+}
+
+func checkCSVValue(t *testing.T, json string, options parser.CSVOptions, expected string) {
+	t.Helper()
+	csv, err := parser.CSVValue(parseAny(t, json), options)
+	if err != nil {
+		t.Errorf("failed to write %s: %s", json, err)
+		return
+	}
+	if csv != expected {
+		t.Errorf("csv\n%q\nexpected\n%q", csv, expected)
+	}
+}
+
+func checkCSVValueError(t *testing.T, json, msg string) {
+	t.Helper()
+	_, err := parser.CSVValue(parseAny(t, json), parser.CSVOptions{})
+	if err == nil || err.Error() != msg {
+		t.Errorf("%s error %v expected %q", json, err, msg)
+	}
+}
+
+func checkCSV(t *testing.T, csv string, options parser.CSVOptions, expected string) {
+	t.Helper()
+	node, err := parser.ParseCSV([]byte(csv), options)
+	if err != nil {
+		t.Errorf("failed to parse %q: %s", csv, err)
+		return
+	}
+	if !parser.EqualValues(node, parseAny(t, expected)) {
+		t.Errorf("csv %q\nparsed %s\nexpected %s", csv, node.JsonValue(), parseAny(t, expected).JsonValue())
+	}
+}
+
+func checkCSVError(t *testing.T, csv string, line, column int, msg string) {
+	t.Helper()
+	_, err := parser.ParseCSV([]byte(csv), parser.CSVOptions{})
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("csv %q expected a ParseError. Got %v", csv, err)
+		return
+	}
+	if pe.Line != line || pe.Column != column || pe.Msg != msg {
+		t.Errorf("csv %q error %d:%d %q expected %d:%d %q", csv, pe.Line, pe.Column, pe.Msg, line, column, msg)
+	}
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func TestDiffObjects(t *testing.T) {
+	a := parseAny(t, `{"name": "Joe", "age": 28, "address": {"city": "San Diego", "state": "CA"}, "x": 1.0}`)
+	b := parseAny(t, `{"name": "Joe", "age": 29, "address": {"city": "London", "zip": "N1"}, "x": 1}`)
+	checkDiff(t, parser.Diff(a, b), `changed /age: 28 -> 29
+changed /address/city: "San Diego" -> "London"
+removed /address/state: "CA"
+added /address/zip: "N1"
+`)
+	c := parser.Diff(a, b).Get(parser.NewDotPath("address.city"))
+	if c == nil || c.Type != parser.CHANGE_CHANGED || c.From.String() != "San Diego" || c.To.String() != "London" {
+		t.Errorf("Get should return the change for address.city. Actual %s", c)
+	}
+	if parser.Diff(a, b).Get(parser.NewDotPath("name")) != nil {
+		t.Errorf("Get should return nil for name")
+	}
+	checkDiff(t, parser.Diff(a, parseAny(t, `[1]`)), `changed type : {"name": "Joe","age": 28,"address": {"city": "San Diego","state": "CA"},"x": 1.0} -> [1]`+"\n")
+}
+
+func TestDiffChangedType(t *testing.T) {
+	changes := parser.Diff(parseAny(t, `{"a": 1, "b": 2, "c": [null]}`), parseAny(t, `{"a": "1", "b": 3, "c": [{}]}`))
+	checkDiff(t, changes, `changed type /a: 1 -> "1"
+changed /b: 2 -> 3
+changed type /c/0: null -> {}
+`)
+	if changes[0].Type != parser.CHANGE_TYPE || changes[1].Type != parser.CHANGE_CHANGED {
+		t.Errorf("expected CHANGE_TYPE then CHANGE_CHANGED. Actual %s and %s", changes[0].Type, changes[1].Type)
+	}
+	expected := "--- a\n+++ b\n@@ /a @@ changed type\n-1\n+\"1\"\n@@ /b @@\n-2\n+3\n@@ /c/0 @@ changed type\n-null\n+{}\n"
+	if u := changes.Unified("a", "b"); u != expected {
+		t.Errorf("Unified is incorrect. Expected:\n%s\nActual:\n%s", expected, u)
+	}
+}
+
+func TestDiffKeyOrder(t *testing.T) {
+	a := parseAny(t, `{"a": 1, "b": 2, "c": 3}`)
+	b := parseAny(t, `{"b": 2, "a": 1, "d": 4}`)
+	checkDiff(t, parser.Diff(a, b), `removed /c: 3
+added /d: 4
+order : {"a": 1,"b": 2,"c": 3} -> {"b": 2,"a": 1,"d": 4}
+`)
+	checkDiff(t, parser.DiffWithOptions(a, b, parser.DiffOptions{IgnoreKeyOrder: true}), `removed /c: 3
+added /d: 4
+`)
+}
+
+func TestDiffLists(t *testing.T) {
+	a := parseAny(t, `[1, 2, 3, {"a": 1}, 5]`)
+	b := parseAny(t, `[0, 1, 3, {"a": 2}, 5, 6]`)
+	checkDiff(t, parser.Diff(a, b), `added /0: 0
+removed /1: 2
+changed /3/a: 1 -> 2
+added /5: 6
+`)
+	checkDiff(t, parser.Diff(parseAny(t, `[1, 2, 3]`), parseAny(t, `[3, 2, 1]`)), `removed /0: 1
+removed /1: 2
+added /1: 2
+added /2: 1
+`)
+	checkDiff(t, parser.DiffWithOptions(parseAny(t, `[1, 2, 3]`), parseAny(t, `[3, 2, 1]`), parser.DiffOptions{IgnoreListOrder: true}), "")
+	checkDiff(t, parser.DiffWithOptions(parseAny(t, `[1, 2, 2, 3]`), parseAny(t, `[3, 2, 4, 1]`), parser.DiffOptions{IgnoreListOrder: true}), `removed /2: 2
+added /2: 4
+`)
+}
+
+func TestDiffLongLists(t *testing.T) {
+	a := parser.NewJsonList("")
+	b := parser.NewJsonList("")
+	c := parser.NewJsonList("")
+	b.Add(parser.NewJsonString("", "x"))
+	for i := 0; i < 1500; i++ {
+		a.Add(parser.NewJsonNumber("", float64(i)))
+		if i < 1499 {
+			b.Add(parser.NewJsonNumber("", float64(i)))
+		}
+		if i == 700 {
+			c.Add(parser.NewJsonNumber("", -1))
+		} else {
+			c.Add(parser.NewJsonNumber("", float64(i)))
+		}
+	}
+	b.Add(parser.NewJsonString("", "y"))
+	// The equal values at the start and end are matched without a table
+	checkDiff(t, parser.Diff(a, c), "changed /700: 700 -> -1\n")
+	// The table would be too large so the values are compared by position
+	changes := parser.Diff(a, b)
+	if len(changes) != 1501 || changes[0].String() != `changed type /0: 0 -> "x"` || changes[1500].String() != `added /1500: "y"` {
+		t.Errorf("long lists should be compared by position. Actual %d changes", len(changes))
+	}
+}
+
+func TestDiffIdentityKey(t *testing.T) {
+	a := parseAny(t, `{"items": [{"id": 1, "v": "a"}, {"id": 2, "v": "b"}, {"id": 3, "v": "c"}]}`)
+	b := parseAny(t, `{"items": [{"id": 2, "v": "B"}, {"id": 1, "v": "a"}, {"id": 4, "v": "d"}]}`)
+	checkDiff(t, parser.DiffWithOptions(a, b, parser.DiffOptions{IdentityKey: "id"}), `changed /items/1/v: "b" -> "B"
+removed /items/2: {"id": 3,"v": "c"}
+added /items/2: {"id": 4,"v": "d"}
+order /items: [{"id": 1,"v": "a"},{"id": 2,"v": "b"},{"id": 3,"v": "c"}] -> [{"id": 2,"v": "B"},{"id": 1,"v": "a"},{"id": 4,"v": "d"}]
+`)
+	checkDiff(t, parser.DiffWithOptions(a, b, parser.DiffOptions{IdentityKey: "id", IgnoreListOrder: true}), `changed /items/1/v: "b" -> "B"
+removed /items/2: {"id": 3,"v": "c"}
+added /items/2: {"id": 4,"v": "d"}
+`)
+}
+
+func TestDiffUnified(t *testing.T) {
+	a := parseAny(t, `{"name": "Joe", "tags": ["x"], "old": true}`)
+	b := parseAny(t, `{"name": "Fred", "tags": ["x", "y"]}`)
+	u := parser.Diff(a, b).Unified("a.json", "b.json")
+	expected := `--- a.json
++++ b.json
+@@ /name @@
+-"Joe"
++"Fred"
+@@ /tags/1 @@ added
++"y"
+@@ /old @@ removed
+-true
+`
+	if u != expected {
+		t.Errorf("Unified diff is incorrect. Expected:\n%s\nActual:\n%s", expected, u)
+	}
+	if parser.Diff(a, a).Unified("a", "b") != "" {
+		t.Errorf("No changes should render an empty string")
+	}
+}
+
+func checkDiff(t *testing.T, changes parser.Changes, expected string) {
+	if changes.String() != expected {
+		t.Errorf("Diff is incorrect. Expected:\n%s\nActual:\n%s", expected, changes.String())
+	}
+}
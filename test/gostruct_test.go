@@ -0,0 +1,92 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func TestGoStructs(t *testing.T) {
+	sample := parseAny(t, `{
+		"id": 1, "first_name": "Joe", "score": 2, "rating": null,
+		"address": {"city": "London", "geo": {"lat": 51.5}},
+		"phoneNumbers": [{"type": "home", "number": "123"}, {"type": "work", "ext": null}],
+		"tags": ["a"], "mixed": [1, "a"], "empty": [], "nothing": null, "2fa": true
+	}`)
+	sample2 := parseAny(t, `{"id": 2, "first_name": "Sue", "score": 2.5, "rating": 4, "address": null, "phoneNumbers": [], "tags": [], "mixed": [], "empty": [], "nothing": null, "2fa": false, "extra": {"a": 1}}`)
+	src, err := parser.GoStructs(parser.GoStructOptions{PackageName: "models", RootName: "person"}, sample, sample2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoStructs(t, src, `package models
+
+type Person struct {
+	ID           int64         `+"`json:\"id\"`"+`
+	FirstName    string        `+"`json:\"first_name\"`"+`
+	Score        float64       `+"`json:\"score\"`"+`
+	Rating       *int64        `+"`json:\"rating\"`"+`
+	Address      *Address      `+"`json:\"address\"`"+`
+	PhoneNumbers []PhoneNumber `+"`json:\"phoneNumbers\"`"+`
+	Tags         []string      `+"`json:\"tags\"`"+`
+	Mixed        []interface{} `+"`json:\"mixed\"`"+`
+	Empty        []interface{} `+"`json:\"empty\"`"+`
+	Nothing      interface{}   `+"`json:\"nothing\"`"+`
+	X2fa         bool          `+"`json:\"2fa\"`"+`
+	Extra        *Extra        `+"`json:\"extra,omitempty\"`"+`
+}
+
+type Address struct {
+	City string `+"`json:\"city\"`"+`
+	Geo  Geo    `+"`json:\"geo\"`"+`
+}
+
+type Geo struct {
+	Lat float64 `+"`json:\"lat\"`"+`
+}
+
+type PhoneNumber struct {
+	Type   string      `+"`json:\"type\"`"+`
+	Number string      `+"`json:\"number,omitempty\"`"+`
+	Ext    interface{} `+"`json:\"ext,omitempty\"`"+`
+}
+
+type Extra struct {
+	A int64 `+"`json:\"a\"`"+`
+}
+`)
+}
+
+func TestGoStructsRootList(t *testing.T) {
+	src, err := parser.GoStructs(parser.GoStructOptions{}, parseAny(t, `[{"name": "a", "root": {"name": "x"}}, {"name": "b"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoStructs(t, src, `type Root []RootItem
+
+type RootItem struct {
+	Name string    `+"`json:\"name\"`"+`
+	Root *RootItemRoot `+"`json:\"root,omitempty\"`"+`
+}
+
+type RootItemRoot struct {
+	Name string `+"`json:\"name\"`"+`
+}
+`)
+	src, err = parser.GoStructs(parser.GoStructOptions{RootName: "count"}, parseAny(t, `10`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoStructs(t, src, "type Count int64\n")
+}
+
+func checkGoStructs(t *testing.T, src, expected string) {
+	t.Helper()
+	// Compare without alignment spaces
+	norm := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	if norm(src) != norm(expected) {
+		t.Errorf("generated\n%s\nexpected\n%s", src, expected)
+	}
+}
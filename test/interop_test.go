@@ -0,0 +1,119 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+type iDoc struct {
+	Title  string             `json:"title"`
+	Body   *parser.JsonObject `json:"body"`
+	Items  *parser.JsonList   `json:"items"`
+	Count  *parser.JsonNumber `json:"count"`
+	Any    parser.NodeI       `json:"any"`
+	Absent *parser.JsonObject `json:"absent"`
+}
+
+func TestFromInterface(t *testing.T) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(`{"b": [1, 2.5, "x", true, null], "a": {"c": {}}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	node, err := parser.FromInterface(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMarshal(t, node, `{"a": {"c": {}}, "b": [1, 2.5, "x", true, null]}`)
+
+	node, err = parser.FromInterface(map[string]interface{}{
+		"n":   json.Number("12345678901234567890"),
+		"raw": json.RawMessage(`{"z": [1]}`),
+		"s":   struct{ X int }{X: 1},
+		"i":   7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMarshal(t, node, `{"i": 7, "n": 12345678901234567890, "raw": {"z": [1]}, "s": {"X": 1}}`)
+
+	if _, err = parser.FromInterface(json.RawMessage(`{"z": `)); err == nil {
+		t.Errorf("expected a parse error for bad raw json")
+	}
+}
+
+func TestToInterface(t *testing.T) {
+	node := parseAny(t, `{"a": [1, "x", false, null], "b": {"c": 1.5}}`)
+	v := parser.ToInterface(node)
+	if fmt.Sprint(v) != "map[a:[1 x false <nil>] b:map[c:1.5]]" {
+		t.Errorf("unexpected value %v", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) != `{"a":[1,"x",false,null],"b":{"c":1.5}}` {
+		t.Errorf("unexpected json %s %v", string(b), err)
+	}
+}
+
+func TestNodesWithEncodingJson(t *testing.T) {
+	js := `{"title":"T","body":{"x":[1,{"y":null}]},"items":["a",2],"count":12345678901234567890,"any":{"k":"v"},"absent":null}`
+	var d iDoc
+	if err := json.Unmarshal([]byte(js), &d); err == nil {
+		t.Errorf("expected an error as a NodeI interface cannot be created by encoding/json")
+	}
+	d = iDoc{Any: parser.NewJsonObject("")}
+	if err := json.Unmarshal([]byte(js), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Body.JsonValue() != `{"x": [1,{"y": null}]}` || d.Items.Len() != 2 || d.Count.GetLiteral() != "12345678901234567890" || d.Absent != nil {
+		t.Errorf("unexpected result %+v", d)
+	}
+	if d.Body.GetNodeWithName("x").GetParent() != d.Body {
+		t.Errorf("parent of x should be the body")
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != js {
+		t.Errorf("json.Marshal\n%s\nexpected\n%s", string(b), js)
+	}
+
+	// Named nodes are written without the name
+	obj := parseAny(t, `{"a\"\u00e9": {"b\\": "x"}}`).(*parser.JsonObject)
+	a := obj.GetNodeWithName("a\"\u00e9")
+	b, err = json.Marshal(map[string]parser.NodeI{"v": a, "s": a.(*parser.JsonObject).GetNodeWithName("b\\")})
+	if err != nil || string(b) != `{"s":"x","v":{"b\\":"x"}}` {
+		t.Errorf("unexpected json %s %v", string(b), err)
+	}
+
+	var l parser.JsonList
+	if err := json.Unmarshal([]byte(`{"a": 1}`), &l); err == nil || err.Error() != "cannot unmarshal OBJECT into a LIST node" {
+		t.Errorf("expected a type error. Actual %v", err)
+	}
+	var s parser.JsonString
+	if err := json.Unmarshal([]byte(`"a\nb"`), &s); err != nil || s.GetValue() != "a\nb" || s.GetNodeType() != parser.NT_STRING {
+		t.Errorf("unexpected string %v %v", s.GetValue(), err)
+	}
+}
+
+func TestUnmarshalEncodingJsonTypes(t *testing.T) {
+	var v struct {
+		N   json.Number        `json:"n"`
+		Raw json.RawMessage    `json:"raw"`
+		Obj *parser.JsonObject `json:"obj"`
+	}
+	if err := parser.Unmarshal(parseAny(t, `{"n": 1.50, "raw": {"a": [1, 2]}, "obj": {"b": true}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.N != "1.50" || string(v.Raw) != `{"a": [1,2]}` || v.Obj.JsonValue() != `"obj": {"b": true}` {
+		t.Errorf("unexpected result %s %s %s", v.N, string(v.Raw), v.Obj.JsonValue())
+	}
+	checkUnmarshalErr(t, `{"obj": [1]}`, &v, "cannot unmarshal LIST into *parser.JsonObject at /obj")
+	node, err := parser.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMarshal(t, node, `{"n": 1.50, "raw": {"a": [1, 2]}, "obj": {"b": true}}`)
+}
@@ -0,0 +1,186 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const storeJson = `{ "store": {
+    "book": [
+      { "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+      { "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+      { "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+      { "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+    ],
+    "bicycle": { "color": "red", "price": 399 }
+  }
+}`
+
+func TestJsonPathStore(t *testing.T) {
+	root := InitParser(t, "", []byte(storeJson))
+	checkJsonPath(t, root, "$.store.book[*].author", "Nigel Rees|Evelyn Waugh|Herman Melville|J. R. R. Tolkien")
+	checkJsonPath(t, root, "$..author", "Nigel Rees|Evelyn Waugh|Herman Melville|J. R. R. Tolkien")
+	checkJsonPath(t, root, "$.store.*.color", "red")
+	checkJsonPath(t, root, "$.store..price", "8.95|12.99|8.99|22.99|399")
+	checkJsonPath(t, root, "$..book[2].title", "Moby Dick")
+	checkJsonPath(t, root, "$..book[-1].title", "The Lord of the Rings")
+	checkJsonPath(t, root, "$..book[0,1].title", "Sayings of the Century|Sword of Honour")
+	checkJsonPath(t, root, "$..book[:2].title", "Sayings of the Century|Sword of Honour")
+	checkJsonPath(t, root, "$..book[?@.isbn].title", "Moby Dick|The Lord of the Rings")
+	checkJsonPath(t, root, "$..book[?@.price<10].title", "Sayings of the Century|Moby Dick")
+	checkJsonPath(t, root, "$..book[?@.price < $.store.bicycle.price && @.category == 'fiction'].price", "12.99|8.99|22.99")
+	checkJsonPath(t, root, "$..book[?!(@.category == 'fiction')].author", "Nigel Rees")
+	checkJsonPath(t, root, "$..book[?@.author == 'Nigel Rees' || @.price > 20].price", "8.95|22.99")
+	checkJsonPath(t, root, "$.store['bicycle']['color', 'price']", "red|399")
+	checkJsonPath(t, root, "$.store.bicycle.size", "")
+	nodes, _, _ := parser.JsonPathQuery(root, "$..*")
+	if len(nodes) != 27 {
+		t.Errorf("$..* should return 27 nodes. Actual %d", len(nodes))
+	}
+}
+
+func TestJsonPathPaths(t *testing.T) {
+	root := InitParser(t, "", []byte(storeJson))
+	nodes, paths, err := parser.JsonPathQuery(root, "$..book[?@.isbn].isbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || paths[0].String() != "store.book.2.isbn" || paths[1].Pointer() != "/store/book/3/isbn" {
+		t.Errorf("Paths are incorrect. Actual %s", paths)
+	}
+	for i, p := range paths {
+		n, err := parser.Find(root, p)
+		if err != nil || n != nodes[i] {
+			t.Errorf("Path %s should find the selected node", p)
+		}
+	}
+	_, paths, _ = parser.JsonPathQuery(root, "$")
+	if len(paths) != 1 || !paths[0].IsEmpty() {
+		t.Errorf("Path to $ should be empty. Actual %s", paths)
+	}
+	root = InitParser(t, "", []byte(`{"a.b": {"c/d": [1, 2]}}`))
+	_, paths, _ = parser.JsonPathQuery(root, "$['a.b']['c/d'][1]")
+	if len(paths) != 1 || paths[0].Pointer() != "/a.b/c~1d/1" {
+		t.Errorf("Pointer is incorrect. Actual %s", paths)
+	}
+}
+
+func TestJsonPathSlices(t *testing.T) {
+	root := InitParser(t, "", []byte(`{"a": ["a", "b", "c", "d", "e", "f", "g"]}`))
+	checkJsonPath(t, root, "$.a[1:3]", "b|c")
+	checkJsonPath(t, root, "$.a[5:]", "f|g")
+	checkJsonPath(t, root, "$.a[1:5:2]", "b|d")
+	checkJsonPath(t, root, "$.a[5:1:-2]", "f|d")
+	checkJsonPath(t, root, "$.a[::-1]", "g|f|e|d|c|b|a")
+	checkJsonPath(t, root, "$.a[-2:]", "f|g")
+	checkJsonPath(t, root, "$.a[::0]", "")
+	checkJsonPath(t, root, "$.a[-10:100]", "a|b|c|d|e|f|g")
+	checkJsonPath(t, root, "$.a[7]", "")
+	checkJsonPath(t, root, "$.a[0, 0]", "a|a")
+}
+
+func TestJsonPathFilters(t *testing.T) {
+	root := InitParser(t, "", []byte(`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}, [1], "1", null, true]}`))
+	checkJsonPath(t, root, "$.a[?@.b == 'kilo']", `{"b": "kilo"}`)
+	checkJsonPath(t, root, "$.a[?@ > 3.5]", "5|4|6")
+	checkJsonPath(t, root, "$.a[?@ == 1]", "1")
+	checkJsonPath(t, root, "$.a[?@ == 1.0e0]", "1")
+	checkJsonPath(t, root, "$.a[?@ == '1']", "1")
+	checkJsonPath(t, root, "$.a[?@ == null]", "null")
+	checkJsonPath(t, root, "$.a[?@ == true]", "true")
+	checkJsonPath(t, root, "$.a[?@.b]", `{"b": "j"}|{"b": "k"}|{"b": {}}|{"b": "kilo"}`)
+	checkJsonPath(t, root, "$.a[?@.b == $.x]", "3|5|1|2|4|6|[1]|1|null|true")
+	checkJsonPath(t, root, "$.a[?@ == $.a[10]]", "[1]")
+	checkJsonPath(t, root, "$.a[?@.b < 'k']", `{"b": "j"}`)
+	checkJsonPath(t, root, "$.a[?@.b <= 'k']", `{"b": "j"}|{"b": "k"}`)
+	checkJsonPath(t, root, "$.a[?match(@.b, 'k.*')]", `{"b": "k"}|{"b": "kilo"}`)
+	checkJsonPath(t, root, "$.a[?search(@.b, 'il')]", `{"b": "kilo"}`)
+	checkJsonPath(t, root, "$.a[?length(@.b) == 4]", `{"b": "kilo"}`)
+	checkJsonPath(t, root, "$.a[?length(@) == 1]", `{"b": "j"}|{"b": "k"}|{"b": {}}|{"b": "kilo"}|[1]|1`)
+	checkJsonPath(t, root, "$.a[?count(@.*) == 1 && value(@.*) == 'j']", `{"b": "j"}`)
+	checkJsonPath(t, root, "$.a[?!@.b][0]", "1")
+	checkJsonPath(t, root, "$[?@[0] == 3]", "\"a\": [3,5,1,2,4,6,{\"b\": \"j\"},{\"b\": \"k\"},{\"b\": {}},{\"b\": \"kilo\"},[1],\"1\",null,true]")
+	root = InitParser(t, "", []byte(`{"a": ["a\nb", "axb", "a\rb", "a.b"]}`))
+	checkJsonPath(t, root, "$.a[?match(@, 'a.b')]", "axb|a.b")
+	checkJsonPath(t, root, "$.a[?search(@, 'x')]", "axb")
+	checkJsonPath(t, root, "$.a[?match(@, 'a\\\\.b')]", "a.b")
+	checkJsonPath(t, root, `$.a[?match(@, "a[.]b")]`, "a.b")
+}
+
+func TestJsonPathNames(t *testing.T) {
+	root := InitParser(t, "", []byte(`{"o": {"j j": {"k.k": 3}, "'": 1, "\"": 2, "é": 4, "😀": 5, "_x1": 6}}`))
+	checkJsonPath(t, root, "$.o['j j']['k.k']", "3")
+	checkJsonPath(t, root, `$.o["j j"]["k.k"]`, "3")
+	checkJsonPath(t, root, `$.o['\'']`, "1")
+	checkJsonPath(t, root, `$.o["\""]`, "2")
+	checkJsonPath(t, root, `$.o['é']`, "4")
+	checkJsonPath(t, root, "$.o.é", "4")
+	checkJsonPath(t, root, `$.o['😀']`, "5")
+	checkJsonPath(t, root, "$.o._x1", "6")
+	checkJsonPath(t, root, "$.o[ 'j j' ] ['k.k']", "3")
+	checkJsonPath(t, root, "$..['k.k', '_x1']", "6|3")
+}
+
+func TestJsonPathErrors(t *testing.T) {
+	for query, msg := range map[string]string{
+		"":                         "must start with '$'",
+		"store":                    "must start with '$'",
+		"$.":                       "expected a name or '*'",
+		"$.1a":                     "expected a name or '*'",
+		"$[":                       "expected a selector",
+		"$[1":                      "expected ',' or ']'",
+		"$[01]":                    "invalid integer '01'",
+		"$[-0]":                    "invalid integer '-0'",
+		"$[9007199254740992]":      "out of range",
+		"$['a]":                    "unterminated string",
+		`$['\a']`:                  "invalid escape",
+		`$['\uD800']`:              "surrogate",
+		"$ ":                       "unexpected ' '",
+		"$[?@.a == 1 1]":           "expected ',' or ']'",
+		"$[?@.a ==]":               "expected a query, literal or function",
+		"$[?@.* == 1]":             "only a singular query",
+		"$[?@..a == 1]":            "only a singular query",
+		"$[?1]":                    "a literal must be compared",
+		"$[?length(@.a)]":          "the result of length() must be compared",
+		"$[?match(@.a, 'a') == 1]": "the result of match() cannot be compared",
+		"$[?count(1) == 1]":        "argument 1 of count() must be a query",
+		"$[?length(@.a, 1) == 1]":  "length() requires 1 arguments",
+		"$[?match(@.a) == 1]":      "match() requires 2 arguments",
+		"$[?foo(@.a)]":             "unknown function 'foo'",
+		"$[?!@.a == 1]":            "'!' cannot be applied to a comparison",
+		"$[?@ == [1]]":             "expected a query, literal or function",
+		"$[?(@.a == 1]":            "expected ')'",
+	} {
+		_, err := parser.NewJsonPath(query)
+		if err == nil {
+			t.Errorf("Query '%s' should fail with '%s'", query, msg)
+			continue
+		}
+		CheckErr(t, err, msg)
+	}
+}
+
+func checkJsonPath(t *testing.T, root parser.NodeI, query, expected string) {
+	jp, err := parser.NewJsonPath(query)
+	if err != nil {
+		t.Errorf("Query '%s' failed: %s", query, err)
+		return
+	}
+	nodes, paths := jp.Query(root)
+	if len(nodes) != len(paths) {
+		t.Errorf("Query '%s' returned %d nodes and %d paths", query, len(nodes), len(paths))
+	}
+	values := make([]string, len(nodes))
+	for i, n := range nodes {
+		if n.IsContainer() {
+			values[i] = n.JsonValue()
+		} else {
+			values[i] = n.String()
+		}
+	}
+	if strings.Join(values, "|") != expected {
+		t.Errorf("Query '%s' should return '%s'. Actual '%s'", query, expected, strings.Join(values, "|"))
+	}
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+type mAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type mBase struct {
+	ID      int64 `json:"id"`
+	Version int   `json:"version"`
+}
+
+type mColour int
+
+func (c mColour) MarshalNode(name string) (parser.NodeI, error) {
+	return parser.NewJsonString(name, []string{"red", "green", "blue"}[c]), nil
+}
+
+func (c *mColour) UnmarshalNode(node parser.NodeI) error {
+	s, ok := node.(*parser.JsonString)
+	if !ok {
+		return fmt.Errorf("colour must be a string")
+	}
+	for i, v := range []string{"red", "green", "blue"} {
+		if v == s.GetValue() {
+			*c = mColour(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown colour %s", s.GetValue())
+}
+
+type mPerson struct {
+	mBase
+	Name     string             `json:"name"`
+	Age      int                `json:"age,string"`
+	Email    *string            `json:"email"`
+	Address  *mAddress          `json:"address,omitempty"`
+	Tags     []string           `json:"tags"`
+	Scores   map[string]float64 `json:"scores,omitempty"`
+	Born     time.Time          `json:"born"`
+	Colour   mColour            `json:"colour"`
+	Data     []byte             `json:"data,omitempty"`
+	Extra    parser.NodeI       `json:"extra,omitempty"`
+	Any      interface{}        `json:"any,omitempty"`
+	Password string             `json:"-"`
+	Version  int                `json:"version"`
+	private  int
+}
+
+func TestMarshalStruct(t *testing.T) {
+	email := "joe@x.com"
+	extra, _ := parser.Parse([]byte(`{"a": [1, 2.5]}`))
+	p := &mPerson{mBase: mBase{ID: 12345678901234567}, Name: "Joe", Age: 28, Email: &email, Address: &mAddress{City: "London"},
+		Tags: []string{"a", "b"}, Scores: map[string]float64{"z": 1.5, "m": 2}, Born: time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+		Colour: 2, Data: []byte("hi"), Extra: extra, Password: "secret", Version: 3, private: 1}
+	node, err := parser.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":"Joe","age":"28","email":"joe@x.com","address":{"city":"London"},"tags":["a","b"],"scores":{"m":2,"z":1.5},` +
+		`"born":"2000-01-02T03:04:05Z","colour":"blue","data":"aGk=","extra":{"a":[1,2.5]},"version":3,"id":12345678901234567}`
+	checkMarshal(t, node, expected)
+
+	node, err = parser.Marshal(mPerson{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMarshal(t, node, `{"name":"","age":"0","email":null,"tags":null,"born":"0001-01-01T00:00:00Z","colour":"red","version":0,"id":0}`)
+
+	for _, v := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, `null`}, {true, `true`}, {uint8(7), `7`}, {-1.5, `-1.5`}, {float32(0.1), `0.1`}, {float32(1e-7), `1e-7`}, {"x", `"x"`}, {[2]int{1, 2}, `[1,2]`},
+		{map[int]bool{2: true, 1: false}, `{"1":false,"2":true}`}, {[]interface{}{1, "a", nil}, `[1,"a",null]`},
+	} {
+		node, err := parser.Marshal(v.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkMarshal(t, node, v.expected)
+	}
+
+	_, err = parser.Marshal(make(chan int))
+	if err == nil || err.Error() != "cannot marshal type chan int" {
+		t.Errorf("expected a marshal error. Actual: %v", err)
+	}
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	json := `{"id": 12345678901234567, "NAME": "Joe", "age": "28", "email": "joe@x.com", "address": {"city": "London", "zip": "N1"},
+	  "tags": ["a", "b"], "scores": {"z": 1.5}, "born": "2000-01-02T03:04:05Z", "colour": "green", "data": "aGk=",
+	  "extra": {"a": [1]}, "any": {"l": [1, "x", true, null]}, "Password": "secret", "version": 3, "unknown": 1}`
+	var p mPerson
+	if err := parser.Unmarshal(parseAny(t, json), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != 12345678901234567 || p.Name != "Joe" || p.Age != 28 || *p.Email != "joe@x.com" || p.Address.Zip != "N1" ||
+		len(p.Tags) != 2 || p.Scores["z"] != 1.5 || !p.Born.Equal(time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		p.Colour != 1 || string(p.Data) != "hi" || p.Extra.JsonValue() != `"extra": {"a": [1]}` || p.Password != "" || p.Version != 3 || p.mBase.Version != 0 {
+		t.Errorf("unexpected unmarshal result %+v", p)
+	}
+	if fmt.Sprint(p.Any) != "map[l:[1 x true <nil>]]" {
+		t.Errorf("unexpected interface{} value %v", p.Any)
+	}
+	// null sets pointers and slices to nil
+	if err := parser.Unmarshal(parseAny(t, `{"email": null, "tags": null, "name": null}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Email != nil || p.Tags != nil || p.Name != "Joe" {
+		t.Errorf("null was not applied %+v", p)
+	}
+	var m map[string][]int
+	if err := parser.Unmarshal(parseAny(t, `{"a": [1, 2], "b": []}`), &m); err != nil || len(m["a"]) != 2 || len(m["b"]) != 0 {
+		t.Errorf("unexpected map %v %v", m, err)
+	}
+	var a [3]uint8
+	if err := parser.Unmarshal(parseAny(t, `[1, 2]`), &a); err != nil || a != [3]uint8{1, 2, 0} {
+		t.Errorf("unexpected array %v %v", a, err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var p mPerson
+	checkUnmarshalErr(t, `{"name": 1}`, &p, "cannot unmarshal NUMBER into string at /name")
+	checkUnmarshalErr(t, `{"age": 28}`, &p, "cannot unmarshal NUMBER into int at /age")
+	checkUnmarshalErr(t, `{"tags": ["a", 1]}`, &p, "cannot unmarshal NUMBER into string at /tags/1")
+	checkUnmarshalErr(t, `{"colour": "pink"}`, &p, "unknown colour pink")
+	checkUnmarshalErr(t, `{"born": "today"}`, &p, `cannot unmarshal "today" into time.Time at /born`)
+	var i8 int8
+	checkUnmarshalErr(t, `300`, &i8, "cannot unmarshal number 300 into int8")
+	checkUnmarshalErr(t, `1.5`, &i8, "cannot unmarshal number 1.5 into int8")
+	checkUnmarshalErr(t, `[]`, &i8, "cannot unmarshal LIST into int8")
+	checkUnmarshalErr(t, `1`, p, "unmarshal requires a non nil pointer. Actual: test.mPerson")
+}
+
+type mQuoted struct {
+	Code  string  `json:"code,string"`
+	Ratio float64 `json:"ratio,string"`
+	Small float32 `json:"small"`
+	Quick float32 `json:"quick,string"`
+}
+
+func TestMarshalQuotedAndNonFinite(t *testing.T) {
+	node, err := parser.Marshal(mQuoted{Code: "a\x01\"/é", Ratio: 0.5, Small: 0.1, Quick: 3.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMarshal(t, node, `{"code":"\"a\\u0001\\\"/é\"","ratio":"0.5","small":0.1,"quick":"3.3"}`)
+	var q mQuoted
+	if err := parser.Unmarshal(node, &q); err != nil || q.Code != "a\x01\"/é" || q.Ratio != 0.5 || q.Small != 0.1 || q.Quick != 3.3 {
+		t.Errorf("unexpected round trip %+v %v", q, err)
+	}
+	if err := parser.Unmarshal(parseAny(t, `{"code": "\"a\\/b\\u0041\""}`), &q); err != nil || q.Code != "a/bA" {
+		t.Errorf("json escapes were not decoded %+v %v", q, err)
+	}
+	checkUnmarshalErr(t, `{"code": "'a'"}`, &q, `cannot unmarshal "'a'" into string at /code. The value is not quoted`)
+	for _, v := range []interface{}{math.NaN(), math.Inf(1), mQuoted{Ratio: math.Inf(-1)}, []float32{float32(math.NaN())}} {
+		_, err = parser.Marshal(v)
+		if err == nil || !strings.HasPrefix(err.Error(), "cannot marshal float ") {
+			t.Errorf("expected a float marshal error for %v. Actual: %v", v, err)
+		}
+	}
+}
+
+func checkMarshal(t *testing.T, node parser.NodeI, expected string) {
+	t.Helper()
+	if exp := parseAny(t, expected).JsonValue(); node.JsonValue() != exp {
+		t.Errorf("marshal\n%s\nexpected\n%s", node.JsonValue(), exp)
+	}
+}
+
+func checkUnmarshalErr(t *testing.T, json string, v interface{}, msg string) {
+	t.Helper()
+	err := parser.Unmarshal(parseAny(t, json), v)
+	if err == nil {
+		t.Errorf("expected error '%s'", msg)
+		return
+	}
+	if !strings.HasPrefix(err.Error(), msg) {
+		t.Errorf("expected error '%s'. Actual '%s'", msg, err.Error())
+	}
+}
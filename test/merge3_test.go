@@ -0,0 +1,94 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const merge3Base = `{"name": "Joe", "age": 28, "city": "San Diego", "tags": ["a", "b"], "old": 1, "gone": 2}`
+
+func TestThreeWayMergeClean(t *testing.T) {
+	base := parseAny(t, merge3Base)
+	ours := parseAny(t, `{"name": "Joe", "age": 29, "city": "San Diego", "tags": ["a", "B"], "old": 1, "new": true}`)
+	theirs := parseAny(t, `{"name": "Joseph", "age": 28, "city": "San Diego", "tags": ["A", "b"], "gone": 2, "other": null}`)
+	result, conflicts := parser.ThreeWayMerge(base, ours, theirs, parser.ThreeWayOptions{})
+	if len(conflicts) != 0 {
+		t.Errorf("There should be no conflicts. Actual %s", conflicts)
+	}
+	expected := `{"name": "Joseph","age": 29,"city": "San Diego","tags": ["A","B"],"new": true,"other": null}`
+	if result.JsonValue() != expected {
+		t.Errorf("Merge should be %s. Actual %s", expected, result.JsonValue())
+	}
+	if base.JsonValue() != parseAny(t, merge3Base).JsonValue() {
+		t.Errorf("Merge should not change base")
+	}
+	checkParents(t, result)
+}
+
+func TestThreeWayMergeConflicts(t *testing.T) {
+	base := parseAny(t, merge3Base)
+	ours := parseAny(t, `{"name": "Fred", "age": 28, "city": "London", "tags": ["a", "b", "c"], "old": 1, "gone": 3}`)
+	theirs := parseAny(t, `{"name": "Bill", "age": 28, "tags": ["a"], "old": 1, "new": 1}`)
+	result, conflicts := parser.ThreeWayMerge(base, ours, theirs, parser.ThreeWayOptions{})
+	expected := "[conflict /name: base \"Joe\" ours \"Fred\" theirs \"Bill\" conflict /city: base \"San Diego\" ours \"London\" theirs (none) conflict /tags: base [\"a\",\"b\"] ours [\"a\",\"b\",\"c\"] theirs [\"a\"] conflict /gone: base 2 ours 3 theirs (none)]"
+	if fmt.Sprint(conflicts) != expected {
+		t.Errorf("Conflicts are incorrect.\nExpected %s\nActual   %s", expected, conflicts)
+	}
+	if result.JsonValue() != `{"name": "Fred","age": 28,"city": "London","tags": ["a","b","c"],"old": 1,"gone": 3,"new": 1}` {
+		t.Errorf("Merge favouring ours is incorrect. Actual %s", result.JsonValue())
+	}
+	result, _ = parser.ThreeWayMerge(base, ours, theirs, parser.ThreeWayOptions{Favour: parser.MERGE_THEIRS})
+	if result.JsonValue() != `{"name": "Bill","age": 28,"tags": ["a"],"old": 1,"new": 1}` {
+		t.Errorf("Merge favouring theirs is incorrect. Actual %s", result.JsonValue())
+	}
+	result, _ = parser.ThreeWayMerge(base, ours, theirs, parser.ThreeWayOptions{ConflictMarkers: true})
+	n, err := parser.FindPointer(result, "/name/$conflict")
+	if err != nil || n.JsonValue() != `"$conflict": {"base": "Joe","ours": "Fred","theirs": "Bill"}` {
+		t.Errorf("Name conflict marker is incorrect. Actual %v %s", n, err)
+	}
+	n, err = parser.FindPointer(result, "/city/$conflict")
+	if err != nil || n.JsonValue() != `"$conflict": {"base": "San Diego","ours": "London"}` {
+		t.Errorf("City conflict marker is incorrect. Actual %v %s", n, err)
+	}
+}
+
+func TestThreeWayMergeIdentityKey(t *testing.T) {
+	base := parseAny(t, `{"items": [{"id": 1, "v": "a"}, {"id": 2, "v": "b"}, {"id": 3, "v": "c"}]}`)
+	ours := parseAny(t, `{"items": [{"id": 2, "v": "B"}, {"id": 1, "v": "a"}, {"id": 3, "v": "c"}, {"id": 4, "v": "d"}]}`)
+	theirs := parseAny(t, `{"items": [{"id": 1, "v": "a", "w": true}, {"id": 2, "v": "b"}, {"id": 5, "v": "e"}]}`)
+	result, conflicts := parser.ThreeWayMerge(base, ours, theirs, parser.ThreeWayOptions{IdentityKey: "id"})
+	if len(conflicts) != 0 {
+		t.Errorf("There should be no conflicts. Actual %s", conflicts)
+	}
+	expected := `{"items": [{"id": 2,"v": "B"},{"id": 1,"v": "a","w": true},{"id": 4,"v": "d"},{"id": 5,"v": "e"}]}`
+	if result.JsonValue() != expected {
+		t.Errorf("Merge should be %s. Actual %s", expected, result.JsonValue())
+	}
+	theirs = parseAny(t, `{"items": [{"id": 1, "v": "a"}]}`)
+	_, conflicts = parser.ThreeWayMerge(base, ours, theirs, parser.ThreeWayOptions{})
+	if len(conflicts) != 1 || conflicts[0].Path.String() != "items" {
+		t.Errorf("Lists made different lengths by both sides without an IdentityKey should conflict. Actual %s", conflicts)
+	}
+}
+
+func TestThreeWayMergeListLength(t *testing.T) {
+	base := parseAny(t, `{"l": [1, 2, 3]}`)
+	for _, v := range []struct {
+		ours, theirs, expected, conflicts string
+	}{
+		{`{"l": [1, 20, 3, 4]}`, `{"l": [1, 2, 30]}`, `{"l": [1,20,30,4]}`, "[]"},
+		{`{"l": [1, 2]}`, `{"l": [10, 2, 3]}`, `{"l": [10,2]}`, "[]"},
+		{`{"l": [1, 2]}`, `{"l": [1, 2, 30]}`, `{"l": [1,2,30]}`, `[conflict /l/2: base 3 ours (none) theirs 30]`},
+		{`{"l": [1, 2, 3, 4]}`, `{"l": [1, 2, 3, 5]}`, `{"l": [1,2,3,5]}`, `[conflict /l/3: base (none) ours 4 theirs 5]`},
+	} {
+		result, conflicts := parser.ThreeWayMerge(base, parseAny(t, v.ours), parseAny(t, v.theirs), parser.ThreeWayOptions{Favour: parser.MERGE_THEIRS})
+		if fmt.Sprint(conflicts) != v.conflicts {
+			t.Errorf("Conflicts for %s and %s are incorrect.\nExpected %s\nActual   %s", v.ours, v.theirs, v.conflicts, conflicts)
+		}
+		if result.JsonValue() != v.expected {
+			t.Errorf("Merge of %s and %s should be %s. Actual %s", v.ours, v.theirs, v.expected, result.JsonValue())
+		}
+	}
+}
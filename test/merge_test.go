@@ -0,0 +1,122 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func TestMergePatchRFCExamples(t *testing.T) {
+	for _, v := range [][]string{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a": "c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a": "b","b": "c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b": "c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a": "c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a": ["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a": {"b": "d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a": [1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e": null,"a": 1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a": "b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a": {"bb": {}}}`},
+		{`{"x":1,"y":2,"z":3}`, `{"y":{"n":1}}`, `{"x": 1,"y": {"n": 1},"z": 3}`},
+	} {
+		target := parseAny(t, v[0])
+		patch := parseAny(t, v[1])
+		result := parser.MergePatch(target, patch)
+		if result.JsonValue() != v[2] {
+			t.Errorf("MergePatch(%s, %s) should be %s. Actual %s", v[0], v[1], v[2], result.JsonValue())
+		}
+		checkParents(t, result)
+	}
+}
+
+func TestMergePatchReplaceInPlace(t *testing.T) {
+	target := parseAny(t, `{"a": 1, "b": [1], "c": 3}`)
+	old := target.(*parser.JsonObject).GetNodeWithName("b")
+	result := parser.MergePatch(target, parseAny(t, `{"b": {"x": 1}, "d": 4}`))
+	if result.JsonValue() != `{"a": 1,"b": {"x": 1},"c": 3,"d": 4}` {
+		t.Errorf("b should be replaced in the same position. Actual %s", result.JsonValue())
+	}
+	if old.GetParent() != nil {
+		t.Errorf("the replaced node should not have a parent")
+	}
+	checkParents(t, result)
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	for _, v := range [][]string{
+		{`{"a": 1, "b": {"c": 2, "d": 3}, "e": [1]}`, `{"a": 1, "b": {"c": 5}, "e": [1, 2], "f": true}`, `{"b": {"d": null,"c": 5},"e": [1,2],"f": true}`},
+		{`{"a": 1}`, `[1]`, `[1]`},
+		{`{"a": 1}`, `{"a": 1.0}`, `{}`},
+	} {
+		from := parseAny(t, v[0])
+		to := parseAny(t, v[1])
+		patch := parser.CreateMergePatch(from, to)
+		if patch.JsonValue() != v[2] {
+			t.Errorf("CreateMergePatch should be %s. Actual %s", v[2], patch.JsonValue())
+		}
+		if result := parser.MergePatch(from, patch); !parser.EqualValues(result, to) {
+			t.Errorf("MergePatch should return %s. Actual %s", to.JsonValue(), result.JsonValue())
+		}
+	}
+}
+
+const mergeDst = `{"name": "a", "tags": ["x", "y"], "items": [{"id": 1, "v": "one"}, {"id": 2, "v": "two"}], "opts": {"on": true, "n": 1}}`
+const mergeSrc = `{"name": "b", "tags": ["z"], "items": [{"id": 2, "v": "TWO", "w": 2}, {"id": 3, "v": "three"}, "s"], "opts": {"n": 2, "m": 3}}`
+
+func TestDeepMergeStrategies(t *testing.T) {
+	checkDeepMerge(t, parser.MergeOptions{},
+		`{"name": "b","tags": ["z"],"items": [{"id": 2,"v": "TWO","w": 2},{"id": 3,"v": "three"},"s"],"opts": {"on": true,"n": 2,"m": 3}}`)
+	checkDeepMerge(t, parser.MergeOptions{Lists: parser.LIST_APPEND},
+		`{"name": "b","tags": ["x","y","z"],"items": [{"id": 1,"v": "one"},{"id": 2,"v": "two"},{"id": 2,"v": "TWO","w": 2},{"id": 3,"v": "three"},"s"],"opts": {"on": true,"n": 2,"m": 3}}`)
+	checkDeepMerge(t, parser.MergeOptions{Lists: parser.LIST_MERGE_BY_KEY, Key: "id"},
+		`{"name": "b","tags": ["x","y","z"],"items": [{"id": 1,"v": "one"},{"id": 2,"v": "TWO","w": 2},{"id": 3,"v": "three"},"s"],"opts": {"on": true,"n": 2,"m": 3}}`)
+	_, err := parser.DeepMerge(parseAny(t, mergeDst), parseAny(t, mergeSrc), parser.MergeOptions{Lists: parser.LIST_MERGE_BY_KEY})
+	CheckErr(t, err, "a Key is required")
+}
+
+func TestDeepMergeConflicts(t *testing.T) {
+	conflicts := make([]string, 0)
+	keepDst := func(path *parser.Path, dst, src parser.NodeI) (parser.NodeI, error) {
+		conflicts = append(conflicts, fmt.Sprintf("%s=%s/%s", path, dst, src))
+		return dst, nil
+	}
+	checkDeepMerge(t, parser.MergeOptions{Lists: parser.LIST_MERGE_BY_KEY, Key: "id", OnConflict: keepDst},
+		`{"name": "a","tags": ["x","y","z"],"items": [{"id": 1,"v": "one"},{"id": 2,"v": "two","w": 2},{"id": 3,"v": "three"},"s"],"opts": {"on": true,"n": 1,"m": 3}}`)
+	if fmt.Sprint(conflicts) != "[name=a/b items.1.v=two/TWO opts.n=1/2]" {
+		t.Errorf("Conflicts are incorrect. Actual %s", conflicts)
+	}
+	join := func(path *parser.Path, dst, src parser.NodeI) (parser.NodeI, error) {
+		return parser.NewJsonString("", dst.String()+"+"+src.String()), nil
+	}
+	checkDeepMerge(t, parser.MergeOptions{OnConflict: join},
+		`{"name": "a+b","tags": ["z"],"items": [{"id": 2,"v": "TWO","w": 2},{"id": 3,"v": "three"},"s"],"opts": {"on": true,"n": "1+2","m": 3}}`)
+	dst := parseAny(t, mergeDst)
+	_, err := parser.DeepMerge(dst, parseAny(t, `{"opts": []}`), parser.MergeOptions{OnConflict: func(path *parser.Path, d, s parser.NodeI) (parser.NodeI, error) {
+		return nil, fmt.Errorf("conflict at %s", path)
+	}})
+	CheckErr(t, err, "conflict at opts")
+}
+
+func checkDeepMerge(t *testing.T, options parser.MergeOptions, expected string) {
+	dst := parseAny(t, mergeDst)
+	src := parseAny(t, mergeSrc)
+	result, err := parser.DeepMerge(dst, src, options)
+	if err != nil {
+		t.Errorf("DeepMerge failed: %s", err)
+		return
+	}
+	if result.JsonValue() != expected {
+		t.Errorf("DeepMerge should return %s. Actual %s", expected, result.JsonValue())
+	}
+	if dst.JsonValue() != parseAny(t, mergeDst).JsonValue() || src.JsonValue() != parseAny(t, mergeSrc).JsonValue() {
+		t.Errorf("DeepMerge should not change dst or src")
+	}
+	checkParents(t, result)
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+var (
+	nestedLists = []byte(`{"grid": [[1,2],[3,[4,5]],[],[{"a": 1},"b"]]}`)
+	rootLists   = []byte(`[[1,2],[3,4]]`)
+)
+
+func TestNestedListParse(t *testing.T) {
+	root := InitParser(t, "nestedLists", nestedLists)
+	if root == nil {
+		return
+	}
+	if root.JsonValue() != string(nestedLists) {
+		t.Errorf("JsonValue should match source\nExpected:%s\nActual  :%s", nestedLists, root.JsonValue())
+	}
+	reparsed := InitParser(t, "JsonValueIndented", []byte(root.JsonValueIndented(4)))
+	if !root.Equal(reparsed) {
+		t.Errorf("JsonValueIndented should parse back to the same tree. %s", root.JsonValueIndented(4))
+	}
+	list := InitParser(t, "rootLists", rootLists)
+	if list == nil {
+		return
+	}
+	if list.Len() != 2 {
+		t.Errorf("root list should contain 2 lists not %d", list.Len())
+	}
+	if list.JsonValue() != string(rootLists) {
+		t.Errorf("JsonValue should match source\nExpected:%s\nActual  :%s", rootLists, list.JsonValue())
+	}
+}
+
+func TestNestedListFind(t *testing.T) {
+	root := InitParser(t, "nestedLists", nestedLists)
+	CheckFindNode(t, root, "grid.0.1", "2")
+	CheckFindNode(t, root, "grid.1.0", "3")
+	CheckFindNode(t, root, "grid.1.1.1", "5")
+	CheckFindNode(t, root, "grid.3.0.a", "1")
+	CheckFindNode(t, root, "grid.3.1", "b")
+	n := CheckFindNode(t, root, "grid.2", "[]")
+	if n != nil && n.(parser.NodeC).Len() != 0 {
+		t.Errorf("grid.2 should be empty")
+	}
+	_, err := parser.Find(root, parser.NewDotPath("grid.1.2"))
+	CheckErr(t, err, "index out of bounds")
+}
+
+func TestNestedListCloneAndEqual(t *testing.T) {
+	root := InitParser(t, "nestedLists", nestedLists)
+	clone := parser.Clone(root, root.GetName(), true)
+	if !root.Equal(clone) {
+		t.Errorf("Clone should equal the original\nExpected:%s\nActual  :%s", root.JsonValue(), clone.JsonValue())
+	}
+	n, _ := parser.Find(clone, parser.NewDotPath("grid.1.1.0"))
+	n.(*parser.JsonNumber).SetValue(40)
+	if root.Equal(clone) {
+		t.Errorf("Clone should NOT equal the original after update")
+	}
+	other := InitParser(t, "other", []byte(`{"grid": [[1,2],[3,[4,5,6]],[],[{"a": 1},"b"]]}`))
+	if root.Equal(other) {
+		t.Errorf("Lists of a different length should NOT be equal")
+	}
+}
+
+func TestNestedListWalk(t *testing.T) {
+	root := InitParser(t, "nestedLists", nestedLists)
+	testWNTFP(t, root, parser.NewBarPath("grid|1|1|0"), "4")
+	testWNTFP(t, root, parser.NewBarPath("grid|3|0|a"), "1")
+	count := 0
+	parser.WalkNodeTree(root, nil, func(n, p, t parser.NodeI) bool {
+		count++
+		return false
+	})
+	if count != 15 {
+		t.Errorf("WalkNodeTree should visit 15 nodes not %d", count)
+	}
+}
+
+func TestNestedListRemove(t *testing.T) {
+	root := InitParser(t, "nestedLists", nestedLists)
+	n := CheckFindNode(t, root, "grid.1.1.0", "4")
+	err := parser.Remove(n)
+	if err != nil {
+		t.Errorf("Remove failed: %s", err.Error())
+	}
+	n = CheckFindNode(t, root, "grid.1.1.0", "5")
+	parser.Remove(n)
+	CheckFindNode(t, root, "grid.1.1", "[]")
+}
+
+func TestNestedListRemoveViaGrandParent(t *testing.T) {
+	root := InitParser(t, "rootLists", []byte(`[[1],[2]]`))
+	n := CheckFindNode(t, root, "1.0", "2")
+	err := root.Remove(n)
+	if err != nil {
+		t.Errorf("Remove failed: %s", err.Error())
+	}
+	if root.JsonValue() != "[[1],[]]" {
+		t.Errorf("Empty nested list should NOT be removed. Actual:%s", root.JsonValue())
+	}
+}
@@ -284,3 +284,29 @@ func TestEqualNumber(t *testing.T) {
 		t.Error("failed: Number ':123' should equal ':123'")
 	}
 }
+
+func TestEqualValues(t *testing.T) {
+	a := InitParser(t, "", []byte(`{"x": {"a": 1, "b": [1.0, "s", true, null]}, "y": {"b": [1e0, "s", true, null], "a": 1.00}, "z": {"a": 1, "b": [1, "s", false, null]}}`))
+	x, _ := parser.Find(a, parser.NewDotPath("x"))
+	y, _ := parser.Find(a, parser.NewDotPath("y"))
+	z, _ := parser.Find(a, parser.NewDotPath("z"))
+	if x.Equal(y) {
+		t.Errorf("Equal should compare names")
+	}
+	if !parser.EqualValues(x, y) {
+		t.Errorf("EqualValues should ignore names, key order and number format")
+	}
+	if parser.EqualValues(x, z) {
+		t.Errorf("EqualValues should compare the values in lists")
+	}
+	if parser.EqualValues(parser.NewJsonString("a", "1"), parser.NewJsonNumber("a", 1)) {
+		t.Errorf("EqualValues should compare node types")
+	}
+	l1 := parser.NewJsonList("")
+	l1.Add(parser.NewJsonString("n", "v"))
+	l2 := parser.NewJsonList("")
+	l2.Add(parser.NewJsonString("", "v"))
+	if parser.EqualValues(l1, l2) {
+		t.Errorf("EqualValues should compare the names of nodes in lists")
+	}
+}
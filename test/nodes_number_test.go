@@ -0,0 +1,186 @@
+package test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+var (
+	numbersObj = []byte(`{"id": 9007199254740993,"max": 18446744073709551615,"neg": -9223372036854775808,"big": 123456789012345678901234567890,"amount": 1234567.89,"small": 1e-9,"exp": 1.5E+300,"zero": -0.0,"huge": 1e400}`)
+)
+
+func TestNumberLiteralRoundTrip(t *testing.T) {
+	root := InitParser(t, "numbersObj", numbersObj)
+	if root == nil {
+		return
+	}
+	if root.JsonValue() != string(numbersObj) {
+		t.Errorf("Numbers should render exactly as parsed\nExpected:%s\nActual  :%s", numbersObj, root.JsonValue())
+	}
+	CheckFindNode(t, root, "small", "1e-9")
+	n := CheckFindNode(t, root, "amount", "1234567.89").(*parser.JsonNumber)
+	n.SetValue(0.5)
+	if n.String() != "0.5" {
+		t.Errorf("Changed number should render the new value. Actual %s", n.String())
+	}
+	n.SetIntValue(9007199254740993)
+	if n.String() != "9007199254740993" {
+		t.Errorf("SetIntValue should render the exact value. Actual %s", n.String())
+	}
+}
+
+func TestNumberFormatFloat(t *testing.T) {
+	testNumberString(t, parser.NewJsonNumber("", 1e-9), "1e-9")
+	testNumberString(t, parser.NewJsonNumber("", 1e21), "1e+21")
+	testNumberString(t, parser.NewJsonNumber("", 123456789012345), "123456789012345")
+	testNumberString(t, parser.NewJsonNumber("", 0.0000015), "0.0000015")
+	testNumberString(t, parser.NewJsonNumber("", -0.1234567), "-0.1234567")
+	testNumberString(t, parser.NewJsonNumber("", 100), "100")
+}
+
+func testNumberString(t *testing.T, n *parser.JsonNumber, expected string) {
+	if n.String() != expected {
+		t.Errorf("Number String() should be '%s'. Actual '%s'", expected, n.String())
+	}
+	if n.JsonValue() != expected {
+		t.Errorf("Number JsonValue() should be '%s'. Actual '%s'", expected, n.JsonValue())
+	}
+}
+
+func TestNumberInt64(t *testing.T) {
+	root := InitParser(t, "numbersObj", numbersObj)
+	id := findNumber(t, root, "id")
+	i, err := id.GetInt64()
+	if err != nil || i != 9007199254740993 {
+		t.Errorf("GetInt64 should return 9007199254740993 exactly. Actual %d %v", i, err)
+	}
+	if id.GetIntValue() != 9007199254740993 {
+		t.Errorf("GetIntValue should return 9007199254740993 exactly. Actual %d", id.GetIntValue())
+	}
+	neg := findNumber(t, root, "neg")
+	i, err = neg.GetInt64()
+	if err != nil || i != -9223372036854775808 {
+		t.Errorf("GetInt64 should return min int64. Actual %d %v", i, err)
+	}
+	_, err = findNumber(t, root, "max").GetInt64()
+	CheckErr(t, err, "overflows int64")
+	_, err = findNumber(t, root, "amount").GetInt64()
+	CheckErr(t, err, "is not an integer")
+	n, _ := parser.NewJsonNumberFromLiteral("", "1.5e3")
+	i, err = n.GetInt64()
+	if err != nil || i != 1500 {
+		t.Errorf("GetInt64 of 1.5e3 should return 1500. Actual %d %v", i, err)
+	}
+}
+
+func TestNumberUint64(t *testing.T) {
+	root := InitParser(t, "numbersObj", numbersObj)
+	u, err := findNumber(t, root, "max").GetUint64()
+	if err != nil || u != 18446744073709551615 {
+		t.Errorf("GetUint64 should return max uint64. Actual %d %v", u, err)
+	}
+	_, err = findNumber(t, root, "neg").GetUint64()
+	CheckErr(t, err, "is negative")
+	_, err = findNumber(t, root, "big").GetUint64()
+	CheckErr(t, err, "overflows uint64")
+}
+
+func TestNumberBig(t *testing.T) {
+	root := InitParser(t, "numbersObj", numbersObj)
+	bi, err := findNumber(t, root, "big").GetBigInt()
+	if err != nil || bi.String() != "123456789012345678901234567890" {
+		t.Errorf("GetBigInt should return the exact value. Actual %s %v", bi, err)
+	}
+	bf := findNumber(t, root, "amount").GetBigFloat()
+	if bf.Text('f', 2) != "1234567.89" {
+		t.Errorf("GetBigFloat should return 1234567.89. Actual %s", bf.Text('f', 2))
+	}
+	r := findNumber(t, root, "amount").GetBigRat()
+	if r.Cmp(big.NewRat(123456789, 100)) != 0 {
+		t.Errorf("GetBigRat should return 123456789/100. Actual %s", r)
+	}
+	huge := findNumber(t, root, "huge").GetBigFloat()
+	if huge.IsInf() || huge.MantExp(nil) < 1000 {
+		t.Errorf("GetBigFloat should hold 1e400. Actual %s", huge.String())
+	}
+	n := parser.NewJsonNumber("", 0)
+	if err := n.SetBigIntValue(bi); err != nil {
+		t.Fatal(err)
+	}
+	if n.String() != "123456789012345678901234567890" {
+		t.Errorf("SetBigIntValue should render exactly. Actual %s", n.String())
+	}
+}
+
+func TestNumberEqualAndClone(t *testing.T) {
+	a, _ := parser.NewJsonNumberFromLiteral("n", "9007199254740993")
+	b, _ := parser.NewJsonNumberFromLiteral("n", "9007199254740992")
+	if a.GetValue() != b.GetValue() {
+		t.Errorf("float64 values should be the same for this test to be valid")
+	}
+	if a.Equal(b) {
+		t.Errorf("Numbers with different literals should not be equal")
+	}
+	c, _ := parser.NewJsonNumberFromLiteral("n", "1.0e2")
+	if !c.Equal(parser.NewJsonNumber("n", 100)) {
+		t.Errorf("1.0e2 should equal 100")
+	}
+	cl := parser.Clone(a, "n", true)
+	if cl.String() != "9007199254740993" || !cl.Equal(a) {
+		t.Errorf("Clone should keep the literal. Actual %s", cl.String())
+	}
+	_, err := parser.NewJsonNumberFromLiteral("n", "1.")
+	CheckErr(t, err, "invalid number '1.'")
+	_, err = parser.NewJsonNumberFromLiteral("n", "0x10")
+	CheckErr(t, err, "invalid number")
+}
+
+func TestNumberCmpHugeExponent(t *testing.T) {
+	num := func(lit string) *parser.JsonNumber {
+		n, err := parser.NewJsonNumberFromLiteral("", lit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	for _, c := range []struct {
+		a, b string
+		cmp  int
+	}{
+		{"1e-99999999", "2e-99999999", -1}, {"2e-99999999", "1e-99999999", 1}, {"1e-99999999", "0", 1},
+		{"-1e99999999", "1e-99999999", -1}, {"10e99999998", "1.0e99999999", 0}, {"0.0", "-0", 0},
+		{"0.001", "1e-3", 0}, {"123.45", "1234.5e-1", 0}, {"19", "2", 1}, {"-19", "-2", -1}, {"0.12", "0.123", -1},
+	} {
+		if actual := num(c.a).Cmp(num(c.b)); actual != c.cmp {
+			t.Errorf("%s Cmp %s should be %d. Actual %d", c.a, c.b, c.cmp, actual)
+		}
+	}
+	if parser.EqualValues(parseAny(t, `[1e-99999999]`), parseAny(t, `[2e-99999999]`)) {
+		t.Errorf("1e-99999999 should not equal 2e-99999999")
+	}
+	if num("1e-99999999").IsInteger() || !num("1e99999999").IsInteger() || !num("1.50e1").IsInteger() {
+		t.Errorf("IsInteger is incorrect for exponents")
+	}
+	_, err := num("1e99999999").GetBigInt()
+	CheckErr(t, err, "is too large for a big.Int")
+}
+
+func TestNumberParseInvalid(t *testing.T) {
+	for _, json := range []string{`[1e]`, `[--1]`, `[1.e5]`, `[.5]`, `[+1]`, `[1e5.5]`, `[-]`} {
+		_, err := parser.Parse([]byte(json))
+		if err == nil {
+			t.Errorf("Parse of '%s' should fail", json)
+		}
+	}
+}
+
+func findNumber(t *testing.T, root parser.NodeI, path string) *parser.JsonNumber {
+	n, err := parser.Find(root, parser.NewDotPath(path))
+	if err != nil {
+		t.Errorf("Find %s failed: %s", path, err.Error())
+		return parser.NewJsonNumber("", 0)
+	}
+	return n.(*parser.JsonNumber)
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+var (
+	orderedObj = []byte(`{"zebra": 1,"apple": 2,"mango": {"z": true,"a": false},"kiwi": [{"y": 1,"b": 2}]}`)
+)
+
+func TestObjectOrderPreserved(t *testing.T) {
+	root := InitParser(t, "orderedObj", orderedObj)
+	if root == nil {
+		return
+	}
+	if root.JsonValue() != string(orderedObj) {
+		t.Errorf("JsonValue should keep the key order\nExpected:%s\nActual  :%s", orderedObj, root.JsonValue())
+	}
+	keys := root.(*parser.JsonObject).GetKeys()
+	if fmt.Sprintf("%s", keys) != "[zebra apple mango kiwi]" {
+		t.Errorf("GetKeys should be in insertion order. Actual %s", keys)
+	}
+	values := root.GetValues()
+	if values[0].GetName() != "zebra" || values[3].GetName() != "kiwi" {
+		t.Errorf("GetValues should be in insertion order")
+	}
+	indented := root.JsonValueIndented(2)
+	if strings.Index(indented, "zebra") > strings.Index(indented, "apple") || strings.Index(indented, "\"z\"") > strings.Index(indented, "\"a\"") {
+		t.Errorf("JsonValueIndented should keep the key order. Actual %s", indented)
+	}
+	for i := 0; i < 10; i++ {
+		if root.JsonValue() != string(orderedObj) {
+			t.Errorf("JsonValue should be the same every time")
+		}
+	}
+}
+
+func TestObjectOrderAddRemoveRename(t *testing.T) {
+	root := InitParser(t, "orderedObj", orderedObj)
+	obj := root.(*parser.JsonObject)
+	obj.Add(parser.NewJsonString("banana", "B"))
+	assertKeys(t, obj, "[zebra apple mango kiwi banana]")
+	n := obj.GetNodeWithName("apple")
+	parser.Remove(n)
+	assertKeys(t, obj, "[zebra mango kiwi banana]")
+	err := parser.Rename(obj.GetNodeWithName("mango"), "cherry")
+	if err != nil {
+		t.Errorf("Rename failed: %s", err.Error())
+	}
+	assertKeys(t, obj, "[zebra cherry kiwi banana]")
+	CheckFindNode(t, root, "cherry.z", "true")
+	obj.Add(parser.NewJsonString("mango", "M"))
+	assertKeys(t, obj, "[zebra cherry kiwi banana mango]")
+	obj.Clear()
+	assertKeys(t, obj, "[]")
+	obj.Add(parser.NewJsonString("first", "F"))
+	assertKeys(t, obj, "[first]")
+}
+
+func TestObjectOrderMove(t *testing.T) {
+	root := InitParser(t, "orderedObj", orderedObj)
+	obj := root.(*parser.JsonObject)
+	testMove(t, obj.MoveBefore("kiwi", "zebra"))
+	assertKeys(t, obj, "[kiwi zebra apple mango]")
+	testMove(t, obj.MoveAfter("kiwi", "mango"))
+	assertKeys(t, obj, "[zebra apple mango kiwi]")
+	testMove(t, obj.MoveAfter("zebra", "apple"))
+	assertKeys(t, obj, "[apple zebra mango kiwi]")
+	testMove(t, obj.MoveBefore("mango", "apple"))
+	assertKeys(t, obj, "[mango apple zebra kiwi]")
+	testMove(t, obj.MoveBefore("mango", "mango"))
+	assertKeys(t, obj, "[mango apple zebra kiwi]")
+	CheckErr(t, obj.MoveBefore("nothing", "apple"), "no matching node [nothing]")
+	CheckErr(t, obj.MoveAfter("apple", "nothing"), "no matching node [nothing]")
+	assertKeys(t, obj, "[mango apple zebra kiwi]")
+	if !strings.HasPrefix(obj.JsonValue(), "{\"mango\": {") {
+		t.Errorf("JsonValue should follow the moved order. Actual %s", obj.JsonValue())
+	}
+}
+
+func TestObjectOrderSort(t *testing.T) {
+	root := InitParser(t, "orderedObj", orderedObj)
+	obj := root.(*parser.JsonObject)
+	obj.SortKeys()
+	assertKeys(t, obj, "[apple kiwi mango zebra]")
+	obj.SortFunc(func(a, b parser.NodeI) bool {
+		return a.GetNodeType() < b.GetNodeType()
+	})
+	assertKeys(t, obj, "[mango kiwi apple zebra]")
+	other := InitParser(t, "orderedObj", orderedObj)
+	if !obj.Equal(other) {
+		t.Errorf("Objects with the same keys in a different order should be Equal")
+	}
+}
+
+func testMove(t *testing.T, err error) {
+	if err != nil {
+		t.Errorf("Move failed: %s", err.Error())
+	}
+}
+
+func assertKeys(t *testing.T, obj *parser.JsonObject, expected string) {
+	keys := fmt.Sprintf("%s", obj.GetKeys())
+	if keys != expected {
+		t.Errorf("Keys should be %s. Actual %s", expected, keys)
+	}
+}
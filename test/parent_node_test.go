@@ -16,11 +16,11 @@ func TestParentOfObjectsInRoot(t *testing.T) {
 		t.Errorf("failed: Rootnode parent must be nil")
 		return
 	}
-	if root1 != root1.GetNodeWithName("config").GetParent() {
+	if root1 != root1.(parser.NodeC).GetNodeWithName("config").GetParent() {
 		t.Errorf("failed: config nod must have root as parent")
 		return
 	}
-	if root1 != root1.GetNodeWithName("actions").GetParent() {
+	if root1 != root1.(parser.NodeC).GetNodeWithName("actions").GetParent() {
 		t.Errorf("failed: actions nod must have root as parent")
 		return
 	}
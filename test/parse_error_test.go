@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func parseError(t *testing.T, json string) *parser.ParseError {
+	_, err := parser.Parse([]byte(json))
+	if err == nil {
+		t.Errorf("Parse of '%s' should fail", json)
+		return nil
+	}
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("Parse of '%s' should return a *ParseError not %T", json, err)
+		return nil
+	}
+	return pe
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	pe := parseError(t, "{\n  \"a\": 1,\n  \"b\" 2\n}")
+	if pe == nil {
+		return
+	}
+	if pe.Line != 3 || pe.Column != 7 || pe.Offset != 18 {
+		t.Errorf("Position should be Line 3 Column 7 Offset 18. Actual Line %d Column %d Offset %d", pe.Line, pe.Column, pe.Offset)
+	}
+	if pe.Token == nil || pe.Token.GetType() != parser.TT_NUMBER || pe.Token.GetPos() != 18 || pe.Token.GetStringValue() != "2" {
+		t.Errorf("Token should be the NUMBER '2' at 18. Actual %s", pe.Token)
+	}
+	if len(pe.Expected) != 1 || pe.Expected[0] != parser.TT_COLON {
+		t.Errorf("Expected should be [COLON]. Actual %s", pe.ExpectedNames())
+	}
+	if pe.Context != "{\n  \"a\": 1,\n  \"b\" |2\n}" {
+		t.Errorf("Context is incorrect. Actual '%s'", pe.Context)
+	}
+	CheckErr(t, pe, "Line: 3 Column: 7 Offset: 18")
+	CheckErr(t, pe, "object name not followed by a ':'")
+}
+
+func TestParseErrorExpected(t *testing.T) {
+	pe := parseError(t, `[1 2]`)
+	if pe != nil && pe.ExpectedNames() != "COMMA or ARRAY_CLOSE" {
+		t.Errorf("Expected names incorrect. Actual %s", pe.ExpectedNames())
+	}
+	pe = parseError(t, `{"a":1 "b":2}`)
+	if pe != nil && pe.ExpectedNames() != "COMMA or OBJECT_CLOSE" {
+		t.Errorf("Expected names incorrect. Actual %s", pe.ExpectedNames())
+	}
+	pe = parseError(t, `{"a":}`)
+	if pe != nil && len(pe.Expected) != 7 {
+		t.Errorf("Expected should be all value tokens. Actual %s", pe.ExpectedNames())
+	}
+	pe = parseError(t, `{"a":1, "a":2}`)
+	if pe != nil {
+		if pe.Token == nil || pe.Token.GetStringValue() != "a" || pe.Offset != 8 {
+			t.Errorf("Duplicate name error should point to the second name. Actual %s at %d", pe.Token, pe.Offset)
+		}
+		CheckErr(t, pe, "duplicate name [a]")
+	}
+}
+
+func TestParseErrorInsideToken(t *testing.T) {
+	pe := parseError(t, `["abc\uZZ"]`)
+	if pe == nil {
+		return
+	}
+	if pe.Token != nil {
+		t.Errorf("Error inside a token should not have a Token. Actual %s", pe.Token)
+	}
+	if pe.Offset != 7 || pe.Column != 8 {
+		t.Errorf("Error should be at offset 7 column 8. Actual %d %d", pe.Offset, pe.Column)
+	}
+	CheckErr(t, pe, "invalid hex digit")
+	pe = parseError(t, "[\n\"abc")
+	if pe != nil && (pe.Line != 2 || pe.Column != 5) {
+		t.Errorf("Unterminated string should be at Line 2 Column 5. Actual %d %d", pe.Line, pe.Column)
+	}
+	pe = parseError(t, `[1.2.3]`)
+	if pe != nil {
+		CheckErr(t, pe, "invalid number '1.2.3'")
+	}
+	pe = parseError(t, `[ttue]`)
+	if pe != nil {
+		CheckErr(t, pe, "unrecognised token. 'ttue'")
+	}
+}
+
+func TestParseErrorReader(t *testing.T) {
+	_, err := parser.ParseReader(iotest.TimeoutReader(bytes.NewReader([]byte(strings.Repeat(" ", 5000) + "[1,2]"))))
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("ParseReader should return a *ParseError not %T", err)
+		return
+	}
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("ParseError should wrap the reader error. Actual %s", err)
+	}
+	pe = nil
+	_, err = parser.ParseReader(strings.NewReader(strings.Repeat("\n", 5000) + "[1,,2]"))
+	if !errors.As(err, &pe) {
+		t.Errorf("ParseReader should return a *ParseError not %T", err)
+		return
+	}
+	if pe.Line != 5001 || pe.Column != 4 || pe.Offset != 5003 {
+		t.Errorf("Position should be Line 5001 Column 4 Offset 5003. Actual Line %d Column %d Offset %d", pe.Line, pe.Column, pe.Offset)
+	}
+}
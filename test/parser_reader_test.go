@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func TestParseReaderSameAsParse(t *testing.T) {
+	for _, dat := range [][]byte{obj1, obj2, obj3, obj4, obj5, nList1, nList2, singleListData, text} {
+		expected, err := parser.Parse(dat)
+		if err != nil {
+			t.Errorf("Parse failed: %s", err.Error())
+			continue
+		}
+		actual, err := parser.ParseReader(bytes.NewReader(dat))
+		if err != nil {
+			t.Errorf("ParseReader failed: %s", err.Error())
+			continue
+		}
+		if !expected.Equal(actual) {
+			t.Errorf("ParseReader tree does not equal Parse tree\nExpected:%s\nActual  :%s", expected.JsonValue(), actual.JsonValue())
+		}
+	}
+}
+
+func TestParseReaderSmallBuffer(t *testing.T) {
+	expected := InitParserFromFile(t, "TestDataTypes.json")
+	dat := []byte(expected.JsonValueIndented(4))
+	sc := parser.NewReaderScannerSize(iotest.OneByteReader(bytes.NewReader(dat)), 8)
+	count := 0
+	for sc.SkipSpace().HasNext() {
+		_, err := sc.NextToken()
+		if err != nil {
+			t.Errorf("NextToken failed: %s", err.Error())
+			return
+		}
+		count++
+	}
+	if count == 0 {
+		t.Errorf("Should have read some tokens")
+	}
+	actual, err := parser.ParseReader(iotest.HalfReader(bytes.NewReader(dat)))
+	if err != nil {
+		t.Errorf("ParseReader failed: %s", err.Error())
+		return
+	}
+	if !expected.Equal(actual) {
+		t.Errorf("ParseReader tree does not equal Parse tree")
+	}
+}
+
+func TestParseReaderLongString(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 1000)
+	actual, err := parser.ParseReader(strings.NewReader(`{"long":"` + long + `", "list":[1,2,3]}`))
+	if err != nil {
+		t.Errorf("ParseReader failed: %s", err.Error())
+		return
+	}
+	assertExact(t, "01", actual, "long", long)
+}
+
+func TestParseReaderPeekAcrossRefill(t *testing.T) {
+	sc := parser.NewReaderScannerSize(iotest.OneByteReader(strings.NewReader(`  "peek", "next"`)), 1)
+	toc, err := sc.PeekToken()
+	if err != nil {
+		t.Errorf("PeekToken failed: %s", err.Error())
+		return
+	}
+	if toc.GetStringValue() != "peek" {
+		t.Errorf("PeekToken should return 'peek' actual '%s'", toc.GetStringValue())
+	}
+	token(t, "peek", sc, parser.TT_QUOTED_STRING)
+	token(t, ",", sc, parser.TT_COMMA)
+	token(t, "next", sc, parser.TT_QUOTED_STRING)
+}
+
+func TestParseReaderError(t *testing.T) {
+	dat := strings.Repeat(" ", 10000) + `{"config": {"password1": ["ace","pwe",]}}`
+	_, err := parser.ParseReader(iotest.OneByteReader(strings.NewReader(dat)))
+	if err == nil {
+		t.Errorf("Should have returned an error")
+		return
+	}
+	CheckErr(t, err, "found an invalid ','")
+	CheckErr(t, err, "[\"ace\",\"pwe\"|,]}}")
+	_, err = parser.ParseReader(iotest.ErrReader(bytes.ErrTooLarge))
+	CheckErr(t, err, "failed to read input")
+}
@@ -286,3 +286,48 @@ func makeList() parser.NodeC {
 	p.Add(b2)
 	return p
 }
+
+func TestParserScalarRoot(t *testing.T) {
+	testScalarRoot(t, `"hello"`, parser.NT_STRING, "hello")
+	testScalarRoot(t, ` "a\tb" `, parser.NT_STRING, "a\tb")
+	testScalarRoot(t, `42`, parser.NT_NUMBER, "42")
+	testScalarRoot(t, "\n-1.5\n", parser.NT_NUMBER, "-1.5")
+	testScalarRoot(t, `true`, parser.NT_BOOL, "true")
+	testScalarRoot(t, `false`, parser.NT_BOOL, "false")
+	testScalarRoot(t, `null`, parser.NT_NULL, "null")
+}
+
+func testScalarRoot(t *testing.T, json string, nt parser.NodeType, value string) {
+	n, err := parser.Parse([]byte(json))
+	if err != nil {
+		t.Errorf("Parse of '%s' failed: %s", json, err.Error())
+		return
+	}
+	if n.GetNodeType() != nt {
+		t.Errorf("Parse of '%s' should return a %s node not %s", json, parser.GetNodeTypeName(nt), parser.GetNodeTypeName(n.GetNodeType()))
+	}
+	if n.GetName() != "" {
+		t.Errorf("Parse of '%s' root node should not have a name", json)
+	}
+	if n.String() != value {
+		t.Errorf("Parse of '%s' value should be '%s' not '%s'", json, value, n.String())
+	}
+}
+
+func TestParserTrailingData(t *testing.T) {
+	for _, json := range []string{`{"a":1}}`, `[1,2]]`, `{"a":1} {"b":2}`, `"hello" x`, `42 43`, `truex`, `null,`, `[1] // comment`} {
+		n, err := parser.Parse([]byte(json))
+		if err == nil {
+			t.Errorf("Parse of '%s' should fail. Returned %s", json, n.JsonValue())
+			continue
+		}
+	}
+	_, err := parser.Parse([]byte(`{"a":1} x`))
+	CheckErr(t, err, "unexpected data after the root value")
+	_, err = parser.Parse([]byte("  {\"a\":1}  \n\t "))
+	if err != nil {
+		t.Errorf("Trailing white space should be ignored: %s", err.Error())
+	}
+	_, err = parser.Parse([]byte(""))
+	CheckErr(t, err, "unexpected end of input")
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func TestPatchRFCExamples(t *testing.T) {
+	checkPatch(t, `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"foo": "bar","baz": "qux"}`)
+	checkPatch(t, `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar","qux","baz"]}`)
+	checkPatch(t, `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`)
+	checkPatch(t, `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar","baz"]}`)
+	checkPatch(t, `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo","foo": "bar"}`)
+	checkPatch(t, `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, `{"foo": {"bar": "baz"},"qux": {"corge": "grault","thud": "fred"}}`)
+	checkPatch(t, `{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all","cows","eat","grass"]}`)
+	checkPatch(t, `{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`, `{"baz": "qux","foo": ["a",2,"c"]}`)
+	checkPatch(t, `{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar","child": {"grandchild": {}}}`)
+	checkPatch(t, `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar",["abc","def"]]}`)
+	checkPatch(t, `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}, {"op": "copy", "from": "/~1", "path": "/a"}]`, `{"/": 9,"~1": 10,"a": 9}`)
+	checkPatch(t, `{"foo": 1}`, `[{"op": "replace", "path": "", "value": [1, 2]}]`, `[1,2]`)
+	checkPatch(t, `{"foo": [1, 2]}`, `[{"op": "replace", "path": "/foo/0", "value": null}]`, `{"foo": [null,2]}`)
+}
+
+func TestPatchErrorsRollback(t *testing.T) {
+	checkPatchErr(t, `{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`, "test failed")
+	checkPatchErr(t, `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, "'/baz' was not found")
+	checkPatchErr(t, `{"foo": [1]}`, `[{"op": "add", "path": "/foo/2", "value": 1}]`, "index 2 is out of bounds")
+	checkPatchErr(t, `{"foo": [1]}`, `[{"op": "remove", "path": "/foo/01"}]`, "invalid list index '01'")
+	checkPatchErr(t, `{"foo": [1]}`, `[{"op": "remove", "path": "/foo/-"}]`, "invalid list index '-'")
+	checkPatchErr(t, `{"foo": {"a": 1}}`, `[{"op": "move", "from": "/foo", "path": "/foo/a/b"}]`, "cannot move '/foo' into one of its children")
+	checkPatchErr(t, `{"foo": 1}`, `[{"op": "remove", "path": ""}]`, "cannot remove the root node")
+	checkPatchErr(t, `{"foo": 1}`, `[{"op": "replace", "path": "/bar", "value": 1}]`, "'/bar' was not found")
+	checkPatchErr(t, `{"foo": 1}`, `[{"op": "add", "path": "/foo/a", "value": 1}]`, "is not an object or a list")
+	// Every operation before the failure must be undone
+	checkPatchErr(t, `{"a": {"x": 1, "y": 2}, "b": [1, 2, 3], "c": "c"}`, `[
+		{"op": "remove", "path": "/a/x"},
+		{"op": "add", "path": "/a/x", "value": 9},
+		{"op": "replace", "path": "/a/y", "value": [1]},
+		{"op": "move", "from": "/b/0", "path": "/b/-"},
+		{"op": "move", "from": "/c", "path": "/a/c"},
+		{"op": "copy", "from": "/a", "path": "/d"},
+		{"op": "remove", "path": "/b/1"},
+		{"op": "replace", "path": "/b/0", "value": {}},
+		{"op": "add", "path": "/b/0", "value": 0},
+		{"op": "test", "path": "/c", "value": "c"}]`, "patch operation 9 (test '/c') failed")
+}
+
+func TestPatchParse(t *testing.T) {
+	for patch, msg := range map[string]string{
+		`{"op": "add"}`:               "must be a list",
+		`[1]`:                         "operation 0 is not an object",
+		`[{"path": "/a"}]`:            "requires a string 'op'",
+		`[{"op": "add", "value": 1}]`: "requires a string 'path'",
+		`[{"op": "add", "path": "a", "value": 1}]`: "must be empty or start with a '/'",
+		`[{"op": "add", "path": "/a"}]`:            "requires a 'value'",
+		`[{"op": "move", "path": "/a"}]`:           "requires a string 'from'",
+		`[{"op": "bad", "path": "/a"}]`:            "unknown op 'bad'",
+	} {
+		_, err := parser.ParsePatch([]byte(patch))
+		CheckErr(t, err, msg)
+	}
+	p, err := parser.ParsePatch([]byte(`[{"op": "copy", "path": "/a", "from": "/b"}, {"op": "test", "path": "/a", "value": null}, {"op": "remove", "path": "/c"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.JsonValue() != `[{"op": "copy","from": "/b","path": "/a"},{"op": "test","path": "/a","value": null},{"op": "remove","path": "/c"}]` {
+		t.Errorf("Patch JsonValue is incorrect. Actual %s", p.JsonValue())
+	}
+}
+
+func TestPatchCreate(t *testing.T) {
+	for _, v := range [][]string{
+		{`{"a": 1, "b": [1, 2, 3], "c": {"d": true}}`, `{"a": 2, "b": [1, 5], "c": {"e": null}, "f/g": "x"}`},
+		{`{"a": [1]}`, `{"a": [1, 2, [3, {"b": 4}]]}`},
+		{`{"a": [1]}`, `{"a": {"0": 1}}`},
+		{`[1, 2]`, `{"a": 1}`},
+		{`{"a": 1.0}`, `{"a": 1}`},
+	} {
+		from := parseAny(t, v[0])
+		to := parseAny(t, v[1])
+		patch := parser.CreatePatch(from, to)
+		// The patch must survive a round trip through json
+		patch, err := parser.ParsePatch([]byte(patch.JsonValue()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := patch.Apply(from)
+		if err != nil {
+			t.Errorf("Patch %s failed: %s", patch, err)
+			continue
+		}
+		if !parser.EqualValues(result, to) {
+			t.Errorf("Patch %s should create %s. Actual %s", patch, to.JsonValue(), result.JsonValue())
+		}
+	}
+	a := parseAny(t, `{"a": 1.0}`)
+	if len(parser.CreatePatch(a, parseAny(t, `{"a": 1}`))) != 0 {
+		t.Errorf("Equal values should not create a patch")
+	}
+}
+
+func parseAny(t *testing.T, json string) parser.NodeI {
+	n, err := parser.Parse([]byte(json))
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %s", json, err)
+	}
+	return n
+}
+
+func checkPatch(t *testing.T, doc, patch, expected string) {
+	root := parseAny(t, doc)
+	p, err := parser.ParsePatch([]byte(patch))
+	if err != nil {
+		t.Errorf("ParsePatch %s failed: %s", patch, err)
+		return
+	}
+	result, err := p.Apply(root)
+	if err != nil {
+		t.Errorf("Apply %s failed: %s", patch, err)
+		return
+	}
+	if result.JsonValue() != expected {
+		t.Errorf("Apply %s should return %s. Actual %s", patch, expected, result.JsonValue())
+	}
+}
+
+func checkPatchErr(t *testing.T, doc, patch, msg string) {
+	root := parseAny(t, doc)
+	before := root.JsonValue()
+	p, err := parser.ParsePatch([]byte(patch))
+	if err != nil {
+		t.Errorf("ParsePatch %s failed: %s", patch, err)
+		return
+	}
+	result, err := p.Apply(root)
+	CheckErr(t, err, msg)
+	if result != root || root.JsonValue() != before {
+		t.Errorf("A failed patch should leave the document unchanged. Expected %s Actual %s", before, root.JsonValue())
+	}
+	checkParents(t, root)
+}
+
+func checkParents(t *testing.T, node parser.NodeI) {
+	if c, ok := node.(parser.NodeC); ok {
+		for _, v := range c.GetValues() {
+			if v.GetParent() != c {
+				t.Errorf("Node %s has the wrong parent", v.GetName())
+			}
+			checkParents(t, v)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const pointerJson = `{"foo": ["bar", "baz"], "a/b": 1, "c%d": 2, "e^f": 3, "g|h": 4, "i\\j": 5, "k\"l": 6, " ": 7, "m~n": 8, "x.y": {"z": [{"id": 1}, {"id": 2}]}}`
+
+func TestPointerRFCExamples(t *testing.T) {
+	root := InitParser(t, "", []byte(pointerJson))
+	checkPointer(t, root, "/foo/0", "bar")
+	checkPointer(t, root, "/a~1b", "1")
+	checkPointer(t, root, "/c%d", "2")
+	checkPointer(t, root, "/e^f", "3")
+	checkPointer(t, root, "/g|h", "4")
+	checkPointer(t, root, "/i\\j", "5")
+	checkPointer(t, root, "/k\"l", "6")
+	checkPointer(t, root, "/ ", "7")
+	checkPointer(t, root, "/m~0n", "8")
+	checkPointer(t, root, "/x.y/z/1/id", "2")
+	n, err := parser.FindPointer(root, "")
+	if err != nil || n != root {
+		t.Errorf("Pointer '' should return the root node")
+	}
+}
+
+func TestPointerErrors(t *testing.T) {
+	root := InitParser(t, "", []byte(pointerJson))
+	_, err := parser.FindPointer(root, "foo")
+	CheckErr(t, err, "must be empty or start with a '/'")
+	_, err = parser.FindPointer(root, "/m~2n")
+	CheckErr(t, err, "'~' must be followed by '0' or '1'")
+	_, err = parser.FindPointer(root, "/m~")
+	CheckErr(t, err, "'~' must be followed by '0' or '1'")
+	_, err = parser.FindPointer(root, "/foo/2")
+	CheckErr(t, err, "index out of bounds")
+	_, err = parser.FindPointer(root, "/bar")
+	CheckErr(t, err, "was not found")
+}
+
+func TestPointerPath(t *testing.T) {
+	p, err := parser.NewPointerPath("/a~1b/m~0n/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 3 || p.StringAt(0) != "a/b" || p.StringAt(1) != "m~n" || p.StringAt(2) != "0" {
+		t.Errorf("Path elements are incorrect. Actual %s", p)
+	}
+	if p.Pointer() != "/a~1b/m~0n/0" {
+		t.Errorf("Pointer is incorrect. Actual %s", p.Pointer())
+	}
+	if p.PathAt(0).StringFirst() != "a/b" {
+		t.Errorf("PathAt should not split the element. Actual %s", p.PathAt(0))
+	}
+	if parser.NewDotPath("a.b~c.d/e").Pointer() != "/a/b~0c/d~1e" {
+		t.Errorf("Pointer is incorrect. Actual %s", parser.NewDotPath("a.b~c.d/e").Pointer())
+	}
+	if parser.NewDotPath("").Pointer() != "" {
+		t.Errorf("Empty path pointer should be ''")
+	}
+	p, _ = parser.NewPointerPath("")
+	if !p.IsEmpty() {
+		t.Errorf("Pointer '' should be an empty path")
+	}
+}
+
+func TestPointerTo(t *testing.T) {
+	root := InitParser(t, "", []byte(pointerJson))
+	for _, ptr := range []string{"/foo/1", "/a~1b", "/m~0n", "/x.y/z/0/id", "/x.y/z/1", "/ "} {
+		n, err := parser.FindPointer(root, ptr)
+		if err != nil {
+			t.Errorf("Pointer %s not found: %s", ptr, err)
+			continue
+		}
+		if parser.PointerTo(n) != ptr {
+			t.Errorf("PointerTo should return %s. Actual %s", ptr, parser.PointerTo(n))
+		}
+	}
+	if parser.PathTo(root).Len() != 0 || parser.PointerTo(root) != "" {
+		t.Errorf("PointerTo root should be ''")
+	}
+}
+
+func TestPointerCreate(t *testing.T) {
+	root := InitParser(t, "", []byte(pointerJson))
+	n, err := parser.CreateAndReturnNodeAtPointer(root, "/x.y/z/0/name~1full", parser.NT_STRING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.(*parser.JsonString).SetValue("fred")
+	checkPointer(t, root, "/x.y/z/0/name~1full", "fred")
+	n2, err := parser.CreateAndReturnNodeAtPointer(root, "/x.y/z/0/name~1full", parser.NT_STRING)
+	if err != nil || n2 != n {
+		t.Errorf("Existing node should be returned")
+	}
+	_, err = parser.CreateAndReturnNodeAtPointer(root, "/foo/1", parser.NT_NUMBER)
+	CheckErr(t, err, "is not a NUMBER node")
+	n, err = parser.CreateAndReturnNodeAtPointer(root, "/new/a.b", parser.NT_BOOL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPointer(t, root, "/new/a.b", "false")
+	if parser.PointerTo(n) != "/new/a.b" {
+		t.Errorf("PointerTo should return /new/a.b. Actual %s", parser.PointerTo(n))
+	}
+}
+
+func TestPointerCreateInList(t *testing.T) {
+	root := InitParser(t, "", []byte(`{"list": [1, 2]}`))
+	_, err := parser.CreateAndReturnNodeAtPointer(root, "/list/5", parser.NT_STRING)
+	CheckErr(t, err, "index [5] does not exist in a list of length 2")
+	_, err = parser.CreateAndReturnNodeAtPointer(root, "/list/x/a", parser.NT_STRING)
+	CheckErr(t, err, "index [x] does not exist in a list of length 2")
+	n, err := parser.CreateAndReturnNodeAtPointer(root, "/list/2", parser.NT_STRING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.(*parser.JsonString).SetValue("a")
+	if _, err = parser.CreateAndReturnNodeAtPointer(root, "/list/-/b", parser.NT_NUMBER); err != nil {
+		t.Fatal(err)
+	}
+	if root.JsonValue() != `{"list": [1,2,"a",{"b": 0}]}` {
+		t.Errorf("list is incorrect: %s", root.JsonValue())
+	}
+	list := InitParser(t, "", []byte(`[]`))
+	if _, err = parser.CreateAndReturnNodeAtPointer(list, "/0", parser.NT_BOOL); err != nil || list.JsonValue() != `[false]` {
+		t.Errorf("root list is incorrect: %s %v", list.JsonValue(), err)
+	}
+}
+
+func checkPointer(t *testing.T, root parser.NodeI, pointer, expected string) {
+	n, err := parser.FindPointer(root, pointer)
+	if err != nil {
+		t.Errorf("Pointer '%s' not found: %s", pointer, err)
+		return
+	}
+	if n.String() != expected {
+		t.Errorf("Pointer '%s' should return '%s'. Actual '%s'", pointer, expected, n.String())
+	}
+}
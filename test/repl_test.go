@@ -0,0 +1,143 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/cli"
+)
+
+const replDoc = `{"a": {"b": [1, {"c": "x"}], "a/b": true}, "d": "hi"}`
+
+func TestReplNavigate(t *testing.T) {
+	r, out := newTestRepl(t, replDoc)
+	checkRepl(t, r, out, "ls", "a/ {2}\nd: \"hi\"\n")
+	checkRepl(t, r, out, "cd a/b", "")
+	if r.Pwd() != "/a/b" || r.Prompt() != "/a/b> " || r.Current().GetName() != "b" {
+		t.Errorf("pwd %s prompt %s", r.Pwd(), r.Prompt())
+	}
+	checkRepl(t, r, out, "ls", "0: 1\n1/ {1}\n")
+	checkRepl(t, r, out, "cat 1/c", "\"x\"\n")
+	checkRepl(t, r, out, "ls 0", "NUMBER 1\n")
+	checkRepl(t, r, out, "cd ../a~1b", "error: '/a/a~1b' is not an object or a list\n")
+	checkRepl(t, r, out, "cd ../x", "error: '/a/x' was not found\n")
+	checkRepl(t, r, out, "cd ..", "")
+	checkRepl(t, r, out, "pwd", "/a\n")
+	checkRepl(t, r, out, "cat a~1b", "true\n")
+	checkRepl(t, r, out, "cd", "")
+	checkRepl(t, r, out, "pwd", "/\n")
+	checkRepl(t, r, out, "find b", "/a/b\n/a/a~1b\n")
+	checkRepl(t, r, out, "find $..c", "/a/b/1/c: \"x\"\n")
+	checkRepl(t, r, out, "bad", "error: unknown command 'bad'. Use help for a list of commands\n")
+	if !r.Exec("exit") || r.Exec("") {
+		t.Error("exit should return true")
+	}
+}
+
+func TestReplChange(t *testing.T) {
+	r, out := newTestRepl(t, replDoc)
+	checkRepl(t, r, out, "cd a/b/1", "")
+	checkRepl(t, r, out, "set c 'x y'", "")
+	checkRepl(t, r, out, "set n [1, 2]", "")
+	checkRepl(t, r, out, "set /d 3", "")
+	if r.Prompt() != "/a/b/1 *> " {
+		t.Errorf("prompt %s", r.Prompt())
+	}
+	checkRepl(t, r, out, "cat", "{\n    \"c\": \"x y\",\n    \"n\": [\n        1,\n        2\n    ]\n}\n")
+	checkRepl(t, r, out, "mv n /a", "")
+	checkRepl(t, r, out, "mv /d ../..", "")
+	checkRepl(t, r, out, "cat /", "{\n    \"a\": {\n        \"b\": [\n            1,\n            {\n                \"c\": \"x y\"\n            }\n        ],\n        \"a/b\": true,\n        \"n\": [\n            1,\n            2\n        ],\n        \"d\": 3\n    }\n}\n")
+	checkRepl(t, r, out, "rm /a/b", "")
+	checkRepl(t, r, out, "pwd", "/a\n")
+	checkRepl(t, r, out, "rm /", "error: the root cannot be removed\n")
+	checkRepl(t, r, out, "rm x", "error: patch operation 0 (remove '/a/x') failed: '/a/x' was not found\n")
+	checkRepl(t, r, out, "undo", "")
+	checkRepl(t, r, out, "pwd", "/a\n")
+	checkRepl(t, r, out, "cat b/1/c", "\"x y\"\n")
+	for i := 0; i < 5; i++ {
+		checkRepl(t, r, out, "undo", "")
+	}
+	checkRepl(t, r, out, "undo", "error: there is nothing to undo\n")
+	if r.Prompt() != "/a> " || r.Root().JsonValue() != parseAny(t, replDoc).JsonValue() {
+		t.Errorf("prompt %s root %s", r.Prompt(), r.Root().JsonValue())
+	}
+}
+
+func TestReplUndo(t *testing.T) {
+	r, out := newTestRepl(t, replDoc)
+	for _, v := range []string{"set /a/b/- 5", "set /a/b/3 6", "rm /a/b/0", "rm /a/b", "mv /d /a/b~1", "set /a/a~1b [1]", "mv /a/a~1b /a", "set / 1"} {
+		checkRepl(t, r, out, v, "")
+	}
+	checkRepl(t, r, out, "cat /", "1\n")
+	for i := 0; i < 8; i++ {
+		checkRepl(t, r, out, "undo", "")
+	}
+	if r.Root().JsonValue() != parseAny(t, replDoc).JsonValue() {
+		t.Errorf("undo should restore the document. Actual %s", r.Root().JsonValue())
+	}
+	checkRepl(t, r, out, "undo", "error: there is nothing to undo\n")
+}
+
+func TestReplSave(t *testing.T) {
+	r, out := newTestRepl(t, `{"a": 1}`)
+	checkRepl(t, r, out, "save", "error: usage: save <file>\n")
+	file := filepath.Join(t.TempDir(), "doc.json")
+	checkRepl(t, r, out, "set a 2", "")
+	checkRepl(t, r, out, "save "+file, "saved "+file+"\n")
+	if r.Prompt() != "/> " {
+		t.Errorf("prompt %s", r.Prompt())
+	}
+	dat, _ := os.ReadFile(file)
+	if string(dat) != "{\n    \"a\": 2\n}\n" {
+		t.Errorf("saved %s", string(dat))
+	}
+}
+
+func TestReplComplete(t *testing.T) {
+	r, _ := newTestRepl(t, replDoc)
+	checkComplete(t, r, "c", "cat,cd")
+	checkComplete(t, r, "  s", "save,set")
+	checkComplete(t, r, "cd ", "a/,d")
+	checkComplete(t, r, "cd a/", "a/a~1b,a/b/")
+	checkComplete(t, r, "ls a/b/1/", "a/b/1/c")
+	checkComplete(t, r, "ls x/", "")
+	r.Exec("cd a")
+	checkComplete(t, r, "cat ../", "../a/,../d")
+}
+
+func TestCliRepl(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "doc.json")
+	os.WriteFile(file, []byte(`{"a": [1]}`), 0600)
+	checkCli(t, "cd a\nset - 2\nls\nsave\n", cli.EXIT_OK, "/> /a> /a *> 0: 1\n1: 2\n/a *> saved "+file+"\n/a> \n", "repl", file)
+	dat, _ := os.ReadFile(file)
+	if string(dat) != "{\n    \"a\": [\n        1,\n        2\n    ]\n}\n" {
+		t.Errorf("saved %s", string(dat))
+	}
+	checkCli(t, "set x 1\ncat\nexit\nls\n", cli.EXIT_OK, "/> / *> {\n    \"x\": 1\n}\n/ *> ", "repl")
+	checkCli(t, "", cli.EXIT_ERROR, "", "repl", filepath.Join(t.TempDir(), "missing.json"))
+}
+
+func newTestRepl(t *testing.T, json string) (*cli.Repl, *bytes.Buffer) {
+	var out bytes.Buffer
+	return cli.NewRepl(parseAny(t, json), "", &out), &out
+}
+
+func checkRepl(t *testing.T, r *cli.Repl, out *bytes.Buffer, line, expected string) {
+	t.Helper()
+	out.Reset()
+	r.Exec(line)
+	if out.String() != expected {
+		t.Errorf("%s: output\n%q\nexpected\n%q", line, out.String(), expected)
+	}
+}
+
+func checkComplete(t *testing.T, r *cli.Repl, line, expected string) {
+	t.Helper()
+	actual := strings.Join(r.Complete(line), ",")
+	if actual != expected {
+		t.Errorf("complete '%s' returned '%s' expected '%s'", line, actual, expected)
+	}
+}
@@ -30,8 +30,8 @@ var (
 func TestEncodeStr(t *testing.T) {
 	testEncode(t, "A\\t\\n\\nY", "A\t\n\nY")
 	testEncode(t, "A\\uFFFDZ", "A\x81Z")
-	testEncode(t, "A\\b\\nY", "A\b\n\rY")
-	testEncode(t, "A\\f\\nY", "A\f\r\nY")
+	testEncode(t, "A\\b\\n\\rY", "A\b\n\rY")
+	testEncode(t, "A\\f\\r\\nY", "A\f\r\nY")
 	testEncode(t, "A\\b\\nY", "A\b\nY")
 	testEncode(t, "A\\nD<Z\\u2605Y", "A\nD\x3CZ\u2605Y")
 	testEncode(t, "A\\tD\\fZ\\u2605\\u2605Y", "A\tD\fZ\u2605\u2605Y")
@@ -166,38 +166,23 @@ func failIfNotEqualInt(t *testing.T, i, j uint16) {
 	}
 }
 
-func TestPanicUnquotedString(t *testing.T) {
+func TestErrorUnquotedString(t *testing.T) {
 	s := parser.NewScanner([]byte(`["literal, 1234, true, false]`))
 	s.NextToken()
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Errorf("FAIL. Should throw 'unterminated quoted String'")
-		}
-	}()
-	s.NextToken()
+	_, err := s.NextToken()
+	CheckErr(t, err, "unterminated quoted String")
 }
-func TestPanicUnexpectedEOF(t *testing.T) {
+func TestErrorUnexpectedEOF(t *testing.T) {
 	s := parser.NewScanner([]byte(`[ `))
 	s.NextToken()
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Errorf("FAIL. Should throw 'Unexpected end of input'")
-		}
-	}()
-	s.NextToken()
+	_, err := s.NextToken()
+	CheckErr(t, err, "Unexpected end of input")
 }
-func TestPanicUnrecognisedToken(t *testing.T) {
+func TestErrorUnrecognisedToken(t *testing.T) {
 	s := parser.NewScanner([]byte(`[ bad ]`))
 	s.NextToken()
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Errorf("FAIL. Should throw 'Unrecognised token'")
-		}
-	}()
-	s.NextToken()
+	_, err := s.NextToken()
+	CheckErr(t, err, "Unrecognised token")
 }
 
 func TestTokens(t *testing.T) {
@@ -301,13 +286,11 @@ func TestBasicScanner(t *testing.T) {
 }
 
 func token(t *testing.T, expected string, s *parser.Scanner, tt parser.TokenType) *parser.Token {
-	defer func() {
-		r := recover()
-		if r != nil {
-			t.Errorf("FAIL. Should not panic")
-		}
-	}()
-	toc := s.NextToken()
+	toc, err := s.NextToken()
+	if err != nil {
+		t.Errorf("NextToken error: %s", err.Error())
+		return parser.NewToken("", 0, parser.TT_UNKNOWN)
+	}
 	if !toc.IsType(tt) {
 		t.Errorf("NextToken error: Token [%s]: is not of type %s ", toc, parser.GetTokenTypeName(tt))
 	}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+var inferSamples = []string{
+	`{"id": 1, "name": "Joe", "status": "active", "score": 2.5, "tags": ["a", "b"], "address": {"city": "London"}}`,
+	`{"id": 2, "name": "Sue", "status": "inactive", "score": 7, "tags": [], "email": null}`,
+	`{"id": 30, "name": "Bob", "status": "active", "score": -1, "tags": ["c"], "email": "bob@x.com", "address": {"city": "Leeds", "zip": "LS1"}}`,
+}
+
+func TestInferSchema(t *testing.T) {
+	samples := inferNodes(t, inferSamples...)
+	schema := parser.InferSchema(samples...)
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{` +
+		`"id":{"type":"integer","minimum":1,"maximum":30},` +
+		`"name":{"type":"string"},` +
+		`"status":{"type":"string","enum":["active","inactive"]},` +
+		`"score":{"type":"number","minimum":-1,"maximum":7},` +
+		`"tags":{"type":"array","items":{"type":"string"}},` +
+		`"address":{"type":"object","properties":{"city":{"type":"string"},"zip":{"type":"string"}},"required":["city"]},` +
+		`"email":{"type":["null","string"]}},` +
+		`"required":["id","name","status","score","tags"]}`
+	checkInferred(t, schema, expected)
+	checkInferredValid(t, schema, samples)
+}
+
+func TestInferSchemaOptions(t *testing.T) {
+	samples := inferNodes(t, `{"s": "x", "n": 1}`, `{"s": "x", "n": 2}`)
+	schema := parser.InferSchemaWithOptions(parser.InferOptions{NoRanges: true}, samples...)
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"s":{"type":"string"},"n":{"type":"integer"}},"required":["s","n"]}`
+	checkInferred(t, schema, expected)
+	// Enum with null and too many values
+	samples = inferNodes(t, `["a", null, "a", "b"]`, `["c", "d", "e", "f", "g", "g"]`)
+	schema = parser.InferSchemaWithOptions(parser.InferOptions{EnumMaxValues: 2}, samples[0])
+	expected = `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"array","items":{"type":["string","null"],"enum":["a","b",null]}}`
+	checkInferred(t, schema, expected)
+	schema = parser.InferSchemaWithOptions(parser.InferOptions{EnumMaxValues: 2}, samples...)
+	expected = `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"array","items":{"type":["string","null"]}}`
+	checkInferred(t, schema, expected)
+	checkInferredValid(t, schema, samples)
+	// Mixed types
+	samples = inferNodes(t, `1`, `2.5`, `true`, `{"a": 1}`)
+	schema = parser.InferSchema(samples...)
+	expected = `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":["number","boolean","object"],"properties":{"a":{"type":"integer","minimum":1,"maximum":1}},"required":["a"],"minimum":1,"maximum":2.5}`
+	checkInferred(t, schema, expected)
+	checkInferredValid(t, schema, samples)
+}
+
+func inferNodes(t *testing.T, json ...string) []parser.NodeI {
+	nodes := make([]parser.NodeI, 0)
+	for _, j := range json {
+		nodes = append(nodes, parseAny(t, j))
+	}
+	return nodes
+}
+
+func checkInferred(t *testing.T, schema *parser.JsonObject, expected string) {
+	if exp := parseAny(t, expected).JsonValue(); schema.JsonValue() != exp {
+		t.Errorf("inferred schema\n%s\nexpected\n%s", schema.JsonValue(), exp)
+	}
+}
+
+func checkInferredValid(t *testing.T, schema *parser.JsonObject, samples []parser.NodeI) {
+	s, err := parser.NewSchema(schema)
+	if err != nil {
+		t.Fatalf("inferred schema did not compile: %s", err)
+	}
+	for _, n := range samples {
+		if v := s.Validate(n); len(v) != 0 {
+			t.Errorf("sample %s is not valid for the inferred schema: %s", n.JsonValue(), v)
+		}
+	}
+}
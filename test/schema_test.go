@@ -0,0 +1,176 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const personSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["name", "age"],
+  "properties": {
+    "name": {"type": "string", "minLength": 2, "maxLength": 10, "pattern": "^[A-Z]"},
+    "age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+    "email": {"type": ["string", "null"]},
+    "gender": {"enum": ["male", "female", "other"]},
+    "version": {"const": 1},
+    "address": {"$ref": "#/$defs/address"},
+    "tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 3},
+    "score": {"type": "number", "maximum": 10.5, "exclusiveMinimum": 0}
+  },
+  "additionalProperties": false,
+  "$defs": {
+    "address": {
+      "type": "object",
+      "properties": {"city": {"type": "string"}, "zip": {"type": "string"}},
+      "required": ["city"],
+      "additionalProperties": {"type": "string"},
+      "maxProperties": 3
+    }
+  }
+}`
+
+func TestSchemaValid(t *testing.T) {
+	schema := parseSchema(t, personSchema)
+	for _, doc := range []string{
+		`{"name": "Joe", "age": 28}`,
+		`{"name": "Joe", "age": 28.0, "email": null, "gender": "male", "version": 1.0, "tags": ["a"], "score": 10.5}`,
+		`{"name": "Joe", "age": 0, "address": {"city": "London", "zip": "N1", "country": "UK"}}`,
+	} {
+		if v := schema.Validate(parseAny(t, doc)); len(v) != 0 {
+			t.Errorf("%s should be valid. Violations %s", doc, v)
+		}
+	}
+}
+
+func TestSchemaViolations(t *testing.T) {
+	schema := parseSchema(t, personSchema)
+	checkViolations(t, schema, `{"name": "joe", "age": -1, "extra": 1}`,
+		`#/name: value "joe" does not match the pattern '^[A-Z]' (#/properties/name/pattern)`,
+		`#/age: value -1 is less than the minimum 0 (#/properties/age/minimum)`,
+		`#/extra: property 'extra' is not allowed (#/additionalProperties)`)
+	checkViolations(t, schema, `{"name": "J", "age": 150, "gender": "x", "version": 2}`,
+		`#/name: length 1 is less than 2 (#/properties/name/minLength)`,
+		`#/age: value 150 must be less than 150 (#/properties/age/exclusiveMaximum)`,
+		`#/gender: value "x" is not one of ["male","female","other"] (#/properties/gender/enum)`,
+		`#/version: value 2 must be 1 (#/properties/version/const)`)
+	checkViolations(t, schema, `{"name": 1, "age": 1.5, "email": true, "tags": [], "score": 0}`,
+		`#/name: expected type string. Found integer (#/properties/name/type)`,
+		`#/age: expected type integer. Found number (#/properties/age/type)`,
+		`#/email: expected type string or null. Found boolean (#/properties/email/type)`,
+		`#/tags: number of items 0 is less than 1 (#/properties/tags/minItems)`,
+		`#/score: value 0 must be more than 0 (#/properties/score/exclusiveMinimum)`)
+	checkViolations(t, schema, `{"age": 1, "tags": ["a", 2, "c", "d"], "score": 11, "address": {"zip": 1, "a": "a", "b": "b", "c": "c"}}`,
+		`#: required property 'name' is missing (#/required)`,
+		`#/address/zip: expected type string. Found integer (#/$defs/address/properties/zip/type)`,
+		`#/address: required property 'city' is missing (#/$defs/address/required)`,
+		`#/address: number of properties 4 is more than 3 (#/$defs/address/maxProperties)`,
+		`#/tags/1: expected type string. Found integer (#/properties/tags/items/type)`,
+		`#/tags: number of items 4 is more than 3 (#/properties/tags/maxItems)`,
+		`#/score: value 11 is more than the maximum 10.5 (#/properties/score/maximum)`)
+	checkViolations(t, schema, `[1]`, `#: expected type object. Found array (#/type)`)
+}
+
+func TestSchemaViolationsAddressProperties(t *testing.T) {
+	schema := parseSchema(t, personSchema)
+	v := schema.Validate(parseAny(t, `{"name": "Joe", "age": 1, "address": {"city": "x", "n": 1}}`))
+	if len(v) != 1 || v[0].Path.Pointer() != "/address/n" || v[0].SchemaPath != "#/$defs/address/additionalProperties/type" || v[0].Keyword != "type" {
+		t.Errorf("Violation is incorrect. Actual %s", v)
+	}
+}
+
+func TestSchemaCombinations(t *testing.T) {
+	schema := parseSchema(t, `{"anyOf": [{"type": "string"}, {"type": "number", "minimum": 5}],
+	  "oneOf": [{"type": "integer"}, {"minimum": 10}],
+	  "allOf": [{"not_a_keyword": 1}, {"maximum": 100}]}`)
+	checkViolations(t, schema, `7`)
+	checkViolations(t, schema, `"a"`)
+	checkViolations(t, schema, `true`, `#: value does not match any of the schemas (#/anyOf)`)
+	checkViolations(t, schema, `1`, `#: value does not match any of the schemas (#/anyOf)`)
+	checkViolations(t, schema, `12`, `#: value matches 2 of the schemas. It must match exactly one (#/oneOf)`)
+	checkViolations(t, schema, `101.5`, `#: value 101.5 is more than the maximum 100 (#/allOf/1/maximum)`)
+}
+
+func TestSchemaBooleanAndRecursion(t *testing.T) {
+	checkViolations(t, parseSchema(t, `true`), `{"a": 1}`)
+	checkViolations(t, parseSchema(t, `false`), `{"a": 1}`, `#: no value is allowed (#)`)
+	tree := parseSchema(t, `{"$defs": {"node": {"type": "object", "properties": {"v": {"type": "integer"}, "kids": {"type": "array", "items": {"$ref": "#/$defs/node"}}}}}, "$ref": "#/$defs/node"}`)
+	checkViolations(t, tree, `{"v": 1, "kids": [{"v": 2, "kids": []}, {"v": "x"}]}`,
+		`#/kids/1/v: expected type integer. Found string (#/$defs/node/properties/v/type)`)
+	loop := parseSchema(t, `{"$ref": "#", "type": "string"}`)
+	checkViolations(t, loop, `1`, `#: expected type string. Found integer (#/type)`)
+}
+
+func TestSchemaErrors(t *testing.T) {
+	for schema, msg := range map[string]string{
+		`[1]`:                                    "invalid schema at '#'. A schema must be an object or a boolean",
+		`{"type": "int"}`:                        "invalid schema at '#/type'. must be one of null, boolean",
+		`{"properties": {"a": 1}}`:               "invalid schema at '#/properties/a'",
+		`{"$ref": "#/$defs/x"}`:                  "invalid schema at '#/$ref'. cannot resolve $ref '#/$defs/x'",
+		`{"$ref": "other.json#/a"}`:              "only a $ref within the schema is supported",
+		`{"pattern": "[a"}`:                      "invalid schema at '#/pattern'",
+		`{"minLength": -1}`:                      "must be a non-negative integer",
+		`{"maxItems": 1.5}`:                      "must be a non-negative integer",
+		`{"anyOf": []}`:                          "must be a list of schemas",
+		`{"required": [1]}`:                      "must be a list of strings",
+		`{"minimum": "1"}`:                       "must be a number",
+		`{"items": {"additionalProperties": 1}}`: "invalid schema at '#/items/additionalProperties'",
+		`{"$ref": "#/x", "x": {"type": 5}}`:      "invalid schema at '#/x/type'",
+		`{"$ref": "#/x", "x": 5}`:                "invalid schema at '#/x'. A schema must be an object or a boolean",
+	} {
+		_, err := parser.ParseSchema([]byte(schema))
+		CheckErr(t, err, msg)
+	}
+	s := parseSchema(t, `{"$defs": {"a b": {"type": "string"}}, "$ref": "#/$defs/a%20b"}`)
+	checkViolations(t, s, `1`, `#: expected type string. Found integer (#/$defs/a b/type)`)
+	// A $ref target outside the keywords that contain schemas
+	s = parseSchema(t, `{"$ref": "#/x", "x": {"pattern": "a", "$ref": "#/x"}}`)
+	checkViolations(t, s, `"a"`)
+	checkViolations(t, s, `"b"`, `#: value "b" does not match the pattern 'a' (#/x/pattern)`)
+
+	// A schema changed after it was compiled is reported under the keyword that holds it
+	node := parseAny(t, `{"properties": {"a": {"type": "string"}}, "items": {"$ref": "#/$defs/x"}, "$defs": {"x": {}}}`)
+	s, err := parser.NewSchema(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := node.(*parser.JsonObject).GetNodeWithName("properties").(*parser.JsonObject)
+	props.Remove(props.GetNodeWithName("a"))
+	props.Add(parser.NewJsonNumber("a", 1))
+	defs := node.(*parser.JsonObject).GetNodeWithName("$defs").(*parser.JsonObject)
+	defs.Remove(defs.GetNodeWithName("x"))
+	defs.Add(parser.NewJsonString("x", "y"))
+	violations := s.Validate(parseAny(t, `{"a": 1}`))
+	if len(violations) != 1 || violations[0].Keyword != "properties" || violations[0].SchemaPath != "#/properties/a" {
+		t.Errorf("expected an invalid schema under properties. Actual %v", violations)
+	}
+	violations = s.Validate(parseAny(t, `[1]`))
+	if len(violations) != 1 || violations[0].Keyword != "$ref" || violations[0].SchemaPath != "#/$defs/x" {
+		t.Errorf("expected an invalid schema under $ref. Actual %v", violations)
+	}
+}
+
+func parseSchema(t *testing.T, json string) *parser.Schema {
+	s, err := parser.ParseSchema([]byte(json))
+	if err != nil {
+		t.Fatalf("Schema failed: %s", err)
+	}
+	return s
+}
+
+func checkViolations(t *testing.T, schema *parser.Schema, doc string, expected ...string) {
+	v := schema.Validate(parseAny(t, doc))
+	actual := make([]string, len(v))
+	for i, x := range v {
+		actual[i] = x.String()
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Violations for %s are incorrect.\nExpected:\n%s\nActual:\n%s", doc, strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+	if schema.IsValid(parseAny(t, doc)) != (len(expected) == 0) {
+		t.Errorf("IsValid for %s is incorrect", doc)
+	}
+}
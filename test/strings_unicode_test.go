@@ -0,0 +1,180 @@
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+func TestDecodeSurrogatePairs(t *testing.T) {
+	testDecode(t, `"\ud83d\ude00"`, "\U0001F600")
+	testDecode(t, `"A\uD83D\uDE00Z"`, "A\U0001F600Z")
+	testDecode(t, `"\u00e9\u2605\ud834\udd1e"`, "\u00e9\u2605\U0001D11E")
+	testDecode(t, "\"\U0001F600 raw\"", "\U0001F600 raw")
+	testDecode(t, `"\ud83d"`, "\uFFFD")
+	testDecode(t, `"\ude00A"`, "\uFFFDA")
+	testDecode(t, `"\ud83dA"`, "\uFFFDA")
+	testDecode(t, `"\ud83d\u0041"`, "\uFFFDA")
+	testDecode(t, `"\ud83d\ud83d\ude00"`, "\uFFFD\U0001F600")
+	testDecode(t, `"\/\"\\"`, "/\"\\")
+}
+
+func TestDecodeSurrogatePairsReader(t *testing.T) {
+	n, err := parser.ParseReader(iotest.OneByteReader(strings.NewReader("[\"\U0001F600\", \"\\ud83dA\"]")))
+	if err != nil {
+		t.Errorf("ParseReader failed: %s", err.Error())
+		return
+	}
+	if n.(*parser.JsonList).GetNodeAt(0).String() != "\U0001F600" || n.(*parser.JsonList).GetNodeAt(1).String() != "\uFFFDA" {
+		t.Errorf("Surrogate pairs not decoded. Actual %s", n.JsonValue())
+	}
+}
+
+func testDecode(t *testing.T, in, expected string) {
+	n, err := parser.Parse([]byte(in))
+	if err != nil {
+		t.Errorf("Parse of %s failed: %s", in, err.Error())
+		return
+	}
+	if n.String() != expected {
+		t.Errorf("Parse of %s should be '%s'. Actual '%s'", in, bytesString(expected), bytesString(n.String()))
+	}
+}
+
+func TestDecodeInvalidEscape(t *testing.T) {
+	for _, in := range []string{`"\q"`, `"\u12"`, `"\u12G4"`, `"\ud83d\u12"`, `"abc\`} {
+		_, err := parser.Parse([]byte(in))
+		if err == nil {
+			t.Errorf("Parse of %s should fail", in)
+		}
+	}
+	_, err := parser.Parse([]byte(`["ok", "\a"]`))
+	CheckErr(t, err, "invalid escape '\\a'")
+}
+
+func TestDecodeValidateUTF8(t *testing.T) {
+	bad := []byte("{\"s\": \"A\x81Z\"}")
+	n, err := parser.Parse(bad)
+	if err != nil {
+		t.Errorf("Invalid UTF-8 should be accepted by default: %s", err.Error())
+	} else {
+		CheckFindNode(t, n, "s", "A")
+	}
+	_, err = parser.ParseWithOptions(bad, parser.ParseOptions{ValidateUTF8: true})
+	CheckErr(t, err, "invalid UTF-8")
+	_, err = parser.ParseReaderWithOptions(strings.NewReader(string(bad)), parser.ParseOptions{ValidateUTF8: true})
+	CheckErr(t, err, "invalid UTF-8")
+	_, err = parser.ParseWithOptions([]byte("{\"s\": \"\u00e9\U0001F600\U0001F600\"}"), parser.ParseOptions{ValidateUTF8: true})
+	if err != nil {
+		t.Errorf("Valid UTF-8 should be accepted: %s", err.Error())
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	for _, options := range []parser.ParseOptions{{Strict: true}, {ValidateUTF8: true}} {
+		_, err := parser.ParseWithOptions([]byte(`{"s": "\x41"}`), options)
+		CheckErr(t, err, "invalid escape '\\x' in quoted String")
+		_, err = parser.ParseWithOptions([]byte("{\"s\": \"a\tb\"}"), options)
+		CheckErr(t, err, "control character 0x09 must be escaped in quoted String")
+		_, err = parser.ParseReaderWithOptions(strings.NewReader("[\"a\nb\"]"), options)
+		CheckErr(t, err, "control character 0x0A must be escaped in quoted String")
+		n, err := parser.ParseWithOptions([]byte(`{"s": "a\tb\u0001"}`), options)
+		if err != nil {
+			t.Errorf("Escaped control characters should be accepted: %s", err.Error())
+		} else {
+			checkStringValue(t, n, "a\tb\u0001")
+		}
+	}
+	// Accepted by default
+	n, err := parser.Parse([]byte("{\"s\": \"\\x41\tB\"}"))
+	if err != nil {
+		t.Errorf("\\x and a raw tab should be accepted by default: %s", err.Error())
+	} else {
+		checkStringValue(t, n, "A\tB")
+	}
+}
+
+func checkStringValue(t *testing.T, n parser.NodeI, expected string) {
+	t.Helper()
+	if s := n.(*parser.JsonObject).GetNodeWithName("s").String(); s != expected {
+		t.Errorf("String should be %q. Actual %q", expected, s)
+	}
+}
+
+func TestEncodeASCII(t *testing.T) {
+	testEncodeWith(t, parser.ENCODE_ASCII, "\U0001F600", `\uD83D\uDE00`)
+	testEncodeWith(t, parser.ENCODE_ASCII, "\u00e9\u2605", `\u00E9\u2605`)
+	testEncodeWith(t, parser.ENCODE_ASCII, "\x01\x1f\x7f", `\u0001\u001F`+"\x7f")
+	testEncodeWith(t, parser.ENCODE_ASCII, "A\xffZ", `A\uFFFDZ`)
+	testEncodeWith(t, parser.ENCODE_UTF8, "\U0001F600\u00e9\u2605", "\U0001F600\u00e9\u2605")
+	testEncodeWith(t, parser.ENCODE_UTF8, "\"\\\r\n\t\x00", `\"\\\r\n\t\u0000`)
+	testEncodeWith(t, parser.ENCODE_UTF8, "A\xffZ", "A\uFFFDZ")
+}
+
+func testEncodeWith(t *testing.T, enc parser.StringEncoding, in, expected string) {
+	s := parser.EncodeQuotedStringWith(in, enc)
+	if s != expected {
+		t.Errorf("Encode of '%s' should be '%s'. Actual '%s'", bytesString(in), bytesString(expected), bytesString(s))
+	}
+	var decoded string
+	err := json.Unmarshal([]byte("\""+s+"\""), &decoded)
+	if err != nil {
+		t.Errorf("encoding/json rejected '%s': %s", s, err.Error())
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	src := "Tab\tCR\rNL\n Quote\" BS\\ \u00e9 \u2605 \U0001F600 \x01 end"
+	for _, enc := range []parser.StringEncoding{parser.ENCODE_ASCII, parser.ENCODE_UTF8} {
+		parser.SetStringEncoding(enc)
+		n := parser.NewJsonObject("")
+		n.Add(parser.NewJsonString("s", src))
+		js := n.JsonValue()
+		if enc == parser.ENCODE_UTF8 && !strings.Contains(js, "\U0001F600") {
+			t.Errorf("ENCODE_UTF8 should write raw UTF-8. Actual %s", js)
+		}
+		if enc == parser.ENCODE_ASCII && strings.Contains(js, "\U0001F600") {
+			t.Errorf("ENCODE_ASCII should not write raw UTF-8. Actual %s", js)
+		}
+		var std map[string]string
+		err := json.Unmarshal([]byte(js), &std)
+		if err != nil || std["s"] != src {
+			t.Errorf("encoding/json should decode '%s' to the source. %v", js, err)
+		}
+		p, err := parser.Parse([]byte(js))
+		if err != nil {
+			t.Errorf("Parse failed: %s", err.Error())
+			continue
+		}
+		assertExact(t, "01", p, "s", src)
+	}
+	parser.SetStringEncoding(parser.ENCODE_ASCII)
+}
+
+func TestEncodeNamesRoundTrip(t *testing.T) {
+	src := `{"a\"b": 1, "tab\tk": {"é\\": [1, {"😀": "x"}]}}`
+	for _, enc := range []parser.StringEncoding{parser.ENCODE_ASCII, parser.ENCODE_UTF8} {
+		parser.SetStringEncoding(enc)
+		n := parseAny(t, src)
+		js := n.JsonValue()
+		if enc == parser.ENCODE_ASCII && (strings.Contains(js, "é") || !strings.Contains(js, `\uD83D\uDE00`)) {
+			t.Errorf("ENCODE_ASCII should escape names. Actual %s", js)
+		}
+		var std map[string]interface{}
+		if err := json.Unmarshal([]byte(js), &std); err != nil {
+			t.Errorf("encoding/json rejected '%s': %s", js, err.Error())
+		}
+		p, err := parser.Parse([]byte(js))
+		if err != nil {
+			t.Errorf("Parse failed: %s", err.Error())
+			continue
+		}
+		if !parser.EqualValues(n, p) || p.(*parser.JsonObject).GetNodeWithName("a\"b") == nil {
+			t.Errorf("names did not round trip. Actual %s", p.JsonValue())
+		}
+	}
+	parser.SetStringEncoding(parser.ENCODE_ASCII)
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const tomlConfig = `# A TOML document
+title = "TOML Example"
+"quoted key" = 'C:\temp'
+site."google.com" = true
+ints = [1_000, +2, -3, 0xDEAD_beef, 0o17, 0b101]
+floats = [1.5, -2e3, 6.626e-34, +1.0]
+dates = [1979-05-27T07:32:00-08:00, 1979-05-27 07:32:00, 1979-05-27, 07:32:00.99]
+multi = """
+Roses are red \
+   Violets are blue
+"quoted" ""end"""""
+literal = '''
+raw \n ''text'''''
+mixed = [1, "a", [2], {x = 1}]
+
+[owner]
+name = "Tom"
+
+[database]
+enabled = true
+ports = [ 8000, 8001,
+  8002, # comment
+]
+temp = { cpu = 79.5, nested.deep = "x" }
+
+[servers.alpha]
+ip = "10.0.0.1"
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+
+[[products]]
+name = "Nail"
+[products.detail]
+size = 1
+[[products.parts]]
+id = 1
+`
+
+func TestTOMLParse(t *testing.T) {
+	checkTOML(t, tomlConfig, `{"title": "TOML Example", "quoted key": "C:\\temp", "site": {"google.com": true},
+		"ints": [1000, 2, -3, 3735928559, 15, 5], "floats": [1.5, -2e3, 6.626e-34, 1.0],
+		"dates": ["1979-05-27T07:32:00-08:00", "1979-05-27 07:32:00", "1979-05-27", "07:32:00.99"],
+		"multi": "Roses are red Violets are blue\n\"quoted\" \"\"end\"\"", "literal": "raw \\n ''text''",
+		"mixed": [1, "a", [2], {"x": 1}], "owner": {"name": "Tom"},
+		"database": {"enabled": true, "ports": [8000, 8001, 8002], "temp": {"cpu": 79.5, "nested": {"deep": "x"}}},
+		"servers": {"alpha": {"ip": "10.0.0.1"}},
+		"products": [{"name": "Hammer"}, {}, {"name": "Nail", "detail": {"size": 1}, "parts": [{"id": 1}]}]}`)
+	checkTOML(t, "", `{}`)
+	checkTOML(t, "a.b.c = 1\na.b.d = 2\n[a.x]\ny = \"\\u00e9\\U0001F600\"", `{"a": {"b": {"c": 1, "d": 2}, "x": {"y": "é😀"}}}`)
+	checkTOML(t, "[a.b]\nc = 1\n[a]\nd = 2", `{"a": {"b": {"c": 1}, "d": 2}}`)
+}
+
+func TestTOMLParseErrors(t *testing.T) {
+	checkTOMLError(t, "a = 1\na = 2", 2, 1, "'a' is already defined")
+	checkTOMLError(t, "[a]\n[a]", 2, 1, "the table [a] is already defined")
+	checkTOMLError(t, "a = {b = 1}\n[a.c]", 2, 1, "the inline table 'a' cannot be changed")
+	checkTOMLError(t, "a = {b = 1}\na.c = 2", 2, 1, "'a' is already defined")
+	checkTOMLError(t, "x = [1]\n[[x]]", 2, 1, "'x' is already defined")
+	checkTOMLError(t, "a.b = 1\n[a.b]", 2, 1, "the table [a.b] is already defined")
+	checkTOMLError(t, "[a.b.c]\n[a]\nb.c.d = 1", 3, 1, "'b' is already defined")
+	checkTOMLError(t, "a = 1\n[a.b]", 2, 1, "'a' is already defined as a value")
+	checkTOMLError(t, "s = \"x\ny\"", 1, 7, "a string cannot contain a line break. Use a multi line string")
+	checkTOMLError(t, "s = 'x", 1, 5, "the string is not closed")
+	checkTOMLError(t, "n = inf", 1, 5, "the number 'inf' cannot be held in a JsonNumber")
+	checkTOMLError(t, "n = 9223372036854775808", 1, 5, "the integer '9223372036854775808' is out of range")
+	checkTOMLError(t, "x = 01", 1, 5, "invalid value '01'")
+	checkTOMLError(t, "x = ", 1, 5, "expected a value")
+	checkTOMLError(t, "t = {a = 1,}", 1, 12, "expected a key")
+	checkTOMLError(t, "t = {a = 1\n}", 1, 11, "an inline table must be closed on the same line")
+	checkTOMLError(t, "l = [1 2]", 1, 8, "expected ',' or ']'")
+	checkTOMLError(t, "l = [1,", 1, 5, "the array is not closed")
+	checkTOMLError(t, "s = \"\\q\"", 1, 6, "invalid escape '\\q'")
+	checkTOMLError(t, "s = \"\\uD800\"", 1, 6, "invalid escape '\\uD800'")
+	checkTOMLError(t, "[a]\nb = 1 c = 2", 2, 7, "expected the end of the line")
+	checkTOMLError(t, "a 1", 1, 3, "expected '=' after a key")
+	checkTOMLError(t, "[a", 1, 3, "expected ']'")
+}
+
+func TestTOMLValue(t *testing.T) {
+	checkTOMLValue(t, `{"title": "x", "a b": "line\n\"q\"", "n": [1, 2.5], "e": [], "site": {"google.com": true},
+		"a": {"b": {"c": 1}}, "empty": {}, "list": [[1], ["a"], [{"x": 1, "y": {}}]],
+		"products": [{"name": "Hammer", "parts": [{"id": 1}]}, {}]}`, `title = "x"
+"a b" = "line\n\"q\""
+n = [1, 2.5]
+e = []
+list = [[1], ["a"], [{ x = 1, y = {} }]]
+
+[site]
+"google.com" = true
+
+[a.b]
+c = 1
+
+[empty]
+
+[[products]]
+name = "Hammer"
+
+[[products.parts]]
+id = 1
+
+[[products]]
+`)
+	checkTOMLValueError(t, `[1]`, "", "the root must be an OBJECT not a LIST at the root")
+	checkTOMLValueError(t, `{"a": {"b": [1, null]}}`, "/a/b/1", "a null cannot be written as toml at '/a/b/1'")
+	checkTOMLValueError(t, `{"a": [{"b": [1, "x"]}]}`, "/a/0/b", "a list with a NUMBER and a STRING cannot be written as toml at '/a/0/b'")
+	checkTOMLValueError(t, `{"a": [{"b": 1}, 2]}`, "/a", "a list with a OBJECT and a NUMBER cannot be written as toml at '/a'")
+	checkTOMLValueError(t, `{"n": 12345678901234567890}`, "/n", "the integer 12345678901234567890 is too large for toml at '/n'")
+}
+
+func checkTOML(t *testing.T, toml, expected string) {
+	t.Helper()
+	node, err := parser.ParseTOML([]byte(toml))
+	if err != nil {
+		t.Errorf("failed to parse %q: %s", toml, err)
+		return
+	}
+	if node.JsonValue() != parseAny(t, expected).JsonValue() {
+		t.Errorf("toml %q\nparsed %s\nexpected %s", toml, node.JsonValue(), parseAny(t, expected).JsonValue())
+	}
+}
+
+func checkTOMLError(t *testing.T, toml string, line, column int, msg string) {
+	t.Helper()
+	_, err := parser.ParseTOML([]byte(toml))
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("toml %q expected a ParseError. Got %v", toml, err)
+		return
+	}
+	if pe.Line != line || pe.Column != column || pe.Msg != msg {
+		t.Errorf("toml %q error %d:%d %q expected %d:%d %q", toml, pe.Line, pe.Column, pe.Msg, line, column, msg)
+	}
+}
+
+func checkTOMLValue(t *testing.T, json, expected string) {
+	t.Helper()
+	node := parseAny(t, json)
+	toml, err := parser.TOMLValue(node)
+	if err != nil {
+		t.Errorf("failed to write %s: %s", json, err)
+		return
+	}
+	if toml != expected {
+		t.Errorf("toml\n%s\nexpected\n%s", toml, expected)
+	}
+	back, err := parser.ParseTOML([]byte(toml))
+	if err != nil || !parser.EqualValues(back, node) {
+		t.Errorf("toml did not read back. %v %v", back, err)
+	}
+}
+
+func checkTOMLValueError(t *testing.T, json, pointer, msg string) {
+	t.Helper()
+	_, err := parser.TOMLValue(parseAny(t, json))
+	var te *parser.TOMLError
+	if !errors.As(err, &te) {
+		t.Errorf("%s expected a TOMLError. Got %v", json, err)
+		return
+	}
+	if te.Path.Pointer() != pointer || te.Error() != msg {
+		t.Errorf("%s error %s %q expected %s %q", json, te.Path.Pointer(), te.Error(), pointer, msg)
+	}
+}
@@ -117,3 +117,28 @@ func TestUpdate(t *testing.T) {
 	}
 
 }
+
+func TestListInsertAtRemoveAt(t *testing.T) {
+	l := parser.NewJsonList("")
+	l.Add(parser.NewJsonNumber("", 1))
+	if err := l.InsertAt(0, parser.NewJsonNumber("", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.InsertAt(2, parser.NewJsonNumber("", 2)); err != nil {
+		t.Fatal(err)
+	}
+	CheckErr(t, l.InsertAt(4, parser.NewJsonNumber("", 4)), "index out of bounds. Range: 0..3 Actual:4")
+	CheckErr(t, l.InsertAt(0, l.GetNodeAt(1)), "already has a parent")
+	if l.JsonValue() != "[0,1,2]" {
+		t.Errorf("InsertAt failed. Actual %s", l.JsonValue())
+	}
+	n, err := l.RemoveAt(1)
+	if err != nil || n.String() != "1" || n.GetParent() != nil {
+		t.Errorf("RemoveAt should return the detached node 1")
+	}
+	_, err = l.RemoveAt(2)
+	CheckErr(t, err, "index out of bounds. Range: 0..1 Actual:2")
+	if l.JsonValue() != "[0,2]" {
+		t.Errorf("RemoveAt failed. Actual %s", l.JsonValue())
+	}
+}
@@ -154,7 +154,7 @@ func InitParserFromFile(t *testing.T, fileName string) parser.NodeC {
 		t.Errorf("Failed to parse file %s. Error %s\n", fileName, err.Error())
 		return nil
 	}
-	return node
+	return node.(parser.NodeC)
 }
 
 func InitParser(t *testing.T, sourceName string, dat []byte) parser.NodeC {
@@ -163,7 +163,11 @@ func InitParser(t *testing.T, sourceName string, dat []byte) parser.NodeC {
 		t.Errorf("Failed to parse source %s. Error %s\n", sourceName, err.Error())
 		return nil
 	}
-	return node
+	if !node.IsContainer() {
+		t.Errorf("Failed to parse source %s. Root node is not a container\n", sourceName)
+		return nil
+	}
+	return node.(parser.NodeC)
 }
 
 func CheckErr(t *testing.T, err error, cont string) {
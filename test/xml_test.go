@@ -0,0 +1,168 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const xmlSoap = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- A soap message -->
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns="urn:orders">
+  <soap:Body>
+    <order id="1" xml:lang="en">
+      <item sku="A1">Hammer</item>
+      <item sku="B2"><![CDATA[Nails & <screws>]]></item>
+      <note>  spaced  </note>
+      <empty/>
+      Some text
+    </order>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestXMLParse(t *testing.T) {
+	checkXML(t, xmlSoap, parser.XMLOptions{}, `{"soap:Envelope": {
+		"@xmlns:soap": "http://www.w3.org/2003/05/soap-envelope", "@xmlns": "urn:orders",
+		"soap:Body": {"order": {"@id": "1", "@xml:lang": "en", "#text": "Some text",
+			"item": [{"@sku": "A1", "#text": "Hammer"}, {"@sku": "B2", "#text": "Nails & <screws>"}],
+			"note": "  spaced  ", "empty": null}}}}`)
+	checkXML(t, xmlSoap, parser.XMLOptions{Namespaces: parser.XML_NS_LOCAL, AttrPrefix: "-", TextKey: "_", ListElements: []string{"note"}}, `{"Envelope": {
+		"Body": {"order": {"-id": "1", "-lang": "en", "_": "Some text",
+			"item": [{"-sku": "A1", "_": "Hammer"}, {"-sku": "B2", "_": "Nails & <screws>"}],
+			"note": ["  spaced  "], "empty": null}}}}`)
+	checkXML(t, `<s:a xmlns:s="urn:s"><b xmlns="urn:b">x</b></s:a>`, parser.XMLOptions{Namespaces: parser.XML_NS_URI}, `{"{urn:s}a": {"{urn:b}b": "x"}}`)
+	checkXML(t, `<s:a xmlns:s="urn:s"><s:b>x</s:b></s:a>`, parser.XMLOptions{Prefixes: map[string]string{"urn:s": "ns"}}, `{"ns:a": {"@xmlns:ns": "urn:s", "ns:b": "x"}}`)
+	checkXML(t, `<a><b>1</b><c/><b>2</b></a>`, parser.XMLOptions{}, `{"a": {"b": ["1", "2"], "c": null}}`)
+
+	checkXMLError(t, "", 1, 1, "no xml element found", "|")
+	checkXMLError(t, "<a>\n<b></a>", 2, 8, "element <b> closed by </a>", "<a>\n<b></a>|")
+	checkXMLError(t, "<a></a><b/>", 1, 12, "only one root element is allowed", "<a></a><b/>|")
+	checkXMLError(t, "<a>", 1, 4, "unexpected EOF", "<a>|")
+	checkXMLError(t, "<a>\n  <b x=1/>\n</a>", 2, 9, "unquoted or missing attribute value in element", "<a>\n  <b x=1|/>\n</a>")
+}
+
+func TestXMLElements(t *testing.T) {
+	feed := `<rss><channel><title>News</title>
+		<item><title>One</title></item>
+		<item><title>Two</title><item>inner</item></item>
+		<item><title>Three</title></item>
+	</channel></rss>`
+	titles := make([]string, 0)
+	err := parser.ParseXMLElements(strings.NewReader(feed), "item", parser.XMLOptions{}, func(node parser.NodeI) error {
+		titles = append(titles, node.(*parser.JsonObject).GetNodeWithName("title").String())
+		return nil
+	})
+	if err != nil || strings.Join(titles, ",") != "One,Two,Three" {
+		t.Errorf("items %v error %v", titles, err)
+	}
+	stop := errors.New("stop")
+	count := 0
+	err = parser.ParseXMLElements(strings.NewReader(feed), "item", parser.XMLOptions{}, func(node parser.NodeI) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("expected to stop after one item. count %d error %v", count, err)
+	}
+	err = parser.ParseXMLElements(strings.NewReader("<a><item></a>"), "item", parser.XMLOptions{}, func(node parser.NodeI) error { return nil })
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("expected a ParseError. Got %v", err)
+	}
+}
+
+func TestXMLValue(t *testing.T) {
+	checkXMLValue(t, `{"a": {"@id": 1, "@x": null, "#text": "x < y", "b": [true, {"c": null}], "n": 1.5}}`, parser.XMLOptions{},
+		`<a id="1">x &lt; y<b>true</b><b><c></c></b><n>1.5</n></a>`)
+	checkXMLValue(t, `{"soap:Envelope": {"@xmlns:soap": "urn:s", "soap:Body": "x"}}`, parser.XMLOptions{Indent: 2},
+		"<soap:Envelope xmlns:soap=\"urn:s\">\n  <soap:Body>x</soap:Body>\n</soap:Envelope>")
+	checkXMLValue(t, `{"{urn:s}a": "x"}`, parser.XMLOptions{}, `<a xmlns="urn:s">x</a>`)
+	checkXMLValue(t, `{"a": {"@xmlns": "urn:a", "b": "x"}}`, parser.XMLOptions{}, `<a xmlns="urn:a"><b>x</b></a>`)
+	checkXMLValue(t, `{"a": 1, "b": "2"}`, parser.XMLOptions{RootName: "doc"}, `<doc><a>1</a><b>2</b></doc>`)
+	checkXMLValue(t, `"text"`, parser.XMLOptions{}, `<root>text</root>`)
+
+	checkXMLValueError(t, `[1]`, "a list must be the value of a key to be written as xml")
+	checkXMLValueError(t, `{"a": {"2fa": 1}}`, "'2fa' is not a valid xml name at '/a/2fa'")
+	checkXMLValueError(t, `{"a": {"b": [[1]]}}`, "a list in a list cannot be written as xml at '/a/b/0'")
+	checkXMLValueError(t, `{"a": {"@b": [1]}}`, "the attribute '@b' must be a string, number or bool at '/a/@b'")
+	checkXMLValueError(t, `{"a": {"{urn:x}": 1}}`, "an empty name cannot be written as xml at '/a/{urn:x}'")
+
+	// Values are strings when read back
+	node := parseAny(t, `{"a": {"@id": "1", "b": ["x", "y"], "c": {"@k": "v", "#text": "t"}, "d": null}}`)
+	x, err := parser.XMLValue(node, parser.XMLOptions{Indent: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := parser.ParseXML([]byte(x), parser.XMLOptions{})
+	if err != nil || !parser.EqualValues(back, node) {
+		t.Errorf("xml\n%s\ndid not parse back to %s. error %v", x, node.JsonValue(), err)
+	}
+}
+
+// Names with no namespace stay out of the default namespace of their parent
+func TestXMLNamespaceRoundTrip(t *testing.T) {
+	uri := parser.XMLOptions{Namespaces: parser.XML_NS_URI}
+	soap := `<s:Env xmlns:s="urn:s"><s:Body><m>1</m><t:n xmlns:t="urn:t"><t:o>2</t:o></t:n></s:Body></s:Env>`
+	node, err := parser.ParseXML([]byte(soap), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := parser.XMLValue(node, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != `<Env xmlns="urn:s"><Body><m xmlns="">1</m><n xmlns="urn:t"><o>2</o></n></Body></Env>` {
+		t.Errorf("xml %s", x)
+	}
+	back, err := parser.ParseXML([]byte(x), uri)
+	if err != nil || !parser.EqualValues(back, node) {
+		t.Errorf("xml %s did not parse back to %s", x, node.JsonValue())
+	}
+}
+
+func checkXML(t *testing.T, xml string, options parser.XMLOptions, expected string) {
+	t.Helper()
+	node, err := parser.ParseXML([]byte(xml), options)
+	if err != nil {
+		t.Errorf("failed to parse %q: %s", xml, err)
+		return
+	}
+	if !parser.EqualValues(node, parseAny(t, expected)) {
+		t.Errorf("xml %q\nparsed %s\nexpected %s", xml, node.JsonValue(), parseAny(t, expected).JsonValue())
+	}
+}
+
+func checkXMLError(t *testing.T, xml string, line, column int, msg, context string) {
+	t.Helper()
+	_, err := parser.ParseXML([]byte(xml), parser.XMLOptions{})
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("xml %q expected a ParseError. Got %v", xml, err)
+		return
+	}
+	if pe.Line != line || pe.Column != column || pe.Msg != msg || pe.Context != context {
+		t.Errorf("xml %q error %d:%d %q %q expected %d:%d %q %q", xml, pe.Line, pe.Column, pe.Msg, pe.Context, line, column, msg, context)
+	}
+}
+
+func checkXMLValue(t *testing.T, json string, options parser.XMLOptions, expected string) {
+	t.Helper()
+	x, err := parser.XMLValue(parseAny(t, json), options)
+	if err != nil {
+		t.Errorf("failed to write %s: %s", json, err)
+		return
+	}
+	if x != expected {
+		t.Errorf("xml\n%s\nexpected\n%s", x, expected)
+	}
+}
+
+func checkXMLValueError(t *testing.T, json, msg string) {
+	t.Helper()
+	_, err := parser.XMLValue(parseAny(t, json), parser.XMLOptions{})
+	if err == nil || err.Error() != msg {
+		t.Errorf("%s error %v expected %q", json, err, msg)
+	}
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stuartdd2/JsonParser4go/parser"
+)
+
+const yamlConfig = `%YAML 1.2
+--- # A deployment config
+name: my app
+version: 1.20
+replicas: 0x10
+mode: 0o17
+enabled: yes
+debug: False
+owner: ~
+empty:
+quoted: 'it''s'
+escaped: "a\tb\u00e9 \x41"
+url: http://example.com:80/a
+multi: this is
+  continued
+
+  here
+ports:
+- 80
+- name: https
+  port: 443
+- - x
+  - y
+-
+  deep: true
+flow: [1, "two", {three: 3}, [a: b], ]
+inline: {a: 1, "b":2, c}
+`
+
+func TestYAMLParse(t *testing.T) {
+	checkYAML(t, yamlConfig, `{"name": "my app", "version": 1.20, "replicas": 16, "mode": 15, "enabled": "yes", "debug": false,
+		"owner": null, "empty": null, "quoted": "it's", "escaped": "a\tbé A", "url": "http://example.com:80/a",
+		"multi": "this is continued\nhere", "ports": [80, {"name": "https", "port": 443}, ["x", "y"], {"deep": true}],
+		"flow": [1, "two", {"three": 3}, [{"a": "b"}]], "inline": {"a": 1, "b": 2, "c": null}}`)
+	checkYAML(t, "", `null`)
+	checkYAML(t, "# only a comment\n", `null`)
+	checkYAML(t, "---\n42\n...\n", `42`)
+	checkYAML(t, "  a:\n  - 1\n  - [2]\n  b: 'x\n\n    y'", `{"a": [1, [2]], "b": "x\ny"}`)
+	checkYAML(t, "a: {\n  b: [1,\n    2],  # comment\n  c: d\n}\n", `{"a": {"b": [1, 2], "c": "d"}}`)
+	checkYAML(t, "a: 1 # comment\nb: a#b\nc: \"#\"\n", `{"a": 1, "b": "a#b", "c": "#"}`)
+	checkYAML(t, "n: [-1, +2, 1e3, 1., .5, 007, 12345678901234567890]", `{"n": [-1, 2, 1e3, 1, 0.5, 7, 12345678901234567890]}`)
+}
+
+func TestYAMLBlockScalars(t *testing.T) {
+	checkYAML(t, "lit: |\n  line 1\n    indented\n\n  line 3\nnext: 1\n", `{"lit": "line 1\n  indented\n\nline 3\n", "next": 1}`)
+	checkYAML(t, "fold: >\n  folded\n  text\n\n  para\n    more\n  end\n", `{"fold": "folded text\npara\n  more\nend\n"}`)
+	checkYAML(t, "- |-\n  strip\n\n- |+\n  keep\n\n- >2\n    two\n", `["strip", "keep\n\n", "  two\n"]`)
+	checkYAML(t, "a: |\n\nb: 1", `{"a": "", "b": 1}`)
+}
+
+func TestYAMLAnchors(t *testing.T) {
+	checkYAML(t, `base: &base
+  x: 1
+  y: 2
+list: &list [a, *base]
+derived:
+  <<: *base
+  y: 3
+  z: 4
+both:
+  <<: [{y: 0}, *base]
+copy: *list
+`, `{"base": {"x": 1, "y": 2}, "list": ["a", {"x": 1, "y": 2}], "derived": {"x": 1, "y": 3, "z": 4},
+		"both": {"y": 0, "x": 1}, "copy": ["a", {"x": 1, "y": 2}]}`)
+	checkYAML(t, "a: &x !!str 1\nb: !!int '2'\nc: !!float 3\nd: !Ref other\ne: !!map\nf: *x\ng: !!str\n", `{"a": "1", "b": 2, "c": 3, "d": "other", "e": {}, "f": "1", "g": ""}`)
+	checkYAML(t, `"<<": 1`, `{"<<": 1}`)
+}
+
+func TestYAMLErrors(t *testing.T) {
+	checkYAMLError(t, "a: b: c\n", 1, 4, "a mapping cannot start on this line")
+	checkYAMLError(t, "a: 1\n  b: 2\n", 2, 4, "unexpected ':'")
+	checkYAMLError(t, "a: 1\na: 2\n", 2, 1, "duplicate key 'a'")
+	checkYAMLError(t, "a: \"x\n", 1, 4, "the quoted value is not closed")
+	checkYAMLError(t, "a: *none\n", 1, 4, "the alias 'none' has no anchor")
+	checkYAMLError(t, "a: .inf\n", 1, 4, "the number '.inf' cannot be held in a JsonNumber")
+	checkYAMLError(t, "a:\n  - 1\n b: 2\n", 3, 2, "bad indentation of a mapping entry")
+	checkYAMLError(t, "a: [1, 2\n", 1, 4, "the '[' is not closed")
+	checkYAMLError(t, "a: {b: 1 c: 2}\n", 1, 11, "expected ',' or '}'")
+	checkYAMLError(t, "a: !!int x\n", 1, 10, "'x' is not a valid !!int")
+	checkYAMLError(t, "a: \"\\q\"\n", 1, 6, "invalid escape '\\q'")
+	checkYAMLError(t, "a: 1\n- b\n", 2, 1, "expected a mapping key")
+	checkYAMLError(t, "<<: 1\n", 1, 1, "the value of a merge key (<<) must be a mapping or a list of mappings")
+	checkYAMLError(t, "a: |x\n", 1, 5, "invalid block scalar indicator 'x'")
+	checkYAMLError(t, ": x\n", 1, 1, "a mapping key cannot be empty")
+	_, err := parser.ParseYAML([]byte("a\n---\nb\n"))
+	if err == nil || err.Error() != "expected one yaml document but found 2. Use ParseYAMLDocuments" {
+		t.Errorf("expected a documents error. Got %v", err)
+	}
+}
+
+func TestYAMLDocuments(t *testing.T) {
+	docs, err := parser.ParseYAMLDocuments([]byte("a: 1\n---\n- x\n...\n--- >\n  text\n---\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"a": 1}`, `["x"]`, `"text\n"`, `null`}
+	if len(docs) != len(expected) {
+		t.Fatalf("expected %d documents. Got %d", len(expected), len(docs))
+	}
+	for i, d := range docs {
+		if !parser.EqualValues(d, parseAny(t, expected[i])) {
+			t.Errorf("document %d is %s expected %s", i, d.JsonValue(), expected[i])
+		}
+	}
+}
+
+func TestYAMLValue(t *testing.T) {
+	root := parseAny(t, `{"name": "my app", "ports": [80, {"name": "https", "tls": {"on": true}}, [1, []], {}],
+		"text": "line 1\n  line 2\n", "strip": "a\nb", "keep": "a\n\n", "empty": "", "y": "no", "n": "123",
+		"quote": "a: b", "space": " x", "dash": "- x", "null": null, "obj": {}, "nested": {"a": {"b": 1.5e3}}}`)
+	checkYAMLValue(t, root, 2, `name: my app
+ports:
+  - 80
+  - name: https
+    tls:
+      "on": true
+  - - 1
+    - []
+  - {}
+text: |
+  line 1
+    line 2
+strip: |-
+  a
+  b
+keep: |+
+  a
+
+empty: ""
+"y": "no"
+"n": "123"
+quote: "a: b"
+space: " x"
+dash: "- x"
+"null": null
+obj: {}
+nested:
+  a:
+    b: 1.5e3
+`)
+	checkYAMLValue(t, parseAny(t, `[{"a": [1, {"b": 2}]}, "x\ty"]`), 4, `-   a:
+        - 1
+        -   b: 2
+- "x\ty"
+`)
+	checkYAMLValue(t, parseAny(t, `["😀", " 😀\n", "\u0085\u007f"]`), 2, "- 😀\n- \" 😀\\n\"\n- \"\\u0085\\u007F\"\n")
+	checkYAMLValue(t, parseAny(t, `"text"`), 2, "text\n")
+	checkYAMLValue(t, parseAny(t, `[]`), 2, "[]\n")
+}
+
+func checkYAML(t *testing.T, yaml, expected string) {
+	t.Helper()
+	node, err := parser.ParseYAML([]byte(yaml))
+	if err != nil {
+		t.Errorf("failed to parse %q: %s", yaml, err)
+		return
+	}
+	if !parser.EqualValues(node, parseAny(t, expected)) || node.JsonValue() != parseAny(t, expected).JsonValue() {
+		t.Errorf("yaml %q\nparsed %s\nexpected %s", yaml, node.JsonValue(), parseAny(t, expected).JsonValue())
+	}
+}
+
+func checkYAMLError(t *testing.T, yaml string, line, column int, msg string) {
+	t.Helper()
+	_, err := parser.ParseYAML([]byte(yaml))
+	var pe *parser.ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("yaml %q expected a ParseError. Got %v", yaml, err)
+		return
+	}
+	if pe.Line != line || pe.Column != column || pe.Msg != msg {
+		t.Errorf("yaml %q error %d:%d %q expected %d:%d %q", yaml, pe.Line, pe.Column, pe.Msg, line, column, msg)
+	}
+}
+
+func checkYAMLValue(t *testing.T, node parser.NodeI, tab int, expected string) {
+	t.Helper()
+	yaml := parser.YAMLValueIndented(node, tab)
+	if yaml != expected {
+		t.Errorf("yaml\n%s\nexpected\n%s", yaml, expected)
+	}
+	back, err := parser.ParseYAML([]byte(yaml))
+	if err != nil {
+		t.Errorf("failed to read back %q: %s", yaml, err)
+		return
+	}
+	if !parser.EqualValues(back, node) || !strings.HasSuffix(yaml, "\n") {
+		t.Errorf("yaml did not read back. %s expected %s", back.JsonValue(), node.JsonValue())
+	}
+}